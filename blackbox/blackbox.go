@@ -1,15 +1,16 @@
 package blackbox
 
 import (
+	"errors"
 	"fmt"
+	"maps"
 
 	"github.com/abialemuel/prometheus-exporter/blackbox/config"
 	"github.com/abialemuel/prometheus-exporter/blackbox/prober"
 	"github.com/abialemuel/prometheus-exporter/helper"
 	proto "github.com/abialemuel/prometheus-exporter/messages"
 	"github.com/go-kit/log"
-	promCfg "github.com/prometheus/common/config"
-	"github.com/prometheus/common/promlog"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type blackbox struct {
@@ -17,21 +18,51 @@ type blackbox struct {
 	rh            *prober.ResultHistory
 	sc            *config.SafeConfig
 	logger        log.Logger
+	moduleUnknown prometheus.Counter
 }
 
 type Blackbox interface {
 	Call(target string, moduleName string, data *proto.WorkerProbe) (helper.ProbeResult, error)
+	// CallWithOverrides probes target with a module changed by overrides
+	// for this call only. The sub-modules of a composite module are changed
+	// as well.
+	CallWithOverrides(target string, moduleName string, overrides ProbeOverrides) (helper.ProbeResult, error)
+	CallBatch(targets []string, moduleName string) (map[string]helper.ProbeResult, error)
+	// History returns the results of recent probes.
+	History() *prober.ResultHistory
 }
 
-func New(historyLimit uint, timeoutOffset float64, logLevel string) (Blackbox, error) {
-	v := &promlog.AllowedLevel{}
-	if err := v.Set(logLevel); err != nil {
-		return nil, fmt.Errorf("error setting log level: %w", err)
+// New loads blackbox.yml and returns an exporter keeping the last
+// historyLimit probe results. Its own metrics are registered with
+// prometheus.DefaultRegisterer and it logs to stderr at logLevel, unless
+// opts say otherwise.
+func New(historyLimit uint, timeoutOffset float64, logLevel string, opts ...helper.Option) (Blackbox, error) {
+	return NewWithHistory(&prober.ResultHistory{MaxResults: historyLimit}, timeoutOffset, logLevel, opts...)
+}
+
+// NewWithHistory is like New, recording probe results in the given history,
+// for example one persisted in a prober.FileStore.
+func NewWithHistory(rh *prober.ResultHistory, timeoutOffset float64, logLevel string, opts ...helper.Option) (Blackbox, error) {
+	o, err := helper.NewOptions(logLevel, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if o.SNMP != nil {
+		return nil, errors.New("SNMP options do not apply to the blackbox exporter")
+	}
+	moduleUnknown, err := helper.Register(o.Registerer, prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "blackbox_module_unknown_total",
+		Help: "Count of unknown modules requested by probes",
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("error registering metrics: %w", err)
 	}
-	logger := promlog.New(&promlog.Config{Level: v})
-	rh := &prober.ResultHistory{MaxResults: historyLimit}
-	sc := &config.SafeConfig{C: &config.Config{}}
-	if err := sc.ReloadConfig("blackbox.yml", logger); err != nil {
+	reloadMetrics, err := helper.NewReloadMetrics(o.Registerer, "blackbox_exporter", "Blackbox exporter")
+	if err != nil {
+		return nil, fmt.Errorf("error registering metrics: %w", err)
+	}
+	sc := &config.SafeConfig{C: &config.Config{}, Metrics: reloadMetrics}
+	if err := sc.ReloadConfig("blackbox.yml", o.Logger); err != nil {
 		return nil, fmt.Errorf("error loading config: %w", err)
 	}
 
@@ -39,65 +70,69 @@ func New(historyLimit uint, timeoutOffset float64, logLevel string) (Blackbox, e
 		timeoutOffset: timeoutOffset,
 		rh:            rh,
 		sc:            sc,
-		logger:        logger,
+		logger:        o.Logger,
+		moduleUnknown: moduleUnknown,
 	}, nil
 }
 
+// Call probes target with a module changed by the probe config of data, see
+// OverridesFromProto.
 func (c *blackbox) Call(target string, moduleName string, data *proto.WorkerProbe) (helper.ProbeResult, error) {
+	overrides, err := OverridesFromProto(data, c.timeoutOffset)
+	if err != nil {
+		return nil, err
+	}
+	return c.CallWithOverrides(target, moduleName, overrides)
+}
+
+// CallWithOverrides probes target with a module changed by overrides for
+// this call only. The sub-modules of a composite module are changed as well.
+func (c *blackbox) CallWithOverrides(target string, moduleName string, overrides ProbeOverrides) (helper.ProbeResult, error) {
 	module, ok := c.sc.C.Modules[moduleName]
 	if !ok {
+		c.moduleUnknown.Inc()
 		return nil, fmt.Errorf("unknown module %q", moduleName)
 	}
-
-	webConfig := data.GetWebsite()
-	if webConfig != nil {
-		if webConfig.Authorization != nil {
-			if webConfig.Authorization.Username != "" && webConfig.Authorization.Password != "" {
-				// inject username and password for module
-				basicAuth := &promCfg.BasicAuth{
-					Username: webConfig.Authorization.Username,
-					Password: promCfg.Secret(webConfig.Authorization.Password),
-				}
-				module.HTTP.HTTPClientConfig.BasicAuth = basicAuth
-			}
-		}
-
-		// inject headers for module
-		module.HTTP.Headers = webConfig.Headers
-
-		// inject method & body for module
-		module.HTTP.Method = webConfig.Method
-		module.HTTP.Body = webConfig.Body
+	module, err := overrides.apply(module)
+	if err != nil {
+		return nil, err
 	}
-
-	icmpQosConfig := data.GetICMPQOS()
-	if icmpQosConfig != nil {
-		// inject icmp qos for module
-		if icmpQosConfig.Timeout == 0 {
-			// in milliseconds
-			icmpQosConfig.Timeout = int32(c.timeoutOffset * 1000)
-		} else {
-			// in milliseconds
-			icmpQosConfig.Timeout = int32(icmpQosConfig.Timeout * 1000)
-
-		}
-
-		// inject icmp qos for module
-		module.ICMPQOS = config.ICMPQOSProbe{
-			PacketSize: int(icmpQosConfig.PacketSize),
-			Count:      int(icmpQosConfig.Count),
-			Interval:   int(icmpQosConfig.Interval),
-			Timeout:    int(icmpQosConfig.Timeout),
-			TTL:        config.DefaultICMPQoSProbe.TTL,
-		}
+	timeoutOffset := c.timeoutOffset
+	if overrides.Timeout > 0 {
+		timeoutOffset = overrides.Timeout.Seconds()
 	}
 
-	// new config modules
-	newModules := map[string]config.Module{
-		moduleName: module,
+	// new config modules, keeping the other modules for the sub-modules of
+	// composite modules, which are changed by the overrides as well
+	newModules := maps.Clone(c.sc.C.Modules)
+	newModules[moduleName] = module
+	if module.Prober == "composite" {
+		for _, m := range module.Composite.Modules {
+			sub, ok := newModules[m.Module]
+			if !ok {
+				continue
+			}
+			if sub, err = overrides.apply(sub); err != nil {
+				return nil, fmt.Errorf("module %q: %w", m.Module, err)
+			}
+			newModules[m.Module] = sub
+		}
 	}
 	config := &config.Config{
 		Modules: newModules,
 	}
-	return prober.Call(target, moduleName, config, c.logger, c.rh, c.timeoutOffset)
+	return prober.Call(target, moduleName, config, c.logger, c.rh, timeoutOffset)
+}
+
+// CallBatch pings many targets in one sweep of an icmp_batch module.
+func (c *blackbox) CallBatch(targets []string, moduleName string) (map[string]helper.ProbeResult, error) {
+	if _, ok := c.sc.C.Modules[moduleName]; !ok {
+		c.moduleUnknown.Inc()
+	}
+	return prober.CallBatch(targets, moduleName, c.sc.C, c.logger, c.rh, c.timeoutOffset)
+}
+
+// History returns the results of recent probes.
+func (c *blackbox) History() *prober.ResultHistory {
+	return c.rh
 }
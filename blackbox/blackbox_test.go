@@ -0,0 +1,89 @@
+package blackbox
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abialemuel/prometheus-exporter/helper"
+	proto "github.com/abialemuel/prometheus-exporter/messages"
+)
+
+func TestCallComposite(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	bb, err := New(1, 0, "", helper.WithRegisterer(prometheus.NewRegistry()), helper.WithLogger(log.NewNopLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The website module of blackbox.yml runs http_2xx and icmp. ICMP may
+	// not be permitted, so only the http_2xx sub-module is checked.
+	result, err := bb.Call(ts.URL, "website", &proto.WorkerProbe{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := result.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), `probe_success{submodule="http_2xx"} 1`) {
+		t.Errorf("Expected the http_2xx sub-module to succeed, got:\n%s", text)
+	}
+
+	results := bb.History().List()
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result in the history, got %d", len(results))
+	}
+	if strings.Contains(results[0].DebugOutput, "Unknown prober") {
+		t.Errorf("Expected the sub-modules to be found, got logs:\n%s", results[0].DebugOutput)
+	}
+}
+
+func TestCallWithOverridesComposite(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Probe") != "composite" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer ts.Close()
+
+	bb, err := New(1, 0, "", helper.WithRegisterer(prometheus.NewRegistry()), helper.WithLogger(log.NewNopLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := bb.CallWithOverrides(ts.URL, "website", ProbeOverrides{
+		HTTP: &HTTPOverrides{Headers: map[string]string{"X-Probe": "composite"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := result.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), `probe_success{submodule="http_2xx"} 1`) {
+		t.Errorf("Expected the overrides to apply to the http_2xx sub-module, got:\n%s", text)
+	}
+
+	// The configured sub-module is left unchanged.
+	result, err = bb.Call(ts.URL, "website", &proto.WorkerProbe{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text, _ := result.Text(); !strings.Contains(string(text), `probe_success{submodule="http_2xx"} 0`) {
+		t.Errorf("Expected the http_2xx sub-module to fail without the header, got:\n%s", text)
+	}
+}
+
+func TestNewRejectsSNMPOptions(t *testing.T) {
+	// Set the way snmp.WithConcurrency and the other SNMP options do.
+	snmpOption := func(o *helper.Options) { o.SNMP = &helper.SNMPOptions{Concurrency: 4} }
+	if _, err := New(1, 0, "", helper.WithRegisterer(prometheus.NewRegistry()), snmpOption); err == nil {
+		t.Errorf("Expected an error for SNMP options")
+	}
+}
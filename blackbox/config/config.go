@@ -14,9 +14,11 @@
 package config
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"net"
 	"net/textproto"
 	"os"
 	"regexp"
@@ -29,35 +31,29 @@ import (
 
 	yaml "gopkg.in/yaml.v3"
 
+	"github.com/abialemuel/prometheus-exporter/helper"
 	"github.com/alecthomas/units"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/miekg/dns"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/config"
 )
 
 var (
-	configReloadSuccess = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: "blackbox_exporter",
-		Name:      "config_last_reload_successful",
-		Help:      "Blackbox exporter config loaded successfully.",
-	})
-
-	configReloadSeconds = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: "blackbox_exporter",
-		Name:      "config_last_reload_success_timestamp_seconds",
-		Help:      "Timestamp of the last successful configuration reload.",
-	})
-
 	// DefaultModule set default configuration for the Module
 	DefaultModule = Module{
-		HTTP:    DefaultHTTPProbe,
-		TCP:     DefaultTCPProbe,
-		ICMP:    DefaultICMPProbe,
-		ICMPQOS: DefaultICMPQoSProbe,
-		DNS:     DefaultDNSProbe,
+		HTTP:           DefaultHTTPProbe,
+		TCP:            DefaultTCPProbe,
+		ICMP:           DefaultICMPProbe,
+		ICMPQOS:        DefaultICMPQoSProbe,
+		DNS:            DefaultDNSProbe,
+		DNSConsistency: DefaultDNSConsistencyProbe,
+		Traceroute:     DefaultTracerouteProbe,
+		PMTU:           DefaultPMTUProbe,
+		ICMPBatch:      DefaultICMPBatchProbe,
+		TWAMP:          DefaultTWAMPProbe,
+		Composite:      DefaultCompositeProbe,
+		Retry:          DefaultRetryPolicy,
 	}
 
 	// DefaultHTTPProbe set default value for HTTPProbe
@@ -74,11 +70,13 @@ var (
 
 	// DefaultICMPQoSProbe set default value for ICMPQOSProbe
 	DefaultICMPQoSProbe = ICMPQOSProbe{
-		TTL:        DefaultICMPTTL, // in seconds or hops number
-		Timeout:    1200,           // in milliseconds
-		Interval:   10,             // in milliseconds
-		Count:      100,            // repetitive count
-		PacketSize: 64,             // in bytes
+		IPProtocol:         "ip4",
+		IPProtocolFallback: true,
+		TTL:                DefaultICMPTTL, // in seconds or hops number
+		Timeout:            1200,           // in milliseconds
+		Interval:           10,             // in milliseconds
+		Count:              100,            // repetitive count
+		PacketSize:         64,             // in bytes
 	}
 
 	// DefaultTCPProbe set default value for TCPProbe
@@ -98,6 +96,60 @@ var (
 		IPProtocolFallback: true,
 		Recursion:          true,
 	}
+
+	// DefaultTracerouteProbe set default value for TracerouteProbe
+	DefaultTracerouteProbe = TracerouteProbe{
+		Protocol:           "icmp",
+		IPProtocolFallback: true,
+		FirstHop:           1,
+		MaxHops:            30,
+		Queries:            3,
+		QueryTimeout:       time.Second,
+	}
+
+	// DefaultICMPBatchProbe set default value for ICMPBatchProbe
+	DefaultICMPBatchProbe = ICMPBatchProbe{
+		IPProtocolFallback: true,
+		Count:              1,
+		Interval:           time.Second,
+		RateLimit:          500,
+	}
+
+	// DefaultPMTUProbe set default value for PMTUProbe
+	DefaultPMTUProbe = PMTUProbe{
+		IPProtocolFallback: true,
+		MaxMTU:             1500,
+		Queries:            2,
+		QueryTimeout:       time.Second,
+	}
+
+	// DefaultTWAMPProbe set default value for TWAMPProbe
+	DefaultTWAMPProbe = TWAMPProbe{
+		IPProtocolFallback: true,
+		Count:              10,
+		Interval:           100 * time.Millisecond,
+		ReplyTimeout:       time.Second,
+		PaddingSize:        27, // Makes test packets as large as reflected ones.
+	}
+
+	// DefaultRetryPolicy set default value for RetryPolicy
+	DefaultRetryPolicy = RetryPolicy{
+		Attempts: 1,
+		Backoff:  100 * time.Millisecond,
+		RetryOn:  []string{"connect_error", "timeout"},
+	}
+
+	// DefaultCompositeProbe set default value for CompositeProbe
+	DefaultCompositeProbe = CompositeProbe{
+		Mode:          "parallel",
+		SuccessPolicy: "all",
+	}
+
+	// DefaultDNSConsistencyProbe set default value for DNSConsistencyProbe
+	DefaultDNSConsistencyProbe = DNSConsistencyProbe{
+		IPProtocolFallback: true,
+		FailIfInconsistent: true,
+	}
 )
 
 type Config struct {
@@ -107,17 +159,14 @@ type Config struct {
 type SafeConfig struct {
 	sync.RWMutex
 	C *Config
+	// Metrics, if set, report the outcome of reloads.
+	Metrics *helper.ReloadMetrics
 }
 
 func (sc *SafeConfig) ReloadConfig(confFile string, logger log.Logger) (err error) {
 	var c = &Config{}
 	defer func() {
-		if err != nil {
-			configReloadSuccess.Set(0)
-		} else {
-			configReloadSuccess.Set(1)
-			configReloadSeconds.SetToCurrentTime()
-		}
+		sc.Metrics.Observe(err)
 	}()
 
 	yamlReader, err := os.Open(confFile)
@@ -131,6 +180,9 @@ func (sc *SafeConfig) ReloadConfig(confFile string, logger log.Logger) (err erro
 	if err = decoder.Decode(c); err != nil {
 		return fmt.Errorf("error parsing config file: %s", err)
 	}
+	if err = c.validateComposites(); err != nil {
+		return fmt.Errorf("error parsing config file: %s", err)
+	}
 
 	for name, module := range c.Modules {
 		if module.HTTP.NoFollowRedirects != nil {
@@ -198,14 +250,23 @@ func MustNewRegexp(s string) Regexp {
 }
 
 type Module struct {
-	Prober  string        `yaml:"prober,omitempty"`
-	Timeout time.Duration `yaml:"timeout,omitempty"`
-	HTTP    HTTPProbe     `yaml:"http,omitempty"`
-	TCP     TCPProbe      `yaml:"tcp,omitempty"`
-	ICMP    ICMPProbe     `yaml:"icmp,omitempty"`
-	ICMPQOS ICMPQOSProbe  `yaml:"icmp_qos,omitempty"`
-	DNS     DNSProbe      `yaml:"dns,omitempty"`
-	GRPC    GRPCProbe     `yaml:"grpc,omitempty"`
+	Prober         string              `yaml:"prober,omitempty"`
+	Timeout        time.Duration       `yaml:"timeout,omitempty"`
+	HTTP           HTTPProbe           `yaml:"http,omitempty"`
+	TCP            TCPProbe            `yaml:"tcp,omitempty"`
+	ICMP           ICMPProbe           `yaml:"icmp,omitempty"`
+	ICMPQOS        ICMPQOSProbe        `yaml:"icmp_qos,omitempty"`
+	DNS            DNSProbe            `yaml:"dns,omitempty"`
+	GRPC           GRPCProbe           `yaml:"grpc,omitempty"`
+	DNSConsistency DNSConsistencyProbe `yaml:"dns_consistency,omitempty"`
+	Traceroute     TracerouteProbe     `yaml:"traceroute,omitempty"`
+	PMTU           PMTUProbe           `yaml:"pmtu,omitempty"`
+	ICMPBatch      ICMPBatchProbe      `yaml:"icmp_batch,omitempty"`
+	TWAMP          TWAMPProbe          `yaml:"twamp,omitempty"`
+	Composite      CompositeProbe      `yaml:"composite,omitempty"`
+	Retry          RetryPolicy         `yaml:"retry,omitempty"`
+	// Extension configures probers registered with prober.RegisterProber.
+	Extension ExtensionConfig `yaml:"extension,omitempty"`
 }
 
 type HTTPProbe struct {
@@ -237,6 +298,45 @@ type GRPCProbe struct {
 	TLSConfig           config.TLSConfig `yaml:"tls_config,omitempty"`
 	IPProtocolFallback  bool             `yaml:"ip_protocol_fallback,omitempty"`
 	PreferredIPProtocol string           `yaml:"preferred_ip_protocol,omitempty"`
+	// Method is a unary method such as "package.Service/Method" to invoke
+	// instead of the health check.
+	Method string `yaml:"method,omitempty"`
+	// Request is the JSON encoded request message, an empty message when unset.
+	Request string `yaml:"request,omitempty"`
+	// DescriptorSetFile is a binary FileDescriptorSet describing the method.
+	// Server reflection is used when it is unset.
+	DescriptorSetFile  string                  `yaml:"descriptor_set_file,omitempty"`
+	ResponseAssertions []GRPCResponseAssertion `yaml:"response_assertions,omitempty"`
+	// Metadata is sent with every request.
+	Metadata        map[string]string `yaml:"metadata,omitempty"`
+	BearerToken     config.Secret     `yaml:"bearer_token,omitempty"`
+	BearerTokenFile string            `yaml:"bearer_token_file,omitempty"`
+	// Authority overrides the :authority header, which defaults to the
+	// target host and port. Without a `server_name` in tls_config the TLS
+	// server name is taken from it, otherwise both have to match.
+	Authority string        `yaml:"authority,omitempty"`
+	Keepalive GRPCKeepalive `yaml:"keepalive,omitempty"`
+	// WatchWindow streams health updates with Watch for the given time
+	// instead of calling Check once.
+	WatchWindow time.Duration `yaml:"watch_window,omitempty"`
+}
+
+// GRPCKeepalive configures client keepalive pings. Pings are disabled when
+// Time is zero.
+type GRPCKeepalive struct {
+	Time                time.Duration `yaml:"time,omitempty"`
+	Timeout             time.Duration `yaml:"timeout,omitempty"`
+	PermitWithoutStream bool          `yaml:"permit_without_stream,omitempty"`
+}
+
+// GRPCResponseAssertion checks a field of the response of a method. Fields
+// are addressed by a dotted path of their JSON names, list elements by
+// their index, e.g. "items.0.name". Values are compared in their JSON form,
+// enums by name.
+type GRPCResponseAssertion struct {
+	Field  string `yaml:"field,omitempty"`
+	Equals string `yaml:"equals,omitempty"`
+	Regexp Regexp `yaml:"regexp,omitempty"`
 }
 
 type HeaderMatch struct {
@@ -269,12 +369,111 @@ type ICMPProbe struct {
 	TTL                int    `yaml:"ttl,omitempty"`
 }
 
+// TracerouteProbe sends probes with increasing TTL and records the hops that
+// answer with ICMP time exceeded. Replies are read from a raw ICMP socket, so
+// the probe requires CAP_NET_RAW in every protocol mode.
+type TracerouteProbe struct {
+	Protocol           string        `yaml:"protocol,omitempty"` // One of icmp, udp or tcp.
+	IPProtocol         string        `yaml:"preferred_ip_protocol,omitempty"`
+	IPProtocolFallback bool          `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string        `yaml:"source_ip_address,omitempty"`
+	Port               int           `yaml:"port,omitempty"` // Defaults to 33434 for udp and 80 for tcp.
+	FirstHop           int           `yaml:"first_hop,omitempty"`
+	MaxHops            int           `yaml:"max_hops,omitempty"`
+	Queries            int           `yaml:"queries,omitempty"` // Probes sent per hop.
+	QueryTimeout       time.Duration `yaml:"query_timeout,omitempty"`
+	PayloadSize        int           `yaml:"payload_size,omitempty"`
+}
+
+// ICMPBatchProbe pings many targets over shared sockets. The module timeout
+// applies to the whole sweep, so it has to cover sending to every target at
+// the configured rate.
+type ICMPBatchProbe struct {
+	IPProtocol         string        `yaml:"preferred_ip_protocol,omitempty"` // Defaults to "ip6".
+	IPProtocolFallback bool          `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string        `yaml:"source_ip_address,omitempty"`
+	PayloadSize        int           `yaml:"payload_size,omitempty"`
+	Count              int           `yaml:"count,omitempty"`      // Echo requests sent to each target.
+	Interval           time.Duration `yaml:"interval,omitempty"`   // Time between requests to the same target.
+	RateLimit          int           `yaml:"rate_limit,omitempty"` // Echo requests per second over all targets.
+}
+
+// RetryPolicy repeats failed probes within the module timeout. Failures
+// are classified as connect_error when the probe got no usable response,
+// assertion_failure when a response did not match the expectations of the
+// module and timeout when the attempt ran out of time.
+type RetryPolicy struct {
+	Attempts int `yaml:"attempts,omitempty"` // Including the first attempt.
+	// Backoff is the wait before the second attempt, it doubles with every
+	// further attempt up to MaxBackoff.
+	Backoff    time.Duration `yaml:"backoff,omitempty"`
+	MaxBackoff time.Duration `yaml:"max_backoff,omitempty"`
+	// AttemptTimeout limits single attempts, so that a hanging attempt
+	// leaves time for the next one.
+	AttemptTimeout time.Duration `yaml:"attempt_timeout,omitempty"`
+	RetryOn        []string      `yaml:"retry_on,omitempty"`
+}
+
+// CompositeProbe runs other modules against the same target within the
+// timeout of the composite module. Metrics of each sub-module carry a
+// submodule label.
+type CompositeProbe struct {
+	Modules       []CompositeModule `yaml:"modules,omitempty"`
+	Mode          string            `yaml:"mode,omitempty"`           // One of parallel or sequence.
+	SuccessPolicy string            `yaml:"success_policy,omitempty"` // One of all, any, quorum or weighted.
+	// Quorum is the number of sub-modules that have to succeed with the
+	// quorum policy, defaults to a majority.
+	Quorum int `yaml:"quorum,omitempty"`
+	// Threshold is the share of the total weight of the sub-modules that
+	// has to succeed with the weighted policy.
+	Threshold float64 `yaml:"threshold,omitempty"`
+}
+
+// CompositeModule references a module run by a composite module.
+type CompositeModule struct {
+	Module string  `yaml:"module,omitempty"`
+	Weight float64 `yaml:"weight,omitempty"` // Defaults to 1.
+}
+
+// TWAMPProbe sends TWAMP-Light (RFC 5357) test packets to a reflector. The
+// target is a host with an optional port, the reflector has to be running
+// already as there is no control session.
+type TWAMPProbe struct {
+	IPProtocol         string        `yaml:"preferred_ip_protocol,omitempty"`
+	IPProtocolFallback bool          `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string        `yaml:"source_ip_address,omitempty"`
+	Port               int           `yaml:"port,omitempty"` // Defaults to 862 when the target has no port.
+	Count              int           `yaml:"count,omitempty"`
+	Interval           time.Duration `yaml:"interval,omitempty"`
+	ReplyTimeout       time.Duration `yaml:"reply_timeout,omitempty"` // Time to wait for replies after the last packet.
+	PaddingSize        int           `yaml:"padding_size,omitempty"`
+	DSCP               int           `yaml:"dscp,omitempty"`
+}
+
+// PMTUProbe searches for the largest packet with the don't fragment flag set
+// that reaches the target. Sizes include the IP header. The probe requires
+// CAP_NET_RAW and is only supported on Linux.
+type PMTUProbe struct {
+	IPProtocol         string        `yaml:"preferred_ip_protocol,omitempty"`
+	IPProtocolFallback bool          `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string        `yaml:"source_ip_address,omitempty"`
+	MinMTU             int           `yaml:"min_mtu,omitempty"` // Defaults to 576 for IPv4 and 1280 for IPv6.
+	MaxMTU             int           `yaml:"max_mtu,omitempty"`
+	Queries            int           `yaml:"queries,omitempty"` // Probes sent per size before it is considered lost.
+	QueryTimeout       time.Duration `yaml:"query_timeout,omitempty"`
+}
+
 type ICMPQOSProbe struct {
-	PacketSize int `yaml:"packet_size,omitempty"`
-	Interval   int `yaml:"interval,omitempty"`
-	Count      int `yaml:"count,omitempty"`
-	Timeout    int `yaml:"timeout,omitempty"`
-	TTL        int `yaml:"ttl,omitempty"`
+	IPProtocol         string `yaml:"preferred_ip_protocol,omitempty"` // Defaults to "ip4".
+	IPProtocolFallback bool   `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string `yaml:"source_ip_address,omitempty"`
+	DSCP               int    `yaml:"dscp,omitempty"` // Sets the upper 6 bits of the TOS (traffic class for IPv6) byte.
+	PacketSize         int    `yaml:"packet_size,omitempty"`
+	Interval           int    `yaml:"interval,omitempty"`
+	Count              int    `yaml:"count,omitempty"`
+	Timeout            int    `yaml:"timeout,omitempty"`
+	TTL                int    `yaml:"ttl,omitempty"`
+	Codec              string `yaml:"codec,omitempty"` // One of g711 or g729, enables the MOS and R-factor.
 }
 
 type DNSProbe struct {
@@ -292,6 +491,52 @@ type DNSProbe struct {
 	ValidateAnswer     DNSRRValidator   `yaml:"validate_answer_rrs,omitempty"`
 	ValidateAuthority  DNSRRValidator   `yaml:"validate_authority_rrs,omitempty"`
 	ValidateAdditional DNSRRValidator   `yaml:"validate_additional_rrs,omitempty"`
+	ExportTTLs         bool             `yaml:"export_ttls,omitempty"`
+	ExportAnswerInfo   bool             `yaml:"export_answer_info,omitempty"`
+	ExportResponseInfo bool             `yaml:"export_response_info,omitempty"`
+	EDNS0              DNSEDNS0         `yaml:"edns0,omitempty"`
+}
+
+// DNSEDNS0 configures the EDNS0 OPT record added to DNS queries. No OPT
+// record is sent when all fields are unset.
+type DNSEDNS0 struct {
+	UDPSize          uint16 `yaml:"udp_size,omitempty"`      // Defaults to 1232 when EDNS0 is used.
+	ClientSubnet     string `yaml:"client_subnet,omitempty"` // Address or CIDR, e.g. 192.0.2.0/24.
+	Cookie           bool   `yaml:"cookie,omitempty"`
+	NSID             bool   `yaml:"nsid,omitempty"`
+	PaddingBlockSize int    `yaml:"padding_block_size,omitempty"`
+}
+
+// Enabled returns whether an OPT record should be added to the query.
+func (e DNSEDNS0) Enabled() bool {
+	return e.UDPSize != 0 || e.ClientSubnet != "" || e.Cookie || e.NSID || e.PaddingBlockSize != 0
+}
+
+// Validate checks that the EDNS0 options are usable.
+func (e DNSEDNS0) Validate() error {
+	if e.ClientSubnet != "" {
+		if _, _, err := net.ParseCIDR(e.ClientSubnet); err != nil && net.ParseIP(e.ClientSubnet) == nil {
+			return fmt.Errorf("client subnet '%s' is not valid", e.ClientSubnet)
+		}
+	}
+	if e.PaddingBlockSize < 0 || e.PaddingBlockSize > 65535 {
+		return fmt.Errorf("padding block size %d is not valid", e.PaddingBlockSize)
+	}
+	return nil
+}
+
+// DNSConsistencyProbe queries every authoritative server of a zone for the
+// same name and compares the answers. The probe target is the zone name.
+type DNSConsistencyProbe struct {
+	IPProtocol         string   `yaml:"preferred_ip_protocol,omitempty"`
+	IPProtocolFallback bool     `yaml:"ip_protocol_fallback,omitempty"`
+	SourceIPAddress    string   `yaml:"source_ip_address,omitempty"`
+	TransportProtocol  string   `yaml:"transport_protocol,omitempty"`
+	Nameservers        []string `yaml:"nameservers,omitempty"` // Discovered via NS lookup when empty.
+	QueryClass         string   `yaml:"query_class,omitempty"` // Defaults to IN.
+	QueryName          string   `yaml:"query_name,omitempty"`  // Defaults to the target zone.
+	QueryType          string   `yaml:"query_type,omitempty"`  // Defaults to SOA.
+	FailIfInconsistent bool     `yaml:"fail_if_inconsistent,omitempty"`
 }
 
 type DNSRRValidator struct {
@@ -317,7 +562,10 @@ func (s *Module) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal((*plain)(s)); err != nil {
 		return err
 	}
-	return nil
+	if s.Timeout > 0 && s.GRPC.WatchWindow >= s.Timeout {
+		return errors.New("gRPC watch_window must be shorter than the module timeout")
+	}
+	return s.Extension.decode(s.Prober)
 }
 
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
@@ -369,6 +617,54 @@ func (s *GRPCProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	if err := unmarshal((*plain)(s)); err != nil {
 		return err
 	}
+
+	if s.Method != "" {
+		service, method, ok := strings.Cut(strings.TrimPrefix(s.Method, "/"), "/")
+		if !ok || service == "" || method == "" || strings.Contains(method, "/") {
+			return fmt.Errorf("gRPC method '%s' is not valid, expected \"package.Service/Method\"", s.Method)
+		}
+		if s.Request != "" && !json.Valid([]byte(s.Request)) {
+			return errors.New("gRPC request must be valid JSON")
+		}
+	} else if s.Request != "" || s.DescriptorSetFile != "" || len(s.ResponseAssertions) > 0 {
+		return errors.New("gRPC method must be set to send a request or check the response")
+	}
+	if s.BearerToken != "" && s.BearerTokenFile != "" {
+		return errors.New("at most one of bearer_token & bearer_token_file must be configured")
+	}
+	for key := range s.Metadata {
+		if key == "" || strings.HasPrefix(key, ":") {
+			return fmt.Errorf("gRPC metadata key '%s' is not valid", key)
+		}
+		if strings.EqualFold(key, "authorization") && (s.BearerToken != "" || s.BearerTokenFile != "") {
+			return errors.New("gRPC metadata cannot set authorization together with a bearer token")
+		}
+	}
+	if s.Keepalive.Time < 0 || s.Keepalive.Timeout < 0 {
+		return errors.New("gRPC keepalive durations cannot be negative")
+	}
+	if s.WatchWindow < 0 {
+		return errors.New("gRPC watch_window cannot be negative")
+	}
+	if s.WatchWindow > 0 && s.Method != "" {
+		return errors.New("gRPC watch_window cannot be used together with method")
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *GRPCResponseAssertion) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain GRPCResponseAssertion
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+
+	if s.Field == "" {
+		return errors.New("field must be set for gRPC response assertions")
+	}
+	if s.Equals == "" && s.Regexp.Regexp == nil {
+		return errors.New("equals or regexp must be set for gRPC response assertions")
+	}
 	return nil
 }
 
@@ -392,6 +688,33 @@ func (s *DNSProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			return fmt.Errorf("query type '%s' is not valid", s.QueryType)
 		}
 	}
+	if err := s.EDNS0.Validate(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *DNSConsistencyProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultDNSConsistencyProbe
+	type plain DNSConsistencyProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+	if s.TransportProtocol != "" && s.TransportProtocol != "udp" && s.TransportProtocol != "tcp" {
+		return fmt.Errorf("transport protocol '%s' is not valid", s.TransportProtocol)
+	}
+	if s.QueryClass != "" {
+		if _, ok := dns.StringToClass[s.QueryClass]; !ok {
+			return fmt.Errorf("query class '%s' is not valid", s.QueryClass)
+		}
+	}
+	if s.QueryType != "" {
+		if _, ok := dns.StringToType[s.QueryType]; !ok {
+			return fmt.Errorf("query type '%s' is not valid", s.QueryType)
+		}
+	}
 
 	return nil
 }
@@ -436,6 +759,207 @@ func (s *ICMPProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	return nil
 }
 
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *ICMPQOSProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultICMPQoSProbe
+	type plain ICMPQOSProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+
+	switch s.Codec {
+	case "", "g711", "g729":
+	default:
+		return fmt.Errorf("codec '%s' is not valid", s.Codec)
+	}
+	if s.DSCP < 0 || s.DSCP > 63 {
+		return errors.New("\"dscp\" must be between 0 and 63")
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *TracerouteProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultTracerouteProbe
+	type plain TracerouteProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+
+	switch s.Protocol {
+	case "icmp", "udp", "tcp":
+	default:
+		return fmt.Errorf("traceroute protocol '%s' is not valid", s.Protocol)
+	}
+	if s.MaxHops < 1 || s.MaxHops > 255 {
+		return errors.New("\"max_hops\" must be between 1 and 255")
+	}
+	if s.FirstHop < 1 || s.FirstHop > s.MaxHops {
+		return errors.New("\"first_hop\" must be between 1 and \"max_hops\"")
+	}
+	if s.Queries < 1 {
+		return errors.New("\"queries\" must be at least 1")
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		return errors.New("\"port\" must be between 0 and 65535")
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *ICMPBatchProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultICMPBatchProbe
+	type plain ICMPBatchProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+
+	if s.Count < 1 {
+		return errors.New("\"count\" must be at least 1")
+	}
+	// Requests are sent by a ticker, which cannot tick more often than every
+	// nanosecond.
+	if s.RateLimit < 1 || s.RateLimit > int(time.Second) {
+		return fmt.Errorf("\"rate_limit\" must be between 1 and %d", int(time.Second))
+	}
+	if s.Interval < 0 {
+		return errors.New("\"interval\" cannot be negative")
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *RetryPolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultRetryPolicy
+	type plain RetryPolicy
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+
+	if s.Attempts < 1 {
+		return errors.New("retry \"attempts\" must be at least 1")
+	}
+	if s.Backoff < 0 || s.MaxBackoff < 0 || s.AttemptTimeout < 0 {
+		return errors.New("retry durations cannot be negative")
+	}
+	for _, condition := range s.RetryOn {
+		switch condition {
+		case "connect_error", "assertion_failure", "timeout":
+		default:
+			return fmt.Errorf("retry condition '%s' is not valid", condition)
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *CompositeProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultCompositeProbe
+	type plain CompositeProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+
+	if len(s.Modules) == 0 {
+		return errors.New("composite modules must be set")
+	}
+	seen := make(map[string]bool, len(s.Modules))
+	for _, m := range s.Modules {
+		if m.Module == "" {
+			return errors.New("composite module name must be set")
+		}
+		if seen[m.Module] {
+			return fmt.Errorf("composite module '%s' is listed twice", m.Module)
+		}
+		seen[m.Module] = true
+		if m.Weight < 0 {
+			return errors.New("composite module \"weight\" cannot be negative")
+		}
+	}
+	if s.Mode != "parallel" && s.Mode != "sequence" {
+		return fmt.Errorf("composite mode '%s' is not valid", s.Mode)
+	}
+	switch s.SuccessPolicy {
+	case "all", "any", "weighted":
+	case "quorum":
+		if s.Quorum < 0 || s.Quorum > len(s.Modules) {
+			return errors.New("composite \"quorum\" must be between 0 and the number of modules")
+		}
+	default:
+		return fmt.Errorf("composite success policy '%s' is not valid", s.SuccessPolicy)
+	}
+	if s.Threshold < 0 || s.Threshold > 1 {
+		return errors.New("composite \"threshold\" must be between 0 and 1")
+	}
+	return nil
+}
+
+// validateComposites checks that composite modules only reference
+// existing modules that are not composite themselves.
+func (c *Config) validateComposites() error {
+	for name, module := range c.Modules {
+		if module.Prober != "composite" {
+			continue
+		}
+		for _, m := range module.Composite.Modules {
+			sub, ok := c.Modules[m.Module]
+			if !ok {
+				return fmt.Errorf("composite module '%s' references unknown module '%s'", name, m.Module)
+			}
+			if sub.Prober == "composite" {
+				return fmt.Errorf("composite module '%s' cannot run composite module '%s'", name, m.Module)
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *TWAMPProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultTWAMPProbe
+	type plain TWAMPProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+
+	if s.Count < 1 {
+		return errors.New("\"count\" must be at least 1")
+	}
+	if s.Port < 0 || s.Port > 65535 {
+		return errors.New("\"port\" must be between 0 and 65535")
+	}
+	if s.Interval < 0 {
+		return errors.New("\"interval\" cannot be negative")
+	}
+	if s.PaddingSize < 0 || s.PaddingSize > 9000 {
+		return errors.New("\"padding_size\" must be between 0 and 9000")
+	}
+	if s.DSCP < 0 || s.DSCP > 63 {
+		return errors.New("\"dscp\" must be between 0 and 63")
+	}
+	return nil
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface.
+func (s *PMTUProbe) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	*s = DefaultPMTUProbe
+	type plain PMTUProbe
+	if err := unmarshal((*plain)(s)); err != nil {
+		return err
+	}
+
+	if s.MaxMTU < 68 || s.MaxMTU > 65535 {
+		return errors.New("\"max_mtu\" must be between 68 and 65535")
+	}
+	if s.MinMTU < 0 || s.MinMTU > s.MaxMTU {
+		return errors.New("\"min_mtu\" must be between 0 and \"max_mtu\"")
+	}
+	if s.Queries < 1 {
+		return errors.New("\"queries\" must be at least 1")
+	}
+	return nil
+}
+
 // UnmarshalYAML implements the yaml.Unmarshaler interface.
 func (s *QueryResponse) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	type plain QueryResponse
@@ -59,6 +59,66 @@ func TestLoadBadConfigs(t *testing.T) {
 			input: "testdata/invalid-dns-type.yml",
 			want:  "error parsing config file: query type 'X' is not valid",
 		},
+		{
+			input: "testdata/invalid-dns-client-subnet.yml",
+			want:  "error parsing config file: client subnet '192.0.2.0/33' is not valid",
+		},
+		{
+			input: "testdata/invalid-traceroute-protocol.yml",
+			want:  "error parsing config file: traceroute protocol 'sctp' is not valid",
+		},
+		{
+			input: "testdata/invalid-pmtu-min-mtu.yml",
+			want:  "error parsing config file: \"min_mtu\" must be between 0 and \"max_mtu\"",
+		},
+		{
+			input: "testdata/invalid-icmp-qos-codec.yml",
+			want:  "error parsing config file: codec 'opus' is not valid",
+		},
+		{
+			input: "testdata/invalid-icmp-qos-dscp.yml",
+			want:  "error parsing config file: \"dscp\" must be between 0 and 63",
+		},
+		{
+			input: "testdata/invalid-icmp-batch-rate-limit.yml",
+			want:  "error parsing config file: \"rate_limit\" must be between 1 and 1000000000",
+		},
+		{
+			input: "testdata/invalid-icmp-batch-rate-limit-high.yml",
+			want:  "error parsing config file: \"rate_limit\" must be between 1 and 1000000000",
+		},
+		{
+			input: "testdata/invalid-grpc-method.yml",
+			want:  "error parsing config file: gRPC method 'grpc.health.v1.Health.Check' is not valid, expected \"package.Service/Method\"",
+		},
+		{
+			input: "testdata/invalid-grpc-metadata.yml",
+			want:  "error parsing config file: gRPC metadata cannot set authorization together with a bearer token",
+		},
+		{
+			input: "testdata/invalid-grpc-watch-window.yml",
+			want:  "error parsing config file: gRPC watch_window must be shorter than the module timeout",
+		},
+		{
+			input: "testdata/invalid-composite-reference.yml",
+			want:  "error parsing config file: composite module 'website' references unknown module 'icmp'",
+		},
+		{
+			input: "testdata/invalid-composite-policy.yml",
+			want:  "error parsing config file: composite success policy 'majority' is not valid",
+		},
+		{
+			input: "testdata/invalid-retry-condition.yml",
+			want:  "error parsing config file: retry condition 'dns_error' is not valid",
+		},
+		{
+			input: "testdata/invalid-twamp-count.yml",
+			want:  "error parsing config file: \"count\" must be at least 1",
+		},
+		{
+			input: "testdata/invalid-dns-consistency-transport.yml",
+			want:  "error parsing config file: transport protocol 'sctp' is not valid",
+		},
 		{
 			input: "testdata/invalid-http-header-match.yml",
 			want:  "error parsing config file: regexp must be set for HTTP header matchers",
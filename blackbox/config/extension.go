@@ -0,0 +1,108 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// ExtensionFactory returns a new configuration with defaults set for a
+// prober registered at runtime.
+type ExtensionFactory func() interface{}
+
+var (
+	extensionsMtx sync.RWMutex
+	extensions    = map[string]ExtensionFactory{}
+)
+
+// RegisterExtension makes the configuration of a prober known to the
+// configuration loader. It is called by prober.RegisterProber and has to
+// happen before the configuration is loaded.
+func RegisterExtension(prober string, factory ExtensionFactory) {
+	extensionsMtx.Lock()
+	defer extensionsMtx.Unlock()
+	extensions[prober] = factory
+}
+
+func lookupExtension(prober string) (ExtensionFactory, bool) {
+	extensionsMtx.RLock()
+	defer extensionsMtx.RUnlock()
+	factory, ok := extensions[prober]
+	return factory, ok
+}
+
+// ExtensionConfig holds the configuration of a prober registered at
+// runtime, read from the "extension" block of a module. Config is the value
+// returned by the factory of the prober, decoded and validated once the
+// whole module has been read.
+type ExtensionConfig struct {
+	Config interface{}
+	node   *yaml.Node
+}
+
+// UnmarshalYAML implements the yaml.Unmarshaler interface. Decoding is
+// deferred until the prober of the module is known.
+func (e *ExtensionConfig) UnmarshalYAML(node *yaml.Node) error {
+	e.node = node
+	return nil
+}
+
+// MarshalYAML implements the yaml.Marshaler interface.
+func (e ExtensionConfig) MarshalYAML() (interface{}, error) {
+	return e.Config, nil
+}
+
+// IsZero reports whether there is no extension configuration, so that it
+// is omitted when marshalling.
+func (e ExtensionConfig) IsZero() bool {
+	return e.Config == nil && e.node == nil
+}
+
+// decode decodes the extension block for the given prober. Unknown fields
+// are rejected and configurations implementing Validate() error are
+// validated.
+func (e *ExtensionConfig) decode(prober string) error {
+	factory, ok := lookupExtension(prober)
+	if !ok || factory == nil {
+		if e.node != nil {
+			return fmt.Errorf("prober %q does not take an extension configuration", prober)
+		}
+		return nil
+	}
+
+	cfg := factory()
+	if e.node != nil {
+		b, err := yaml.Marshal(e.node)
+		if err != nil {
+			return err
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(b))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil {
+			return fmt.Errorf("error decoding extension configuration of prober %q: %w", prober, err)
+		}
+		e.node = nil
+	}
+	if v, ok := cfg.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid extension configuration of prober %q: %w", prober, err)
+		}
+	}
+	e.Config = cfg
+	return nil
+}
@@ -0,0 +1,308 @@
+package blackbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+	promCfg "github.com/prometheus/common/config"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+	proto "github.com/abialemuel/prometheus-exporter/messages"
+)
+
+// ProbeOverrides change the module of a single call. Zero values keep the
+// configured settings. The module is copied before it is changed, so
+// neither the configured module nor the overrides are modified by a call.
+type ProbeOverrides struct {
+	HTTP *HTTPOverrides
+	TCP  *TCPOverrides
+	DNS  *DNSOverrides
+	ICMP *ICMPOverrides
+	GRPC *GRPCOverrides
+	// Timeout replaces the timeout of the probe.
+	Timeout time.Duration
+}
+
+// HTTPOverrides change the request of http modules.
+type HTTPOverrides struct {
+	Method string
+	Body   string
+	// Headers are added to the configured headers, replacing those with the
+	// same name.
+	Headers map[string]string
+	// Username and Password set basic authentication when both are set.
+	Username string
+	Password string
+}
+
+// TCPOverrides change tcp modules.
+type TCPOverrides struct {
+	IPProtocol      string
+	SourceIPAddress string
+	// TLS enables or disables TLS when set.
+	TLS *bool
+	// QueryResponse replaces the configured dialogue.
+	QueryResponse []config.QueryResponse
+}
+
+// DNSOverrides change the query of dns modules.
+type DNSOverrides struct {
+	QueryName        string
+	QueryType        string
+	ClientSubnet     string
+	UDPSize          uint16
+	PaddingBlockSize int
+	// Cookie and NSID enable or disable the EDNS0 options when set.
+	Cookie *bool
+	NSID   *bool
+}
+
+// ICMPOverrides change icmp_qos modules.
+type ICMPOverrides struct {
+	PacketSize int
+	Count      int
+	Interval   time.Duration
+	// Timeout is the time to wait for each reply.
+	Timeout time.Duration
+}
+
+// GRPCOverrides change grpc modules.
+type GRPCOverrides struct {
+	Service   string
+	Method    string
+	Request   string
+	Authority string
+	// TLS enables or disables TLS when set.
+	TLS *bool
+	// Metadata is added to the configured metadata, replacing keys with
+	// the same name.
+	Metadata map[string]string
+}
+
+// OverridesFromProto returns the overrides carried by the probe config of
+// a WorkerProbe. An ICMP QoS config without timeout waits timeoutOffset
+// seconds for each reply.
+func OverridesFromProto(data *proto.WorkerProbe, timeoutOffset float64) (ProbeOverrides, error) {
+	var o ProbeOverrides
+	if web := data.GetWebsite(); web != nil {
+		o.HTTP = &HTTPOverrides{
+			Method:   web.Method,
+			Body:     web.Body,
+			Headers:  maps.Clone(web.Headers),
+			Username: web.GetAuthorization().GetUsername(),
+			Password: web.GetAuthorization().GetPassword(),
+		}
+	}
+	if tcp := data.GetTCP(); tcp != nil {
+		o.TCP = &TCPOverrides{
+			IPProtocol:      tcp.IPProtocol,
+			SourceIPAddress: tcp.SourceIPAddress,
+			TLS:             cloneBool(tcp.TLS),
+		}
+		for _, qr := range tcp.QueryResponse {
+			var expect config.Regexp
+			if qr.Expect != "" {
+				re, err := config.NewRegexp(qr.Expect)
+				if err != nil {
+					return ProbeOverrides{}, fmt.Errorf("invalid tcp config: %w", err)
+				}
+				expect = re
+			}
+			o.TCP.QueryResponse = append(o.TCP.QueryResponse, config.QueryResponse{
+				Expect:   expect,
+				Send:     qr.Send,
+				StartTLS: qr.StartTLS,
+			})
+		}
+	}
+	if d := data.GetDNS(); d != nil {
+		if d.UDPSize < 0 || d.UDPSize > 65535 {
+			return ProbeOverrides{}, fmt.Errorf("invalid dns config: udp size %d is not valid", d.UDPSize)
+		}
+		o.DNS = &DNSOverrides{
+			QueryName:        d.QueryName,
+			QueryType:        d.QueryType,
+			ClientSubnet:     d.ClientSubnet,
+			UDPSize:          uint16(d.UDPSize),
+			PaddingBlockSize: int(d.PaddingBlockSize),
+			Cookie:           cloneBool(d.Cookie),
+			NSID:             cloneBool(d.NSID),
+		}
+	}
+	if icmp := data.GetICMPQOS(); icmp != nil {
+		o.ICMP = &ICMPOverrides{
+			PacketSize: int(icmp.PacketSize),
+			Count:      int(icmp.Count),
+			Interval:   time.Duration(icmp.Interval) * time.Millisecond,
+			Timeout:    time.Duration(icmp.Timeout) * time.Second,
+		}
+		if icmp.Timeout == 0 {
+			o.ICMP.Timeout = time.Duration(timeoutOffset * float64(time.Second))
+		}
+	}
+	if g := data.GetGRPC(); g != nil {
+		o.GRPC = &GRPCOverrides{
+			Service:   g.Service,
+			Method:    g.Method,
+			Request:   g.Request,
+			Authority: g.Authority,
+			TLS:       cloneBool(g.TLS),
+			Metadata:  maps.Clone(g.Metadata),
+		}
+	}
+	return o, nil
+}
+
+// cloneBool copies an optional proto field, so that overrides do not share
+// state with the message.
+func cloneBool(b *bool) *bool {
+	if b == nil {
+		return nil
+	}
+	v := *b
+	return &v
+}
+
+// apply returns a copy of module changed by the overrides. Maps and slices
+// that are changed are copied as well.
+func (o ProbeOverrides) apply(module config.Module) (config.Module, error) {
+	if o.Timeout < 0 {
+		return module, errors.New("timeout cannot be negative")
+	}
+	if o.Timeout > 0 {
+		module.Timeout = o.Timeout
+	}
+
+	if h := o.HTTP; h != nil {
+		if h.Method != "" {
+			module.HTTP.Method = h.Method
+		}
+		if h.Body != "" {
+			module.HTTP.Body = h.Body
+			module.HTTP.BodyFile = ""
+		}
+		if len(h.Headers) > 0 {
+			headers := maps.Clone(module.HTTP.Headers)
+			if headers == nil {
+				headers = make(map[string]string, len(h.Headers))
+			}
+			maps.Copy(headers, h.Headers)
+			module.HTTP.Headers = headers
+		}
+		if h.Username != "" && h.Password != "" {
+			module.HTTP.HTTPClientConfig.BasicAuth = &promCfg.BasicAuth{
+				Username: h.Username,
+				Password: promCfg.Secret(h.Password),
+			}
+		}
+	}
+
+	if t := o.TCP; t != nil {
+		if t.IPProtocol != "" {
+			module.TCP.IPProtocol = t.IPProtocol
+		}
+		if t.SourceIPAddress != "" {
+			module.TCP.SourceIPAddress = t.SourceIPAddress
+		}
+		if t.TLS != nil {
+			module.TCP.TLS = *t.TLS
+		}
+		if len(t.QueryResponse) > 0 {
+			module.TCP.QueryResponse = append([]config.QueryResponse(nil), t.QueryResponse...)
+		}
+	}
+
+	if d := o.DNS; d != nil {
+		if d.QueryName != "" {
+			module.DNS.QueryName = d.QueryName
+		}
+		if d.QueryType != "" {
+			if _, ok := dns.StringToType[d.QueryType]; !ok {
+				return module, fmt.Errorf("invalid dns config: query type '%s' is not valid", d.QueryType)
+			}
+			module.DNS.QueryType = d.QueryType
+		}
+		edns0 := &module.DNS.EDNS0
+		if d.ClientSubnet != "" {
+			edns0.ClientSubnet = d.ClientSubnet
+		}
+		if d.UDPSize != 0 {
+			edns0.UDPSize = d.UDPSize
+		}
+		if d.PaddingBlockSize != 0 {
+			edns0.PaddingBlockSize = d.PaddingBlockSize
+		}
+		if d.Cookie != nil {
+			edns0.Cookie = *d.Cookie
+		}
+		if d.NSID != nil {
+			edns0.NSID = *d.NSID
+		}
+		if err := edns0.Validate(); err != nil {
+			return module, fmt.Errorf("invalid dns config: %w", err)
+		}
+	}
+
+	if i := o.ICMP; i != nil {
+		if i.PacketSize < 0 || i.Count < 0 || i.Interval < 0 || i.Timeout < 0 {
+			return module, errors.New("invalid icmp config: values cannot be negative")
+		}
+		// The icmp_qos prober takes durations in milliseconds.
+		if i.PacketSize != 0 {
+			module.ICMPQOS.PacketSize = i.PacketSize
+		}
+		if i.Count != 0 {
+			module.ICMPQOS.Count = i.Count
+		}
+		if i.Interval != 0 {
+			module.ICMPQOS.Interval = int(i.Interval.Milliseconds())
+		}
+		if i.Timeout != 0 {
+			module.ICMPQOS.Timeout = int(i.Timeout.Milliseconds())
+		}
+	}
+
+	if g := o.GRPC; g != nil {
+		if g.Service != "" {
+			module.GRPC.Service = g.Service
+		}
+		if g.Method != "" {
+			service, method, ok := strings.Cut(strings.TrimPrefix(g.Method, "/"), "/")
+			if !ok || service == "" || method == "" || strings.Contains(method, "/") {
+				return module, fmt.Errorf("invalid grpc config: method '%s' is not valid, expected \"package.Service/Method\"", g.Method)
+			}
+			module.GRPC.Method = g.Method
+		}
+		if g.Request != "" {
+			if !json.Valid([]byte(g.Request)) {
+				return module, errors.New("invalid grpc config: request must be valid JSON")
+			}
+			module.GRPC.Request = g.Request
+		}
+		if g.Authority != "" {
+			module.GRPC.Authority = g.Authority
+		}
+		if g.TLS != nil {
+			module.GRPC.TLS = *g.TLS
+		}
+		if len(g.Metadata) > 0 {
+			for key := range g.Metadata {
+				if key == "" || strings.HasPrefix(key, ":") {
+					return module, fmt.Errorf("invalid grpc config: metadata key '%s' is not valid", key)
+				}
+			}
+			metadata := maps.Clone(module.GRPC.Metadata)
+			if metadata == nil {
+				metadata = make(map[string]string, len(g.Metadata))
+			}
+			maps.Copy(metadata, g.Metadata)
+			module.GRPC.Metadata = metadata
+		}
+	}
+	return module, nil
+}
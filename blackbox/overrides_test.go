@@ -0,0 +1,137 @@
+package blackbox
+
+import (
+	"testing"
+	"time"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+	proto "github.com/abialemuel/prometheus-exporter/messages"
+)
+
+func TestOverridesFromProto(t *testing.T) {
+	icmp := &proto.ICMPQOSConfig{PacketSize: 128, Count: 10, Interval: 20, Timeout: 2}
+	data := &proto.WorkerProbe{ProbeConfig: &proto.WorkerProbe_ICMPQOS{ICMPQOS: icmp}}
+	for i := 0; i < 2; i++ {
+		o, err := OverridesFromProto(data, 0.5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := ICMPOverrides{PacketSize: 128, Count: 10, Interval: 20 * time.Millisecond, Timeout: 2 * time.Second}
+		if *o.ICMP != want {
+			t.Errorf("Call %d: expected %+v, got %+v", i, want, *o.ICMP)
+		}
+	}
+	if icmp.Timeout != 2 {
+		t.Errorf("Expected the proto config to be left unchanged, got timeout %d", icmp.Timeout)
+	}
+
+	icmp.Timeout = 0
+	o, err := OverridesFromProto(data, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.ICMP.Timeout != 500*time.Millisecond {
+		t.Errorf("Expected the timeout offset without a timeout, got %s", o.ICMP.Timeout)
+	}
+
+	data = &proto.WorkerProbe{ProbeConfig: &proto.WorkerProbe_TCP{TCP: &proto.TCPConfig{
+		QueryResponse: []*proto.TCPQueryResponse{{Expect: "^220"}, {Send: "QUIT"}},
+	}}}
+	o, err = OverridesFromProto(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.TCP.QueryResponse) != 2 || !o.TCP.QueryResponse[0].Expect.MatchString("220 ready") || o.TCP.QueryResponse[1].Send != "QUIT" {
+		t.Errorf("Unexpected query responses %+v", o.TCP.QueryResponse)
+	}
+
+	data.GetTCP().QueryResponse[0].Expect = "("
+	if _, err := OverridesFromProto(data, 0); err == nil {
+		t.Errorf("Expected an error for an invalid expect regexp")
+	}
+
+	disable := false
+	data = &proto.WorkerProbe{ProbeConfig: &proto.WorkerProbe_DNS{DNS: &proto.DNSConfig{Cookie: &disable}}}
+	o, err = OverridesFromProto(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.DNS.Cookie == nil || *o.DNS.Cookie || o.DNS.NSID != nil {
+		t.Errorf("Expected cookies to be disabled and NSID to be unset, got %v and %v", o.DNS.Cookie, o.DNS.NSID)
+	}
+}
+
+func TestOverridesApply(t *testing.T) {
+	enable, disable := true, false
+	module := config.DefaultModule
+	module.DNS.EDNS0.Cookie = true
+	module.GRPC.TLS = true
+	module.HTTP.Headers = map[string]string{"Accept": "text/plain", "X-Env": "prod"}
+	module.GRPC.Metadata = map[string]string{"tenant": "a"}
+	module.TCP.QueryResponse = []config.QueryResponse{{Send: "HELO"}}
+
+	o := ProbeOverrides{
+		HTTP: &HTTPOverrides{
+			Method:   "POST",
+			Headers:  map[string]string{"X-Env": "staging"},
+			Username: "user",
+			Password: "secret",
+		},
+		TCP:     &TCPOverrides{TLS: &enable, QueryResponse: []config.QueryResponse{{Send: "PING"}}},
+		DNS:     &DNSOverrides{QueryName: "example.com", QueryType: "AAAA", Cookie: &disable, NSID: &enable},
+		ICMP:    &ICMPOverrides{Count: 5, Timeout: 2 * time.Second},
+		GRPC:    &GRPCOverrides{Method: "pkg.Service/Get", TLS: &disable, Metadata: map[string]string{"tenant": "b"}},
+		Timeout: 3 * time.Second,
+	}
+	got, err := o.apply(module)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.HTTP.Method != "POST" || got.HTTP.Headers["Accept"] != "text/plain" || got.HTTP.Headers["X-Env"] != "staging" {
+		t.Errorf("Unexpected HTTP settings: method %q, headers %v", got.HTTP.Method, got.HTTP.Headers)
+	}
+	if ba := got.HTTP.HTTPClientConfig.BasicAuth; ba == nil || ba.Username != "user" || string(ba.Password) != "secret" {
+		t.Errorf("Unexpected basic auth %+v", ba)
+	}
+	if len(got.TCP.QueryResponse) != 1 || got.TCP.QueryResponse[0].Send != "PING" || !got.TCP.TLS {
+		t.Errorf("Unexpected TCP settings %+v", got.TCP)
+	}
+	if got.DNS.QueryName != "example.com" || got.DNS.QueryType != "AAAA" || got.DNS.EDNS0.Cookie || !got.DNS.EDNS0.NSID {
+		t.Errorf("Unexpected DNS settings %+v", got.DNS)
+	}
+	if got.ICMPQOS.Count != 5 || got.ICMPQOS.Timeout != 2000 || got.ICMPQOS.PacketSize != config.DefaultICMPQoSProbe.PacketSize {
+		t.Errorf("Unexpected ICMP QoS settings %+v", got.ICMPQOS)
+	}
+	if got.GRPC.Method != "pkg.Service/Get" || got.GRPC.TLS || got.GRPC.Metadata["tenant"] != "b" {
+		t.Errorf("Unexpected gRPC settings %+v", got.GRPC)
+	}
+	if got.Timeout != 3*time.Second {
+		t.Errorf("Expected timeout 3s, got %s", got.Timeout)
+	}
+
+	// Neither the module nor the overrides share state with the result.
+	got.HTTP.Headers["X-Env"] = "changed"
+	got.TCP.QueryResponse[0].Send = "changed"
+	got.GRPC.Metadata["tenant"] = "changed"
+	if module.HTTP.Headers["X-Env"] != "prod" || module.GRPC.Metadata["tenant"] != "a" || module.HTTP.HTTPClientConfig.BasicAuth != nil {
+		t.Errorf("The configured module was modified")
+	}
+	if o.HTTP.Headers["X-Env"] != "staging" || o.TCP.QueryResponse[0].Send != "PING" || o.GRPC.Metadata["tenant"] != "b" {
+		t.Errorf("The overrides were modified")
+	}
+
+	for name, o := range map[string]ProbeOverrides{
+		"negative timeout":  {Timeout: -time.Second},
+		"query type":        {DNS: &DNSOverrides{QueryType: "BOGUS"}},
+		"client subnet":     {DNS: &DNSOverrides{ClientSubnet: "not-a-subnet"}},
+		"negative count":    {ICMP: &ICMPOverrides{Count: -1}},
+		"grpc method":       {GRPC: &GRPCOverrides{Method: "Get"}},
+		"grpc request":      {GRPC: &GRPCOverrides{Request: "{"}},
+		"grpc metadata key": {GRPC: &GRPCOverrides{Metadata: map[string]string{":path": "/"}}},
+	} {
+		if _, err := o.apply(module); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
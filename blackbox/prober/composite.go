@@ -0,0 +1,221 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+// compositeResult is the outcome of a sub-module of a composite module.
+type compositeResult struct {
+	module   config.CompositeModule
+	success  bool
+	families []*dto.MetricFamily
+	sl       *scrapeLogger
+}
+
+// runSubModule probes the target with a module of a composite module. The
+// metrics of the sub-module are gathered with a submodule label.
+func runSubModule(ctx context.Context, target string, m config.CompositeModule, module config.Module, logger log.Logger) compositeResult {
+	r := compositeResult{module: m, sl: newScrapeLogger(logger, m.Module, target)}
+	prober, ok := LookupProber(module.Prober)
+	if !ok {
+		level.Error(r.sl).Log("msg", "Unknown prober", "prober", module.Prober)
+		return r
+	}
+	if module.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, module.Timeout)
+		defer cancel()
+	}
+
+	level.Info(r.sl).Log("msg", "Beginning probe", "probe", module.Prober)
+	ctx, span := probeTracer().Start(ctx, "submodule "+m.Module, trace.WithAttributes(
+		attribute.String("probe.module", m.Module),
+		attribute.String("probe.prober", module.Prober),
+	))
+	start := time.Now()
+	registry, success, reason := probeWithRetry(ctx, prober, target, module, r.sl)
+	endProbeSpan(span, success, reason)
+	r.success = success
+	duration := time.Since(start).Seconds()
+
+	probeSuccessGauge := prometheus.NewGauge(probeSuccessGaugeOpts)
+	probeDurationGauge := prometheus.NewGauge(probeDurationGaugeOpts)
+	registry.MustRegister(probeSuccessGauge)
+	registry.MustRegister(probeDurationGauge)
+	probeDurationGauge.Set(duration)
+	if r.success {
+		probeSuccessGauge.Set(1)
+		level.Info(r.sl).Log("msg", "Probe succeeded", "duration_seconds", duration)
+	} else {
+		level.Error(r.sl).Log("msg", "Probe failed", "duration_seconds", duration)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		level.Error(r.sl).Log("msg", "Error gathering metrics", "err", err)
+	}
+	label := &dto.LabelPair{Name: proto.String("submodule"), Value: proto.String(m.Module)}
+	for _, mf := range families {
+		for _, metric := range mf.Metric {
+			metric.Label = append(metric.Label, label)
+			sort.Slice(metric.Label, func(i, j int) bool { return metric.Label[i].GetName() < metric.Label[j].GetName() })
+		}
+	}
+	r.families = families
+	return r
+}
+
+// compositeSuccess applies the success policy to the results of the
+// sub-modules and returns the score it is based on.
+func compositeSuccess(probe config.CompositeProbe, results []compositeResult) (bool, float64) {
+	var succeeded, weight, total float64
+	for _, r := range results {
+		w := r.module.Weight
+		if w == 0 {
+			w = 1
+		}
+		total += w
+		if r.success {
+			succeeded++
+			weight += w
+		}
+	}
+	n := float64(len(results))
+	switch probe.SuccessPolicy {
+	case "any":
+		return succeeded > 0, succeeded / n
+	case "quorum":
+		quorum := float64(probe.Quorum)
+		if quorum == 0 {
+			quorum = float64(len(results)/2 + 1)
+		}
+		return succeeded >= quorum, succeeded / n
+	case "weighted":
+		return total > 0 && weight/total >= probe.Threshold, weight / total
+	default:
+		return succeeded == n, succeeded / n
+	}
+}
+
+// probeComposite runs the sub-modules of a composite module. The logs of
+// each sub-module are appended to the logs of the composite module once
+// all of them finished.
+func probeComposite(ctx context.Context, target string, probe config.CompositeProbe, c *config.Config, registry *prometheus.Registry, sl *scrapeLogger, logger log.Logger) bool {
+	var (
+		submodulesGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_composite_submodules",
+			Help: "Returns the number of modules run by the composite module",
+		})
+
+		succeededGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_composite_submodules_succeeded",
+			Help: "Returns the number of modules that succeeded",
+		})
+
+		scoreGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_composite_score",
+			Help: "Returns the share of modules, or of their weight for the weighted policy, that succeeded",
+		})
+	)
+
+	registry.MustRegister(submodulesGauge)
+	registry.MustRegister(succeededGauge)
+	registry.MustRegister(scoreGauge)
+
+	results := make([]compositeResult, len(probe.Modules))
+	run := func(i int) {
+		m := probe.Modules[i]
+		results[i] = runSubModule(ctx, target, m, c.Modules[m.Module], logger)
+	}
+	if probe.Mode == "sequence" {
+		for i := range probe.Modules {
+			run(i)
+		}
+	} else {
+		var wg sync.WaitGroup
+		for i := range probe.Modules {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				run(i)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	var families []*dto.MetricFamily
+	succeeded := 0
+	for _, r := range results {
+		fmt.Fprintf(&sl.buffer, "Logs for module %s:\n", r.module.Module)
+		r.sl.buffer.WriteTo(&sl.buffer)
+		families = append(families, r.families...)
+		if r.success {
+			succeeded++
+		}
+	}
+	registry.MustRegister(familiesCollector(families))
+
+	success, score := compositeSuccess(probe, results)
+	submodulesGauge.Set(float64(len(results)))
+	succeededGauge.Set(float64(succeeded))
+	scoreGauge.Set(score)
+	level.Info(sl).Log("msg", "Composite probe finished", "policy", probe.SuccessPolicy, "succeeded", succeeded, "modules", len(results), "score", score)
+	return success
+}
+
+// familiesCollector exposes already gathered metric families. It is an
+// unchecked collector, metrics of the same name are merged by the registry.
+type familiesCollector []*dto.MetricFamily
+
+// Describe implements prometheus.Collector.
+func (c familiesCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c familiesCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, mf := range c {
+		desc := prometheus.NewDesc(mf.GetName(), mf.GetHelp(), nil, nil)
+		for _, m := range mf.Metric {
+			ch <- gatheredMetric{desc: desc, metric: m}
+		}
+	}
+}
+
+type gatheredMetric struct {
+	desc   *prometheus.Desc
+	metric *dto.Metric
+}
+
+func (m gatheredMetric) Desc() *prometheus.Desc { return m.desc }
+
+func (m gatheredMetric) Write(out *dto.Metric) error {
+	proto.Reset(out)
+	proto.Merge(out, m.metric)
+	return nil
+}
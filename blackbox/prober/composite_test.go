@@ -0,0 +1,167 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+func TestCompositeSuccess(t *testing.T) {
+	results := []compositeResult{
+		{module: config.CompositeModule{Module: "a", Weight: 3}, success: true},
+		{module: config.CompositeModule{Module: "b"}, success: false},
+		{module: config.CompositeModule{Module: "c"}, success: true},
+	}
+	for _, tc := range []struct {
+		probe   config.CompositeProbe
+		success bool
+		score   float64
+	}{
+		{config.CompositeProbe{SuccessPolicy: "all"}, false, 2.0 / 3},
+		{config.CompositeProbe{SuccessPolicy: "any"}, true, 2.0 / 3},
+		{config.CompositeProbe{SuccessPolicy: "quorum"}, true, 2.0 / 3},
+		{config.CompositeProbe{SuccessPolicy: "quorum", Quorum: 3}, false, 2.0 / 3},
+		{config.CompositeProbe{SuccessPolicy: "weighted", Threshold: 0.8}, true, 0.8},
+		{config.CompositeProbe{SuccessPolicy: "weighted", Threshold: 0.9}, false, 0.8},
+	} {
+		success, score := compositeSuccess(tc.probe, results)
+		if success != tc.success || score != tc.score {
+			t.Errorf("Policy %+v: expected %v with score %v, got %v with score %v", tc.probe, tc.success, tc.score, success, score)
+		}
+	}
+}
+
+func TestCallComposite(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	for _, mode := range []string{"parallel", "sequence"} {
+		t.Run(mode, func(t *testing.T) {
+			c := &config.Config{Modules: map[string]config.Module{
+				"ok": {
+					Prober:  "http",
+					Timeout: time.Second,
+					HTTP:    config.HTTPProbe{IPProtocolFallback: true},
+				},
+				"bad": {
+					Prober:  "http",
+					Timeout: time.Second,
+					HTTP:    config.HTTPProbe{IPProtocolFallback: true, ValidStatusCodes: []int{404}},
+				},
+				"website": {
+					Prober:  "composite",
+					Timeout: 5 * time.Second,
+					Composite: config.CompositeProbe{
+						Mode:          mode,
+						SuccessPolicy: "weighted",
+						Threshold:     0.7,
+						Modules: []config.CompositeModule{
+							{Module: "ok", Weight: 3},
+							{Module: "bad"},
+						},
+					},
+				},
+			}}
+			rh := &ResultHistory{MaxResults: 1}
+			result, err := Call(ts.URL, "website", c, log.NewNopLogger(), rh, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Success() {
+				t.Errorf("Expected weighted composite probe to succeed")
+			}
+			text, err := result.Text()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range []string{
+				`probe_success{submodule="ok"} 1`,
+				`probe_success{submodule="bad"} 0`,
+				`probe_http_status_code{submodule="bad"} 200`,
+				"probe_composite_score 0.75",
+				"probe_composite_submodules_succeeded 1",
+				"probe_success 1",
+			} {
+				if !strings.Contains(string(text), want) {
+					t.Errorf("Expected %q in metrics, got\n%s", want, text)
+				}
+			}
+			debug := rh.List()[0].DebugOutput
+			if !strings.Contains(debug, "Logs for module ok:") || !strings.Contains(debug, "Logs for module bad:") {
+				t.Errorf("Expected logs of each module in debug output, got\n%s", debug)
+			}
+		})
+	}
+}
+
+func TestCallCompositeDNS(t *testing.T) {
+	server, addr := startDNSServer("udp", zoneDNSHandler(2024010101, "192.0.2.1"))
+	defer server.Shutdown()
+
+	// Both probers report serials, their metrics are merged into one
+	// registry.
+	c := &config.Config{Modules: map[string]config.Module{
+		"soa": {
+			Prober:  "dns",
+			Timeout: time.Second,
+			DNS: config.DNSProbe{
+				IPProtocol:         "ip4",
+				IPProtocolFallback: true,
+				QueryName:          "example.com",
+				QueryType:          "SOA",
+			},
+		},
+		"consistency": {
+			Prober:  "dns_consistency",
+			Timeout: time.Second,
+			DNSConsistency: config.DNSConsistencyProbe{
+				IPProtocol:         "ip4",
+				IPProtocolFallback: true,
+				Nameservers:        []string{addr.String()},
+			},
+		},
+		"zone": {
+			Prober:  "composite",
+			Timeout: 5 * time.Second,
+			Composite: config.CompositeProbe{
+				Modules: []config.CompositeModule{{Module: "soa"}, {Module: "consistency"}},
+			},
+		},
+	}}
+	result, err := Call(addr.String(), "zone", c, log.NewNopLogger(), &ResultHistory{MaxResults: 1}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := result.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`probe_dns_serial{submodule="soa"} 2.024010101e+09`,
+		`probe_dns_consistency_serial{server="` + addr.String() + `",submodule="consistency"} 2.024010101e+09`,
+		"probe_success 1",
+	} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("Expected %q in metrics, got\n%s", want, text)
+		}
+	}
+}
@@ -15,6 +15,7 @@ package prober
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"regexp"
 	"time"
@@ -24,6 +25,7 @@ import (
 	"github.com/miekg/dns"
 	"github.com/prometheus/client_golang/prometheus"
 	pconfig "github.com/prometheus/common/config"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/abialemuel/prometheus-exporter/blackbox/config"
 )
@@ -124,6 +126,134 @@ func validRcode(rcode int, valid []string, logger log.Logger) bool {
 	return false
 }
 
+// rrTTLRange returns the lowest and highest TTL of the RRs, ignoring the
+// EDNS0 OPT pseudo-RR whose TTL field carries flags instead of a TTL.
+func rrTTLRange(rrs []dns.RR) (min, max uint32, ok bool) {
+	for _, rr := range rrs {
+		if rr.Header().Rrtype == dns.TypeOPT {
+			continue
+		}
+		ttl := rr.Header().Ttl
+		if !ok || ttl < min {
+			min = ttl
+		}
+		if !ok || ttl > max {
+			max = ttl
+		}
+		ok = true
+	}
+	return min, max, ok
+}
+
+// answerInfoValue returns the value exported in probe_dns_answer_info for the
+// record types it supports.
+func answerInfoValue(rr dns.RR) (string, bool) {
+	switch v := rr.(type) {
+	case *dns.A:
+		return v.A.String(), true
+	case *dns.AAAA:
+		return v.AAAA.String(), true
+	case *dns.CNAME:
+		return v.Target, true
+	case *dns.MX:
+		return fmt.Sprintf("%d %s", v.Preference, v.Mx), true
+	}
+	return "", false
+}
+
+// exportDNSResponseMetrics registers the optional metrics describing the
+// content of a DNS response of size bytes.
+func exportDNSResponseMetrics(response *dns.Msg, size int, probe config.DNSProbe, registry *prometheus.Registry) {
+	if probe.ExportTTLs {
+		ttlGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_dns_ttl_seconds",
+			Help: "Returns the lowest and highest TTL of the records in each section",
+		}, []string{"section", "aggregate"})
+		registry.MustRegister(ttlGaugeVec)
+		for section, rrs := range map[string][]dns.RR{
+			"answer":     response.Answer,
+			"authority":  response.Ns,
+			"additional": response.Extra,
+		} {
+			if min, max, ok := rrTTLRange(rrs); ok {
+				ttlGaugeVec.WithLabelValues(section, "min").Set(float64(min))
+				ttlGaugeVec.WithLabelValues(section, "max").Set(float64(max))
+			}
+		}
+	}
+
+	if probe.ExportAnswerInfo {
+		answerInfoGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_dns_answer_info",
+			Help: "Contains the values of the A, AAAA, CNAME and MX records in the answer section",
+		}, []string{"type", "value"})
+		registry.MustRegister(answerInfoGaugeVec)
+		for _, rr := range response.Answer {
+			if value, ok := answerInfoValue(rr); ok {
+				answerInfoGaugeVec.WithLabelValues(dns.TypeToString[rr.Header().Rrtype], value).Set(1)
+			}
+		}
+	}
+
+	if probe.ExportResponseInfo {
+		responseSizeGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_response_size_bytes",
+			Help: "Returns the size of the DNS response as received in bytes",
+		})
+		truncatedGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_truncated",
+			Help: "Displays whether or not the response had the TC flag set",
+		})
+		edns0UDPSizeGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_edns0_udp_size_bytes",
+			Help: "Returns the EDNS0 UDP buffer size advertised in the response, 0 without EDNS0",
+		})
+		registry.MustRegister(responseSizeGauge)
+		registry.MustRegister(truncatedGauge)
+		registry.MustRegister(edns0UDPSizeGauge)
+
+		responseSizeGauge.Set(float64(size))
+		if response.Truncated {
+			truncatedGauge.Set(1)
+		}
+		if opt := response.IsEdns0(); opt != nil {
+			edns0UDPSizeGauge.Set(float64(opt.UDPSize()))
+		}
+	}
+}
+
+// exchangeDNS sends msg over conn and returns the response and its size in
+// bytes as received. Like dns.Client.Exchange, it skips UDP responses with
+// another ID, which may answer earlier queries that timed out.
+func exchangeDNS(conn *dns.Conn, msg *dns.Msg, deadline time.Time) (*dns.Msg, int, error) {
+	if opt := msg.IsEdns0(); opt != nil && opt.UDPSize() >= dns.MinMsgSize {
+		conn.UDPSize = opt.UDPSize()
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, 0, err
+	}
+	if err := conn.WriteMsg(msg); err != nil {
+		return nil, 0, err
+	}
+	_, isUDP := conn.Conn.(net.PacketConn)
+	for {
+		p, err := conn.ReadMsgHeader(nil)
+		if err != nil {
+			return nil, 0, err
+		}
+		response := new(dns.Msg)
+		if err := response.Unpack(p); err != nil {
+			return nil, 0, err
+		}
+		if response.Id == msg.Id {
+			return response, len(p), nil
+		}
+		if !isUDP {
+			return nil, 0, dns.ErrId
+		}
+	}
+}
+
 func ProbeDNS(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger log.Logger) bool {
 	var dialProtocol string
 	probeDNSDurationGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
@@ -258,18 +388,33 @@ func ProbeDNS(ctx context.Context, target string, module config.Module, registry
 	msg.RecursionDesired = module.DNS.Recursion
 	msg.Question = make([]dns.Question, 1)
 	msg.Question[0] = dns.Question{dns.Fqdn(module.DNS.QueryName), qt, qc}
+	if err := setEDNS0(msg, module.DNS.EDNS0); err != nil {
+		level.Error(logger).Log("msg", "Error setting EDNS0 options", "err", err)
+		return false
+	}
 
 	level.Info(logger).Log("msg", "Making DNS query", "target", targetIP, "dial_protocol", dialProtocol, "query", module.DNS.QueryName, "type", qt, "class", qc)
 	timeoutDeadline, _ := ctx.Deadline()
 	client.Timeout = time.Until(timeoutDeadline)
 	requestStart := time.Now()
-	response, rtt, err := client.Exchange(msg, targetIP)
-	// The rtt value returned from client.Exchange includes only the time to
-	// exchange messages with the server _after_ the connection is created.
-	// We compute the connection time as the total time for the operation
-	// minus the time for the actual request rtt.
-	probeDNSDurationGaugeVec.WithLabelValues("connect").Set((time.Since(requestStart) - rtt).Seconds())
-	probeDNSDurationGaugeVec.WithLabelValues("request").Set(rtt.Seconds())
+	// The connection is made separately from the exchange to time both and
+	// to get the size of the response as received.
+	var (
+		response *dns.Msg
+		size     int
+	)
+	conn, err := client.Dial(targetIP)
+	connected := time.Now()
+	if err == nil {
+		response, size, err = exchangeDNS(conn, msg, timeoutDeadline)
+		conn.Close()
+	}
+	requestEnd := time.Now()
+	probeDNSDurationGaugeVec.WithLabelValues("connect").Set(connected.Sub(requestStart).Seconds())
+	probeDNSDurationGaugeVec.WithLabelValues("request").Set(requestEnd.Sub(connected).Seconds())
+	recordPhase(ctx, "connect", requestStart, connected, nil)
+	recordPhase(ctx, "request", connected, requestEnd, err,
+		attribute.String("dns.query", module.DNS.QueryName), attribute.String("dns.transport", dialProtocol))
 	if err != nil {
 		level.Error(logger).Log("msg", "Error while sending a DNS query", "err", err)
 		return false
@@ -280,6 +425,8 @@ func ProbeDNS(ctx context.Context, target string, module config.Module, registry
 	probeDNSAuthorityRRSGauge.Set(float64(len(response.Ns)))
 	probeDNSAdditionalRRSGauge.Set(float64(len(response.Extra)))
 	probeDNSQuerySucceeded.Set(1)
+	exportDNSResponseMetrics(response, size, module.DNS, registry)
+	exportEDNS0Metrics(response, module.DNS.EDNS0, registry)
 
 	if qt == dns.TypeSOA {
 		probeDNSSOAGauge = prometheus.NewGauge(prometheus.GaugeOpts{
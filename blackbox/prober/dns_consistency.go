@@ -0,0 +1,328 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+// nameserverResult holds what a single nameserver returned during a
+// consistency probe.
+type nameserverResult struct {
+	server    string
+	addr      string
+	serial    uint32
+	hasSerial bool
+	answers   []string
+	duration  time.Duration
+	err       error
+}
+
+// canonicalRRs returns the RRs as sorted strings with their TTL cleared, so
+// that answer sets from different servers can be compared.
+func canonicalRRs(rrs []dns.RR) []string {
+	result := make([]string, 0, len(rrs))
+	for _, rr := range rrs {
+		c := dns.Copy(rr)
+		c.Header().Ttl = 0
+		result = append(result, c.String())
+	}
+	sort.Strings(result)
+	return result
+}
+
+// resolveNameserver returns the address to query for a nameserver given as
+// host or host:port, honouring the preferred IP protocol.
+func resolveNameserver(ctx context.Context, server string, IPProtocol string, fallbackIPProtocol bool) (string, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		host, port = server, "53"
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return net.JoinHostPort(ip.String(), port), nil
+	}
+
+	if IPProtocol == "" {
+		IPProtocol = "ip6"
+	}
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return "", err
+	}
+	var fallback net.IP
+	for _, ip := range ips {
+		isIPv4 := ip.IP.To4() != nil
+		if (IPProtocol == "ip4") == isIPv4 {
+			return net.JoinHostPort(ip.IP.String(), port), nil
+		}
+		if fallback == nil {
+			fallback = ip.IP
+		}
+	}
+	if fallback == nil || !fallbackIPProtocol {
+		return "", fmt.Errorf("unable to find ip for %s; no fallback", host)
+	}
+	return net.JoinHostPort(fallback.String(), port), nil
+}
+
+// queryNameserver sends the SOA query for the zone and, if it differs, the
+// configured query to a single nameserver.
+func queryNameserver(ctx context.Context, server string, zone string, question dns.Question, probe config.DNSConsistencyProbe, srcIP net.IP) (result nameserverResult) {
+	result.server = server
+	start := time.Now()
+	defer func() {
+		result.duration = time.Since(start)
+	}()
+
+	addr, err := resolveNameserver(ctx, server, probe.IPProtocol, probe.IPProtocolFallback)
+	if err != nil {
+		result.err = fmt.Errorf("error resolving nameserver: %w", err)
+		return
+	}
+	result.addr = addr
+
+	host, _, _ := net.SplitHostPort(addr)
+	client := new(dns.Client)
+	if net.ParseIP(host).To4() == nil {
+		client.Net = probe.TransportProtocol + "6"
+	} else {
+		client.Net = probe.TransportProtocol + "4"
+	}
+	if srcIP != nil {
+		client.Dialer = &net.Dialer{}
+		if probe.TransportProtocol == "tcp" {
+			client.Dialer.LocalAddr = &net.TCPAddr{IP: srcIP}
+		} else {
+			client.Dialer.LocalAddr = &net.UDPAddr{IP: srcIP}
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		client.Timeout = time.Until(deadline)
+	}
+
+	exchange := func(q dns.Question) (*dns.Msg, error) {
+		msg := new(dns.Msg)
+		msg.Id = dns.Id()
+		msg.Question = []dns.Question{q}
+		response, _, err := client.ExchangeContext(ctx, msg, addr)
+		if err != nil {
+			return nil, err
+		}
+		if response.Rcode != dns.RcodeSuccess {
+			return nil, fmt.Errorf("unexpected rcode %s", dns.RcodeToString[response.Rcode])
+		}
+		return response, nil
+	}
+
+	soaQuestion := dns.Question{Name: zone, Qtype: dns.TypeSOA, Qclass: question.Qclass}
+	response, err := exchange(soaQuestion)
+	if err != nil {
+		result.err = fmt.Errorf("error querying SOA: %w", err)
+		return
+	}
+	for _, rr := range response.Answer {
+		if soa, ok := rr.(*dns.SOA); ok {
+			result.serial = soa.Serial
+			result.hasSerial = true
+		}
+	}
+
+	if question != soaQuestion {
+		response, err = exchange(question)
+		if err != nil {
+			result.err = fmt.Errorf("error querying %s: %w", dns.TypeToString[question.Qtype], err)
+			return
+		}
+	}
+	result.answers = canonicalRRs(response.Answer)
+	return
+}
+
+func ProbeDNSConsistency(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger log.Logger) bool {
+	var (
+		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_dns_duration_seconds",
+			Help: "Duration of DNS request by phase",
+		}, []string{"phase"})
+
+		nameserversGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_nameservers",
+			Help: "Returns the number of nameservers queried",
+		})
+
+		serialGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_dns_consistency_serial",
+			Help: "Returns the serial number of the zone as seen by each nameserver",
+		}, []string{"server"})
+
+		serverDurationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_dns_server_duration_seconds",
+			Help: "Returns how long the queries to each nameserver took in seconds",
+		}, []string{"server"})
+
+		serverSucceededGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_dns_server_query_succeeded",
+			Help: "Displays whether or not the queries to each nameserver succeeded",
+		}, []string{"server"})
+
+		consistentGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_consistent",
+			Help: "Displays whether or not all nameservers returned the same serial and answers",
+		})
+	)
+
+	registry.MustRegister(durationGaugeVec)
+	registry.MustRegister(nameserversGauge)
+	registry.MustRegister(serialGaugeVec)
+	registry.MustRegister(serverDurationGaugeVec)
+	registry.MustRegister(serverSucceededGaugeVec)
+	registry.MustRegister(consistentGauge)
+
+	probe := module.DNSConsistency
+	if probe.TransportProtocol == "" {
+		probe.TransportProtocol = "udp"
+	}
+	if !(probe.TransportProtocol == "udp" || probe.TransportProtocol == "tcp") {
+		level.Error(logger).Log("msg", "Configuration error: Expected transport protocol udp or tcp", "protocol", probe.TransportProtocol)
+		return false
+	}
+
+	qc := uint16(dns.ClassINET)
+	if probe.QueryClass != "" {
+		var ok bool
+		qc, ok = dns.StringToClass[probe.QueryClass]
+		if !ok {
+			level.Error(logger).Log("msg", "Invalid query class", "Class seen", probe.QueryClass, "Existing classes", dns.ClassToString)
+			return false
+		}
+	}
+
+	qt := dns.TypeSOA
+	if probe.QueryType != "" {
+		var ok bool
+		qt, ok = dns.StringToType[probe.QueryType]
+		if !ok {
+			level.Error(logger).Log("msg", "Invalid query type", "Type seen", probe.QueryType, "Existing types", dns.TypeToString)
+			return false
+		}
+	}
+
+	var srcIP net.IP
+	if len(probe.SourceIPAddress) > 0 {
+		if srcIP = net.ParseIP(probe.SourceIPAddress); srcIP == nil {
+			level.Error(logger).Log("msg", "Error parsing source ip address", "srcIP", probe.SourceIPAddress)
+			return false
+		}
+		level.Info(logger).Log("msg", "Using local address", "srcIP", srcIP)
+	}
+
+	zone := dns.Fqdn(target)
+	queryName := zone
+	if probe.QueryName != "" {
+		queryName = dns.Fqdn(probe.QueryName)
+	}
+	question := dns.Question{Name: queryName, Qtype: qt, Qclass: qc}
+
+	servers := probe.Nameservers
+	if len(servers) == 0 {
+		level.Info(logger).Log("msg", "Discovering nameservers", "zone", zone)
+		discoverStart := time.Now()
+		nss, err := net.DefaultResolver.LookupNS(ctx, zone)
+		durationGaugeVec.WithLabelValues("discover").Set(time.Since(discoverStart).Seconds())
+		if err != nil {
+			level.Error(logger).Log("msg", "Error looking up nameservers", "zone", zone, "err", err)
+			return false
+		}
+		for _, ns := range nss {
+			servers = append(servers, strings.TrimSuffix(ns.Host, "."))
+		}
+		level.Info(logger).Log("msg", "Discovered nameservers", "zone", zone, "nameservers", strings.Join(servers, ","))
+	}
+	if len(servers) == 0 {
+		level.Error(logger).Log("msg", "No nameservers to query", "zone", zone)
+		return false
+	}
+	nameserversGauge.Set(float64(len(servers)))
+
+	level.Info(logger).Log("msg", "Querying nameservers", "zone", zone, "query", queryName, "type", qt, "class", qc)
+	requestStart := time.Now()
+	results := make([]nameserverResult, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func(i int, server string) {
+			defer wg.Done()
+			results[i] = queryNameserver(ctx, server, zone, question, probe, srcIP)
+		}(i, server)
+	}
+	wg.Wait()
+	durationGaugeVec.WithLabelValues("request").Set(time.Since(requestStart).Seconds())
+
+	success := true
+	consistent := true
+	var reference *nameserverResult
+	for i := range results {
+		r := &results[i]
+		serverDurationGaugeVec.WithLabelValues(r.server).Set(r.duration.Seconds())
+		if r.err != nil {
+			level.Error(logger).Log("msg", "Nameserver query failed", "server", r.server, "addr", r.addr, "err", r.err)
+			serverSucceededGaugeVec.WithLabelValues(r.server).Set(0)
+			success = false
+			continue
+		}
+		serverSucceededGaugeVec.WithLabelValues(r.server).Set(1)
+		if r.hasSerial {
+			serialGaugeVec.WithLabelValues(r.server).Set(float64(r.serial))
+		}
+		level.Info(logger).Log("msg", "Got response", "server", r.server, "addr", r.addr, "serial", r.serial, "answers", len(r.answers))
+
+		if reference == nil {
+			reference = r
+			continue
+		}
+		if r.serial != reference.serial || r.hasSerial != reference.hasSerial {
+			level.Warn(logger).Log("msg", "Serial mismatch", "server", r.server, "serial", r.serial, "reference_server", reference.server, "reference_serial", reference.serial)
+			consistent = false
+		}
+		if strings.Join(r.answers, "\n") != strings.Join(reference.answers, "\n") {
+			level.Warn(logger).Log("msg", "Answer mismatch", "server", r.server, "reference_server", reference.server)
+			consistent = false
+		}
+	}
+
+	if reference == nil {
+		level.Error(logger).Log("msg", "No nameserver returned a response")
+		return false
+	}
+	if consistent {
+		consistentGauge.Set(1)
+	} else if probe.FailIfInconsistent {
+		level.Error(logger).Log("msg", "Nameservers returned inconsistent answers")
+		success = false
+	}
+	return success
+}
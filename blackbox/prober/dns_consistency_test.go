@@ -0,0 +1,204 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+// zoneDNSHandler returns a handler answering authoritatively for example.com
+// with the given SOA serial and A record.
+func zoneDNSHandler(serial uint32, address string) func(dns.ResponseWriter, *dns.Msg) {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		m.Authoritative = true
+		var rr string
+		switch r.Question[0].Qtype {
+		case dns.TypeSOA:
+			rr = fmt.Sprintf("example.com. 3600 IN SOA ns1.example.com. hostmaster.example.com. %d 3600 600 86400 300", serial)
+		case dns.TypeA:
+			rr = "www.example.com. 300 IN A " + address
+		}
+		if rr != "" {
+			a, err := dns.NewRR(rr)
+			if err != nil {
+				panic(err)
+			}
+			m.Answer = append(m.Answer, a)
+		}
+		if err := w.WriteMsg(m); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestDNSConsistency(t *testing.T) {
+	tests := []struct {
+		name               string
+		handlers           []func(dns.ResponseWriter, *dns.Msg)
+		queryType          string
+		failIfInconsistent bool
+		shouldSucceed      bool
+		consistent         float64
+	}{
+		{
+			name:               "same serial",
+			handlers:           []func(dns.ResponseWriter, *dns.Msg){zoneDNSHandler(2024010101, "192.0.2.1"), zoneDNSHandler(2024010101, "192.0.2.1")},
+			failIfInconsistent: true,
+			shouldSucceed:      true,
+			consistent:         1,
+		},
+		{
+			name:               "stale secondary",
+			handlers:           []func(dns.ResponseWriter, *dns.Msg){zoneDNSHandler(2024010102, "192.0.2.1"), zoneDNSHandler(2024010101, "192.0.2.1")},
+			failIfInconsistent: true,
+			shouldSucceed:      false,
+			consistent:         0,
+		},
+		{
+			name:               "stale secondary without failing",
+			handlers:           []func(dns.ResponseWriter, *dns.Msg){zoneDNSHandler(2024010102, "192.0.2.1"), zoneDNSHandler(2024010101, "192.0.2.1")},
+			failIfInconsistent: false,
+			shouldSucceed:      true,
+			consistent:         0,
+		},
+		{
+			name:               "different answers",
+			handlers:           []func(dns.ResponseWriter, *dns.Msg){zoneDNSHandler(2024010101, "192.0.2.1"), zoneDNSHandler(2024010101, "192.0.2.2")},
+			queryType:          "A",
+			failIfInconsistent: true,
+			shouldSucceed:      false,
+			consistent:         0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var servers []string
+			for _, h := range test.handlers {
+				server, addr := startDNSServer("udp", h)
+				defer server.Shutdown()
+				servers = append(servers, addr.String())
+			}
+
+			module := config.Module{
+				Timeout: time.Second,
+				DNSConsistency: config.DNSConsistencyProbe{
+					IPProtocol:         "ip4",
+					IPProtocolFallback: true,
+					Nameservers:        servers,
+					QueryType:          test.queryType,
+					FailIfInconsistent: test.failIfInconsistent,
+				},
+			}
+			if test.queryType != "" {
+				module.DNSConsistency.QueryName = "www.example.com"
+			}
+
+			registry := prometheus.NewRegistry()
+			testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			result := ProbeDNSConsistency(testCTX, "example.com", module, registry, log.NewNopLogger())
+			if result != test.shouldSucceed {
+				t.Fatalf("Test %q had unexpected result: %v", test.name, result)
+			}
+			mfs, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRegistryResults(map[string]float64{
+				"probe_dns_consistent":  test.consistent,
+				"probe_dns_nameservers": float64(len(servers)),
+			}, mfs, t)
+		})
+	}
+}
+
+func TestDNSConsistencyMetrics(t *testing.T) {
+	primary, primaryAddr := startDNSServer("udp", zoneDNSHandler(2024010102, "192.0.2.1"))
+	defer primary.Shutdown()
+	secondary, secondaryAddr := startDNSServer("udp", zoneDNSHandler(2024010101, "192.0.2.1"))
+	defer secondary.Shutdown()
+
+	module := config.Module{
+		Timeout: time.Second,
+		DNSConsistency: config.DNSConsistencyProbe{
+			IPProtocol:         "ip4",
+			IPProtocolFallback: true,
+			Nameservers:        []string{primaryAddr.String(), secondaryAddr.String()},
+		},
+	}
+	registry := prometheus.NewRegistry()
+	testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	ProbeDNSConsistency(testCTX, "example.com", module, registry, log.NewNopLogger())
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedMetrics := map[string]map[string]map[string]struct{}{
+		"probe_dns_duration_seconds": {
+			"phase": {
+				"request": {},
+			},
+		},
+		"probe_dns_nameservers": nil,
+		"probe_dns_consistent":  nil,
+		"probe_dns_consistency_serial": {
+			"server": {
+				primaryAddr.String():   {},
+				secondaryAddr.String(): {},
+			},
+		},
+		"probe_dns_server_duration_seconds": {
+			"server": {
+				primaryAddr.String():   {},
+				secondaryAddr.String(): {},
+			},
+		},
+		"probe_dns_server_query_succeeded": {
+			"server": {
+				primaryAddr.String():   {},
+				secondaryAddr.String(): {},
+			},
+		},
+	}
+	checkMetrics(expectedMetrics, mfs, t)
+
+	for _, mf := range mfs {
+		if mf.GetName() != "probe_dns_consistency_serial" {
+			continue
+		}
+		for _, m := range mf.Metric {
+			want := float64(2024010101)
+			if m.GetLabel()[0].GetValue() == primaryAddr.String() {
+				want = 2024010102
+			}
+			if got := m.GetGauge().GetValue(); got != want {
+				t.Errorf("Unexpected serial for %s: got %v, want %v", m.GetLabel()[0].GetValue(), got, want)
+			}
+		}
+	}
+}
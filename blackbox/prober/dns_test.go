@@ -18,6 +18,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -655,3 +656,214 @@ func TestDNSMetrics(t *testing.T) {
 
 	checkMetrics(expectedMetrics, mfs, t)
 }
+
+func recordsDNSHandler(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	for _, rr := range []string{
+		"www.example.com. 300 IN CNAME example.com.",
+		"example.com. 3600 IN A 192.0.2.1",
+		"example.com. 60 IN MX 10 mail.example.com.",
+	} {
+		a, err := dns.NewRR(rr)
+		if err != nil {
+			panic(err)
+		}
+		m.Answer = append(m.Answer, a)
+	}
+	ns, err := dns.NewRR("example.com. 7200 IN NS ns1.example.com.")
+	if err != nil {
+		panic(err)
+	}
+	m.Ns = append(m.Ns, ns)
+	m.SetEdns0(1232, false)
+	m.Compress = true
+	if err := w.WriteMsg(m); err != nil {
+		panic(err)
+	}
+}
+
+// sizeRecorder records the size of the messages written, as sent and
+// without compression.
+type sizeRecorder struct {
+	dns.ResponseWriter
+	sent, uncompressed *atomic.Int64
+}
+
+func (w *sizeRecorder) WriteMsg(m *dns.Msg) error {
+	b, err := m.Pack()
+	if err != nil {
+		return err
+	}
+	w.sent.Store(int64(len(b)))
+	c := m.Copy()
+	c.Compress = false
+	w.uncompressed.Store(int64(c.Len()))
+	_, err = w.Write(b)
+	return err
+}
+
+func TestDNSRecordMetrics(t *testing.T) {
+	// The names of the records repeat, the response is compressed.
+	var compressed, uncompressed atomic.Int64
+	server, addr := startDNSServer("udp", func(w dns.ResponseWriter, r *dns.Msg) {
+		recordsDNSHandler(&sizeRecorder{ResponseWriter: w, sent: &compressed, uncompressed: &uncompressed}, r)
+	})
+	defer server.Shutdown()
+
+	_, port, _ := net.SplitHostPort(addr.String())
+
+	module := config.Module{
+		Timeout: time.Second,
+		DNS: config.DNSProbe{
+			IPProtocol:         "ip4",
+			IPProtocolFallback: true,
+			QueryName:          "www.example.com",
+			Recursion:          true,
+			ExportTTLs:         true,
+			ExportAnswerInfo:   true,
+			ExportResponseInfo: true,
+		},
+	}
+	registry := prometheus.NewRegistry()
+	testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result := ProbeDNS(testCTX, net.JoinHostPort("localhost", port), module, registry, log.NewNopLogger())
+	if !result {
+		t.Fatalf("DNS test connection failed, expected success.")
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedMetrics := map[string]map[string]map[string]struct{}{
+		"probe_dns_ttl_seconds": {
+			"section":   nil,
+			"aggregate": nil,
+		},
+		"probe_dns_answer_info": {
+			"type":  nil,
+			"value": {"192.0.2.1": {}, "example.com.": {}, "10 mail.example.com.": {}},
+		},
+		"probe_dns_response_size_bytes":  nil,
+		"probe_dns_truncated":            nil,
+		"probe_dns_edns0_udp_size_bytes": nil,
+	}
+	checkMetrics(expectedMetrics, mfs, t)
+
+	checkRegistryResults(map[string]float64{
+		"probe_dns_truncated":            0,
+		"probe_dns_edns0_udp_size_bytes": 1232,
+		"probe_dns_response_size_bytes":  float64(compressed.Load()),
+	}, mfs, t)
+	if compressed.Load() >= uncompressed.Load() {
+		t.Errorf("Expected a compressed response, got %d bytes for %d uncompressed", compressed.Load(), uncompressed.Load())
+	}
+
+	for _, mf := range mfs {
+		if mf.GetName() != "probe_dns_ttl_seconds" {
+			continue
+		}
+		for _, m := range mf.Metric {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			want, ok := map[string]float64{
+				"answer/min":    60,
+				"answer/max":    3600,
+				"authority/min": 7200,
+				"authority/max": 7200,
+			}[labels["section"]+"/"+labels["aggregate"]]
+			if !ok {
+				t.Errorf("Unexpected TTL metric %v", labels)
+			} else if got := m.GetGauge().GetValue(); got != want {
+				t.Errorf("Unexpected TTL for %v: got %v, want %v", labels, got, want)
+			}
+		}
+	}
+}
+
+// edns0DNSHandler echoes the EDNS0 options of the query the way an anycast
+// resolver with NSID, ECS and cookie support would.
+func edns0DNSHandler(t *testing.T) func(dns.ResponseWriter, *dns.Msg) {
+	return func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		opt := r.IsEdns0()
+		if opt == nil {
+			t.Errorf("Expected OPT record in query")
+			w.WriteMsg(m)
+			return
+		}
+		if opt.UDPSize() != 1400 {
+			t.Errorf("Unexpected UDP size %d", opt.UDPSize())
+		}
+		if r.Len()%128 != 0 {
+			t.Errorf("Query of %d bytes was not padded to 128 bytes", r.Len())
+		}
+		m.SetEdns0(1232, false)
+		respOpt := m.IsEdns0()
+		for _, o := range opt.Option {
+			switch o := o.(type) {
+			case *dns.EDNS0_NSID:
+				respOpt.Option = append(respOpt.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID, Nsid: "6e73312e616d73"})
+			case *dns.EDNS0_SUBNET:
+				if o.SourceNetmask != 24 || !o.Address.Equal(net.ParseIP("192.0.2.0")) {
+					t.Errorf("Unexpected client subnet %s/%d", o.Address, o.SourceNetmask)
+				}
+				o.SourceScope = 16
+				respOpt.Option = append(respOpt.Option, o)
+			case *dns.EDNS0_COOKIE:
+				respOpt.Option = append(respOpt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: o.Cookie + "0102030405060708"})
+			}
+		}
+		if err := w.WriteMsg(m); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func TestDNSEDNS0(t *testing.T) {
+	server, addr := startDNSServer("udp", edns0DNSHandler(t))
+	defer server.Shutdown()
+
+	_, port, _ := net.SplitHostPort(addr.String())
+
+	module := config.Module{
+		Timeout: time.Second,
+		DNS: config.DNSProbe{
+			IPProtocol:         "ip4",
+			IPProtocolFallback: true,
+			QueryName:          "example.com",
+			Recursion:          true,
+			EDNS0: config.DNSEDNS0{
+				UDPSize:          1400,
+				ClientSubnet:     "192.0.2.0/24",
+				Cookie:           true,
+				NSID:             true,
+				PaddingBlockSize: 128,
+			},
+		},
+	}
+	registry := prometheus.NewRegistry()
+	testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result := ProbeDNS(testCTX, net.JoinHostPort("localhost", port), module, registry, log.NewNopLogger())
+	if !result {
+		t.Fatalf("DNS test connection failed, expected success.")
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkRegistryLabels(map[string]map[string]string{
+		"probe_dns_nsid_info": {"nsid": "ns1.ams"},
+	}, mfs, t)
+	checkRegistryResults(map[string]float64{
+		"probe_dns_server_cookie":                     1,
+		"probe_dns_client_subnet_scope_prefix_length": 16,
+	}, mfs, t)
+}
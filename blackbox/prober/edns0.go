@@ -0,0 +1,169 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"unicode"
+
+	"github.com/miekg/dns"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+// defaultEDNS0UDPSize is the buffer size advertised when EDNS0 is enabled
+// without an explicit size, as recommended by DNS flag day 2020.
+const defaultEDNS0UDPSize = 1232
+
+// parseClientSubnet builds an EDNS Client Subnet option (RFC 7871) from an
+// address or CIDR.
+func parseClientSubnet(s string) (*dns.EDNS0_SUBNET, error) {
+	ip, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		if ip = net.ParseIP(s); ip == nil {
+			return nil, fmt.Errorf("client subnet %q is not valid", s)
+		}
+		bits := 128
+		if ip.To4() != nil {
+			bits = 32
+		}
+		ipNet = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+	}
+	ones, _ := ipNet.Mask.Size()
+	subnet := &dns.EDNS0_SUBNET{
+		Code:          dns.EDNS0SUBNET,
+		SourceNetmask: uint8(ones),
+	}
+	if v4 := ipNet.IP.To4(); v4 != nil {
+		subnet.Family = 1
+		subnet.Address = v4
+	} else {
+		subnet.Family = 2
+		subnet.Address = ipNet.IP.To16()
+	}
+	return subnet, nil
+}
+
+// setEDNS0 adds an OPT record carrying the configured options to the query.
+func setEDNS0(msg *dns.Msg, e config.DNSEDNS0) error {
+	if !e.Enabled() {
+		return nil
+	}
+	udpSize := e.UDPSize
+	if udpSize == 0 {
+		udpSize = defaultEDNS0UDPSize
+	}
+	msg.SetEdns0(udpSize, false)
+	opt := msg.IsEdns0()
+
+	if e.ClientSubnet != "" {
+		subnet, err := parseClientSubnet(e.ClientSubnet)
+		if err != nil {
+			return err
+		}
+		opt.Option = append(opt.Option, subnet)
+	}
+	if e.Cookie {
+		cookie := make([]byte, 8)
+		if _, err := rand.Read(cookie); err != nil {
+			return fmt.Errorf("error generating client cookie: %w", err)
+		}
+		opt.Option = append(opt.Option, &dns.EDNS0_COOKIE{Code: dns.EDNS0COOKIE, Cookie: hex.EncodeToString(cookie)})
+	}
+	if e.NSID {
+		opt.Option = append(opt.Option, &dns.EDNS0_NSID{Code: dns.EDNS0NSID})
+	}
+	if e.PaddingBlockSize > 0 {
+		// Pad the query to a multiple of the block size (RFC 7830, RFC 8467).
+		padding := &dns.EDNS0_PADDING{}
+		opt.Option = append(opt.Option, padding)
+		if rem := msg.Len() % e.PaddingBlockSize; rem != 0 {
+			padding.Padding = make([]byte, e.PaddingBlockSize-rem)
+		}
+	}
+	return nil
+}
+
+// nsidString returns the NSID as text when it is printable, hex otherwise.
+func nsidString(nsid string) string {
+	b, err := hex.DecodeString(nsid)
+	if err != nil {
+		return nsid
+	}
+	s := string(b)
+	if strings.IndexFunc(s, func(r rune) bool { return !unicode.IsPrint(r) }) != -1 {
+		return nsid
+	}
+	return s
+}
+
+// exportEDNS0Metrics registers metrics for the EDNS0 options that were
+// requested and returned by the server.
+func exportEDNS0Metrics(response *dns.Msg, e config.DNSEDNS0, registry *prometheus.Registry) {
+	if !e.Enabled() {
+		return
+	}
+	opt := response.IsEdns0()
+
+	if e.NSID {
+		nsidGaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_dns_nsid_info",
+			Help: "Contains the name server identifier returned by the server",
+		}, []string{"nsid"})
+		registry.MustRegister(nsidGaugeVec)
+		if opt != nil {
+			for _, o := range opt.Option {
+				if nsid, ok := o.(*dns.EDNS0_NSID); ok {
+					nsidGaugeVec.WithLabelValues(nsidString(nsid.Nsid)).Set(1)
+				}
+			}
+		}
+	}
+
+	if e.Cookie {
+		serverCookieGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_server_cookie",
+			Help: "Displays whether or not the server returned a DNS cookie",
+		})
+		registry.MustRegister(serverCookieGauge)
+		if opt != nil {
+			for _, o := range opt.Option {
+				// A server cookie follows the 16 hex digit client cookie.
+				if cookie, ok := o.(*dns.EDNS0_COOKIE); ok && len(cookie.Cookie) > 16 {
+					serverCookieGauge.Set(1)
+				}
+			}
+		}
+	}
+
+	if e.ClientSubnet != "" {
+		scopeGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_dns_client_subnet_scope_prefix_length",
+			Help: "Returns the scope prefix length of the EDNS Client Subnet option in the response",
+		})
+		registry.MustRegister(scopeGauge)
+		if opt != nil {
+			for _, o := range opt.Option {
+				if subnet, ok := o.(*dns.EDNS0_SUBNET); ok {
+					scopeGauge.Set(float64(subnet.SourceScope))
+				}
+			}
+		}
+	}
+}
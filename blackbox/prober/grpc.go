@@ -15,8 +15,10 @@ package prober
 
 import (
 	"context"
+	"fmt"
 	"net"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
@@ -27,11 +29,15 @@ import (
 	pconfig "github.com/prometheus/common/config"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
 type GRPCHealthCheck interface {
@@ -75,6 +81,97 @@ func (c *gRPCHealthCheckClient) Check(ctx context.Context, service string) (bool
 	return false, returnStatus.Code(), nil, "", err
 }
 
+// grpcHealthWatch summarizes the health updates streamed during a watch.
+type grpcHealthWatch struct {
+	firstResponse time.Duration
+	transitions   int
+	// durations is the time spent in each serving status.
+	durations  map[string]time.Duration
+	last       string
+	allServing bool
+}
+
+// Watch streams health updates of a service until the window elapsed. The
+// end of the window is not an error.
+func (c *gRPCHealthCheckClient) Watch(ctx context.Context, service string, window time.Duration) (*grpcHealthWatch, codes.Code, *peer.Peer, error) {
+	watchCtx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	w := &grpcHealthWatch{durations: map[string]time.Duration{}, allServing: true}
+	serverPeer := new(peer.Peer)
+	start := time.Now()
+	stream, err := c.client.Watch(watchCtx, &grpc_health_v1.HealthCheckRequest{Service: service}, grpc.Peer(serverPeer))
+	if err != nil {
+		return w, status.Code(err), nil, err
+	}
+
+	var since time.Time
+	for {
+		res, err := stream.Recv()
+		now := time.Now()
+		if err != nil {
+			if w.last != "" {
+				w.durations[w.last] += now.Sub(since)
+			}
+			if watchCtx.Err() != nil && ctx.Err() == nil && w.last != "" {
+				return w, codes.OK, serverPeer, nil
+			}
+			return w, status.Code(err), serverPeer, err
+		}
+
+		servingStatus := res.GetStatus().String()
+		if w.last == "" {
+			w.firstResponse = now.Sub(start)
+		} else {
+			w.durations[w.last] += now.Sub(since)
+			if servingStatus != w.last {
+				w.transitions++
+			}
+		}
+		if res.GetStatus() != grpc_health_v1.HealthCheckResponse_SERVING {
+			w.allServing = false
+		}
+		w.last, since = servingStatus, now
+	}
+}
+
+// grpcOutgoingContext adds the configured metadata and bearer token to the
+// requests made with the context.
+func grpcOutgoingContext(ctx context.Context, probe config.GRPCProbe) (context.Context, error) {
+	md := metadata.New(probe.Metadata)
+	token := string(probe.BearerToken)
+	if probe.BearerTokenFile != "" {
+		b, err := os.ReadFile(probe.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read bearer token file %s: %w", probe.BearerTokenFile, err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	if token != "" {
+		md.Set("authorization", "Bearer "+token)
+	}
+	if md.Len() == 0 {
+		return ctx, nil
+	}
+	return metadata.NewOutgoingContext(ctx, md), nil
+}
+
+// waitForGRPCConnection connects and waits until the connection is ready or
+// failed. RPCs on a failed connection fail right away with the reason.
+func waitForGRPCConnection(ctx context.Context, conn *grpc.ClientConn) connectivity.State {
+	conn.Connect()
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready, connectivity.TransientFailure, connectivity.Shutdown:
+			return state
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return conn.GetState()
+		}
+	}
+}
+
 func ProbeGRPC(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger log.Logger) (success bool) {
 
 	var (
@@ -114,7 +211,7 @@ func ProbeGRPC(ctx context.Context, target string, module config.Module, registr
 		)
 	)
 
-	for _, lv := range []string{"resolve"} {
+	for _, lv := range []string{"resolve", "connect"} {
 		durationGaugeVec.WithLabelValues(lv)
 	}
 
@@ -148,57 +245,131 @@ func ProbeGRPC(ctx context.Context, target string, module config.Module, registr
 		return false
 	}
 
-	ip, lookupTime, err := chooseProtocol(ctx, module.GRPC.PreferredIPProtocol, module.GRPC.IPProtocolFallback, targetHost, registry, logger)
+	ctx, err = grpcOutgoingContext(ctx, module.GRPC)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error creating request metadata", "err", err)
+		return false
+	}
+
+	_, lookupTime, err := chooseProtocol(ctx, module.GRPC.PreferredIPProtocol, module.GRPC.IPProtocolFallback, targetHost, registry, logger)
 	if err != nil {
 		level.Error(logger).Log("msg", "Error resolving address", "err", err)
 		return false
 	}
 	durationGaugeVec.WithLabelValues("resolve").Add(lookupTime)
-	checkStart := time.Now()
-	if len(tlsConfig.ServerName) == 0 {
+	if len(targetPort) == 0 {
+		targetPort = "80"
+		if module.GRPC.TLS {
+			targetPort = "443"
+		}
+	}
+
+	var opts []grpc.DialOption
+	if module.GRPC.Authority != "" {
+		opts = append(opts, grpc.WithAuthority(module.GRPC.Authority))
+	} else if len(tlsConfig.ServerName) == 0 {
 		// If there is no `server_name` in tls_config, use
 		// the hostname of the target.
 		tlsConfig.ServerName = targetHost
 	}
-
-	if targetPort == "" {
-		targetURL.Host = "[" + ip.String() + "]"
-	} else {
-		targetURL.Host = net.JoinHostPort(ip.String(), targetPort)
-	}
-
-	var opts []grpc.DialOption
-	target = targetHost + ":" + targetPort
 	if !module.GRPC.TLS {
 		level.Debug(logger).Log("msg", "Dialing GRPC without TLS")
 		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
-		if len(targetPort) == 0 {
-			target = targetHost + ":80"
-		}
 	} else {
 		creds := credentials.NewTLS(tlsConfig)
 		opts = append(opts, grpc.WithTransportCredentials(creds))
-		if len(targetPort) == 0 {
-			target = targetHost + ":443"
-		}
+	}
+	if module.GRPC.Keepalive.Time > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                module.GRPC.Keepalive.Time,
+			Timeout:             module.GRPC.Keepalive.Timeout,
+			PermitWithoutStream: module.GRPC.Keepalive.PermitWithoutStream,
+		}))
 	}
 
-	conn, err := grpc.Dial(target, opts...)
-
+	conn, err := grpc.NewClient(net.JoinHostPort(targetHost, targetPort), opts...)
 	if err != nil {
-		level.Error(logger).Log("did not connect: %v", err)
+		level.Error(logger).Log("msg", "Error creating gRPC client", "err", err)
+		return false
 	}
-
-	client := NewGrpcHealthCheckClient(conn)
 	defer conn.Close()
-	ok, statusCode, serverPeer, servingStatus, err := client.Check(context.Background(), module.GRPC.Service)
-	durationGaugeVec.WithLabelValues("check").Add(time.Since(checkStart).Seconds())
 
-	for servingStatusName, _ := range grpc_health_v1.HealthCheckResponse_ServingStatus_value {
-		healthCheckResponseGaugeVec.WithLabelValues(servingStatusName).Set(float64(0))
-	}
-	if servingStatus != "" {
-		healthCheckResponseGaugeVec.WithLabelValues(servingStatus).Set(float64(1))
+	connectStart := time.Now()
+	state := waitForGRPCConnection(ctx, conn)
+	durationGaugeVec.WithLabelValues("connect").Add(time.Since(connectStart).Seconds())
+	recordPhase(ctx, "connect", connectStart, time.Now(), nil)
+	level.Debug(logger).Log("msg", "Connection state after connecting", "state", state)
+
+	var (
+		ok         bool
+		statusCode codes.Code
+		serverPeer *peer.Peer
+	)
+	if module.GRPC.Method != "" {
+		failedDueToAssertionGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_grpc_failed_due_to_assertion",
+			Help: "Indicates if probe failed due to a response assertion",
+		})
+		registry.MustRegister(failedDueToAssertionGauge)
+
+		var response proto.Message
+		response, statusCode, serverPeer, err = invokeGRPCMethod(ctx, conn, module.GRPC, durationGaugeVec, logger)
+		if err == nil {
+			ok = checkGRPCResponse(response, module.GRPC.ResponseAssertions, logger)
+			if !ok {
+				failedDueToAssertionGauge.Set(1)
+			}
+		}
+	} else if module.GRPC.WatchWindow > 0 {
+		var (
+			transitionsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: "probe_grpc_healthcheck_transitions",
+				Help: "Returns the number of serving status changes during the watch",
+			})
+			statusDurationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+				Name: "probe_grpc_healthcheck_status_duration_seconds",
+				Help: "Time spent in each serving status during the watch",
+			}, []string{"serving_status"})
+		)
+		registry.MustRegister(transitionsGauge)
+		registry.MustRegister(statusDurationGaugeVec)
+
+		client := &gRPCHealthCheckClient{client: grpc_health_v1.NewHealthClient(conn), conn: conn}
+		watchStart := time.Now()
+		var w *grpcHealthWatch
+		w, statusCode, serverPeer, err = client.Watch(ctx, module.GRPC.Service, module.GRPC.WatchWindow)
+		durationGaugeVec.WithLabelValues("watch").Add(time.Since(watchStart).Seconds())
+		recordPhase(ctx, "watch", watchStart, time.Now(), err)
+		if w.last != "" {
+			durationGaugeVec.WithLabelValues("first_response").Add(w.firstResponse.Seconds())
+		}
+
+		transitionsGauge.Set(float64(w.transitions))
+		for servingStatusName := range grpc_health_v1.HealthCheckResponse_ServingStatus_value {
+			healthCheckResponseGaugeVec.WithLabelValues(servingStatusName).Set(0)
+			statusDurationGaugeVec.WithLabelValues(servingStatusName).Set(w.durations[servingStatusName].Seconds())
+		}
+		if w.last != "" {
+			healthCheckResponseGaugeVec.WithLabelValues(w.last).Set(1)
+		}
+		ok = w.last != "" && w.allServing
+		if err == nil && !ok {
+			level.Error(logger).Log("msg", "Service was not serving during the whole watch", "transitions", w.transitions, "last_status", w.last)
+		}
+	} else {
+		client := NewGrpcHealthCheckClient(conn)
+		var servingStatus string
+		checkStart := time.Now()
+		ok, statusCode, serverPeer, servingStatus, err = client.Check(ctx, module.GRPC.Service)
+		durationGaugeVec.WithLabelValues("check").Add(time.Since(checkStart).Seconds())
+		recordPhase(ctx, "check", checkStart, time.Now(), err)
+
+		for servingStatusName, _ := range grpc_health_v1.HealthCheckResponse_ServingStatus_value {
+			healthCheckResponseGaugeVec.WithLabelValues(servingStatusName).Set(float64(0))
+		}
+		if servingStatus != "" {
+			healthCheckResponseGaugeVec.WithLabelValues(servingStatus).Set(float64(1))
+		}
 	}
 
 	if serverPeer != nil {
@@ -218,7 +389,7 @@ func ProbeGRPC(ctx context.Context, target string, module config.Module, registr
 		level.Error(logger).Log("msg", "can't connect grpc server:", "err", err)
 		success = false
 	} else {
-		level.Debug(logger).Log("connect the grpc server successfully")
+		level.Debug(logger).Log("msg", "Connected to the gRPC server successfully")
 		success = true
 	}
 
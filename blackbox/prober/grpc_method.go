@@ -0,0 +1,324 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	reflectionv1 "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alpha "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+// fetchFileDescriptors asks a reflection service for the serialized file
+// descriptors defining a symbol or a file.
+type fetchFileDescriptors func(symbol, filename string) ([][]byte, error)
+
+func reflectionV1(ctx context.Context, conn grpc.ClientConnInterface) fetchFileDescriptors {
+	var stream reflectionv1.ServerReflection_ServerReflectionInfoClient
+	return func(symbol, filename string) ([][]byte, error) {
+		if stream == nil {
+			s, err := reflectionv1.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+			if err != nil {
+				return nil, err
+			}
+			stream = s
+		}
+		req := &reflectionv1.ServerReflectionRequest{MessageRequest: &reflectionv1.ServerReflectionRequest_FileByFilename{FileByFilename: filename}}
+		if symbol != "" {
+			req.MessageRequest = &reflectionv1.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol}
+		}
+		// Errors of Send are reported by Recv.
+		stream.Send(req)
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, status.Error(codes.Code(e.GetErrorCode()), e.GetErrorMessage())
+		}
+		return resp.GetFileDescriptorResponse().GetFileDescriptorProto(), nil
+	}
+}
+
+// reflectionV1Alpha is used for servers predating the v1 reflection service.
+func reflectionV1Alpha(ctx context.Context, conn grpc.ClientConnInterface) fetchFileDescriptors {
+	var stream reflectionv1alpha.ServerReflection_ServerReflectionInfoClient
+	return func(symbol, filename string) ([][]byte, error) {
+		if stream == nil {
+			s, err := reflectionv1alpha.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+			if err != nil {
+				return nil, err
+			}
+			stream = s
+		}
+		req := &reflectionv1alpha.ServerReflectionRequest{MessageRequest: &reflectionv1alpha.ServerReflectionRequest_FileByFilename{FileByFilename: filename}}
+		if symbol != "" {
+			req.MessageRequest = &reflectionv1alpha.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol}
+		}
+		stream.Send(req)
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, status.Error(codes.Code(e.GetErrorCode()), e.GetErrorMessage())
+		}
+		return resp.GetFileDescriptorResponse().GetFileDescriptorProto(), nil
+	}
+}
+
+// reflectGRPCFiles resolves the files defining a service and their
+// dependencies through server reflection.
+func reflectGRPCFiles(ctx context.Context, conn grpc.ClientConnInterface, service string) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	files, err := resolveGRPCFiles(service, reflectionV1(ctx, conn))
+	if status.Code(err) == codes.Unimplemented {
+		files, err = resolveGRPCFiles(service, reflectionV1Alpha(ctx, conn))
+	}
+	return files, err
+}
+
+func resolveGRPCFiles(service string, fetch fetchFileDescriptors) (*protoregistry.Files, error) {
+	fdps := map[string]*descriptorpb.FileDescriptorProto{}
+	add := func(raw [][]byte) error {
+		for _, b := range raw {
+			fdp := &descriptorpb.FileDescriptorProto{}
+			if err := proto.Unmarshal(b, fdp); err != nil {
+				return err
+			}
+			fdps[fdp.GetName()] = fdp
+		}
+		return nil
+	}
+
+	raw, err := fetch(service, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := add(raw); err != nil {
+		return nil, err
+	}
+	// Servers usually send all dependencies along, fetch the ones they
+	// left out. Well-known types are taken from the local registry.
+	for {
+		var missing []string
+		for _, fdp := range fdps {
+			for _, dep := range fdp.GetDependency() {
+				if _, ok := fdps[dep]; !ok {
+					missing = append(missing, dep)
+				}
+			}
+		}
+		if len(missing) == 0 {
+			break
+		}
+		for _, dep := range missing {
+			if _, ok := fdps[dep]; ok {
+				continue
+			}
+			if fd, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+				fdps[dep] = protodesc.ToFileDescriptorProto(fd)
+				continue
+			}
+			raw, err := fetch("", dep)
+			if err != nil {
+				return nil, err
+			}
+			if err := add(raw); err != nil {
+				return nil, err
+			}
+			if _, ok := fdps[dep]; !ok {
+				return nil, fmt.Errorf("server did not return file %q", dep)
+			}
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, fdp := range fdps {
+		set.File = append(set.File, fdp)
+	}
+	return protodesc.NewFiles(set)
+}
+
+// loadGRPCDescriptorSet reads a binary FileDescriptorSet as written by
+// protoc --descriptor_set_out --include_imports.
+func loadGRPCDescriptorSet(path string) (*protoregistry.Files, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, err
+	}
+	return protodesc.NewFiles(set)
+}
+
+// findGRPCMethod looks up a unary method given as "package.Service/Method".
+func findGRPCMethod(files *protoregistry.Files, fullMethod string) (protoreflect.MethodDescriptor, error) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	d, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("service %q not found: %w", service, err)
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, fmt.Errorf("method %q not found in service %q", method, service)
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		return nil, fmt.Errorf("method %q is not unary", fullMethod)
+	}
+	return md, nil
+}
+
+// invokeGRPCMethod resolves the configured method and calls it with the
+// configured request.
+func invokeGRPCMethod(ctx context.Context, conn *grpc.ClientConn, probe config.GRPCProbe, durationGaugeVec *prometheus.GaugeVec, logger log.Logger) (proto.Message, codes.Code, *peer.Peer, error) {
+	service, _, _ := strings.Cut(strings.TrimPrefix(probe.Method, "/"), "/")
+
+	descriptorsStart := time.Now()
+	var (
+		files *protoregistry.Files
+		err   error
+	)
+	if probe.DescriptorSetFile != "" {
+		files, err = loadGRPCDescriptorSet(probe.DescriptorSetFile)
+	} else {
+		level.Debug(logger).Log("msg", "Resolving method through server reflection", "service", service)
+		files, err = reflectGRPCFiles(ctx, conn, service)
+	}
+	durationGaugeVec.WithLabelValues("descriptors").Add(time.Since(descriptorsStart).Seconds())
+	recordPhase(ctx, "descriptors", descriptorsStart, time.Now(), err)
+	if err != nil {
+		return nil, status.Code(err), nil, fmt.Errorf("error loading descriptors: %w", err)
+	}
+	md, err := findGRPCMethod(files, probe.Method)
+	if err != nil {
+		return nil, codes.Unknown, nil, err
+	}
+
+	req := dynamicpb.NewMessage(md.Input())
+	if probe.Request != "" {
+		if err := protojson.Unmarshal([]byte(probe.Request), req); err != nil {
+			return nil, codes.Unknown, nil, fmt.Errorf("error decoding request: %w", err)
+		}
+	}
+	resp := dynamicpb.NewMessage(md.Output())
+
+	serverPeer := new(peer.Peer)
+	rpcStart := time.Now()
+	err = conn.Invoke(ctx, "/"+string(md.Parent().FullName())+"/"+string(md.Name()), req, resp, grpc.Peer(serverPeer))
+	durationGaugeVec.WithLabelValues("rpc").Add(time.Since(rpcStart).Seconds())
+	recordPhase(ctx, "rpc", rpcStart, time.Now(), err)
+	if err != nil {
+		return nil, status.Code(err), serverPeer, err
+	}
+	return resp, codes.OK, serverPeer, nil
+}
+
+// grpcResponseField returns the JSON form of a field of the response.
+func grpcResponseField(response interface{}, path string) (string, error) {
+	v := response
+	for _, name := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			field, ok := node[name]
+			if !ok {
+				return "", fmt.Errorf("field %q not found", name)
+			}
+			v = field
+		case []interface{}:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", fmt.Errorf("index %q out of range", name)
+			}
+			v = node[i]
+		default:
+			return "", fmt.Errorf("field %q not found", name)
+		}
+	}
+	switch value := v.(type) {
+	case string:
+		return value, nil
+	case json.Number:
+		return value.String(), nil
+	default:
+		b, err := json.Marshal(value)
+		return string(b), err
+	}
+}
+
+// checkGRPCResponse evaluates the response assertions.
+func checkGRPCResponse(response proto.Message, assertions []config.GRPCResponseAssertion, logger log.Logger) bool {
+	if len(assertions) == 0 {
+		return true
+	}
+	b, err := protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(response)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error encoding response", "err", err)
+		return false
+	}
+	var decoded interface{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&decoded); err != nil {
+		level.Error(logger).Log("msg", "Error decoding response", "err", err)
+		return false
+	}
+
+	for _, a := range assertions {
+		value, err := grpcResponseField(decoded, a.Field)
+		if err != nil {
+			level.Error(logger).Log("msg", "Response assertion failed", "field", a.Field, "err", err)
+			return false
+		}
+		if a.Equals != "" && value != a.Equals {
+			level.Error(logger).Log("msg", "Response field does not equal the expected value", "field", a.Field, "value", value, "expected", a.Equals)
+			return false
+		}
+		if a.Regexp.Regexp != nil && !a.Regexp.MatchString(value) {
+			level.Error(logger).Log("msg", "Response field does not match regular expression", "field", a.Field, "value", value, "regexp", a.Regexp.String())
+			return false
+		}
+	}
+	return true
+}
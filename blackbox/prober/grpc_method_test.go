@@ -0,0 +1,176 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+func TestGRPCResponseField(t *testing.T) {
+	var response interface{}
+	d := json.NewDecoder(strings.NewReader(`{"status":"SERVING","count":"42","ratio":0.5,"items":[{"name":"a"},{"name":"b"}],"ok":true}`))
+	d.UseNumber()
+	if err := d.Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"status":       "SERVING",
+		"count":        "42",
+		"ratio":        "0.5",
+		"items.1.name": "b",
+		"ok":           "true",
+		"items.0":      `{"name":"a"}`,
+	} {
+		got, err := grpcResponseField(response, path)
+		if err != nil {
+			t.Errorf("Field %q: %s", path, err)
+		} else if got != want {
+			t.Errorf("Field %q: expected %q, got %q", path, want, got)
+		}
+	}
+	for _, path := range []string{"missing", "items.2.name", "status.name"} {
+		if _, err := grpcResponseField(response, path); err == nil {
+			t.Errorf("Expected error for field %q", path)
+		}
+	}
+}
+
+func TestGRPCMethod(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening on socket: %s", err)
+	}
+	defer ln.Close()
+
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Error retrieving port for socket: %s", err)
+	}
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("service", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	reflection.Register(s)
+
+	go func() {
+		if err := s.Serve(ln); err != nil {
+			t.Errorf("failed to serve: %v", err)
+			return
+		}
+	}()
+	defer s.GracefulStop()
+
+	descriptorSet, err := proto.Marshal(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(grpc_health_v1.File_grpc_health_v1_health_proto)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptorSetFile := filepath.Join(t.TempDir(), "health.protoset")
+	if err := os.WriteFile(descriptorSetFile, descriptorSet, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name                 string
+		probe                config.GRPCProbe
+		success              bool
+		statusCode           float64
+		failedDueToAssertion float64
+	}{
+		{
+			name: "reflection",
+			probe: config.GRPCProbe{
+				Method:             "grpc.health.v1.Health/Check",
+				Request:            `{"service": "service"}`,
+				ResponseAssertions: []config.GRPCResponseAssertion{{Field: "status", Equals: "SERVING"}},
+			},
+			success: true,
+		},
+		{
+			name: "descriptor set",
+			probe: config.GRPCProbe{
+				Method:             "/grpc.health.v1.Health/Check",
+				Request:            `{"service": "service"}`,
+				DescriptorSetFile:  descriptorSetFile,
+				ResponseAssertions: []config.GRPCResponseAssertion{{Field: "status", Regexp: config.MustNewRegexp("^SERV")}},
+			},
+			success: true,
+		},
+		{
+			name: "assertion failed",
+			probe: config.GRPCProbe{
+				Method:             "grpc.health.v1.Health/Check",
+				Request:            `{"service": "service"}`,
+				ResponseAssertions: []config.GRPCResponseAssertion{{Field: "status", Equals: "NOT_SERVING"}},
+			},
+			failedDueToAssertion: 1,
+		},
+		{
+			name: "status code",
+			probe: config.GRPCProbe{
+				Method:  "grpc.health.v1.Health/Check",
+				Request: `{"service": "missing"}`,
+			},
+			statusCode: 5,
+		},
+		{
+			name: "streaming method",
+			probe: config.GRPCProbe{
+				Method: "grpc.health.v1.Health/Watch",
+			},
+			statusCode: 2,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			registry := prometheus.NewRegistry()
+			tc.probe.PreferredIPProtocol = "ip4"
+
+			result := ProbeGRPC(testCTX, "localhost:"+port, config.Module{Timeout: time.Second, GRPC: tc.probe}, registry, log.NewNopLogger())
+			if result != tc.success {
+				t.Fatalf("Expected probe success %v, got %v", tc.success, result)
+			}
+
+			mfs, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRegistryResults(map[string]float64{
+				"probe_grpc_status_code":             tc.statusCode,
+				"probe_grpc_failed_due_to_assertion": tc.failedDueToAssertion,
+			}, mfs, t)
+		})
+	}
+}
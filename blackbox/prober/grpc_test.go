@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -29,9 +30,12 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	pconfig "github.com/prometheus/common/config"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 func TestGRPCConnection(t *testing.T) {
@@ -414,3 +418,289 @@ func TestGRPCHealthCheckUnimplemented(t *testing.T) {
 
 	checkRegistryResults(expectedResults, mfs, t)
 }
+
+func TestGRPCMetadataAndAuthority(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening on socket: %s", err)
+	}
+	defer ln.Close()
+
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Error retrieving port for socket: %s", err)
+	}
+
+	var received metadata.MD
+	s := grpc.NewServer(grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		received, _ = metadata.FromIncomingContext(ctx)
+		if v := received.Get("authorization"); len(v) != 1 || v[0] != "Bearer s3cr3t" {
+			return nil, status.Error(codes.Unauthenticated, "missing token")
+		}
+		return handler(ctx, req)
+	}))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("service", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(ln); err != nil {
+			t.Errorf("failed to serve: %v", err)
+			return
+		}
+	}()
+	defer s.GracefulStop()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name       string
+		probe      config.GRPCProbe
+		success    bool
+		statusCode float64
+		metadata   map[string]string
+	}{
+		{
+			name: "bearer token",
+			probe: config.GRPCProbe{
+				BearerToken: "s3cr3t",
+				Metadata:    map[string]string{"X-Tenant": "orders"},
+				Authority:   "api.example.com",
+			},
+			success:  true,
+			metadata: map[string]string{"x-tenant": "orders", ":authority": "api.example.com"},
+		},
+		{
+			name:     "bearer token file",
+			probe:    config.GRPCProbe{BearerTokenFile: tokenFile},
+			success:  true,
+			metadata: map[string]string{":authority": "localhost:" + port},
+		},
+		{
+			name:       "unauthenticated",
+			probe:      config.GRPCProbe{},
+			statusCode: 16,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			registry := prometheus.NewRegistry()
+			tc.probe.PreferredIPProtocol = "ip4"
+			tc.probe.Service = "service"
+
+			result := ProbeGRPC(testCTX, "localhost:"+port, config.Module{Timeout: time.Second, GRPC: tc.probe}, registry, log.NewNopLogger())
+			if result != tc.success {
+				t.Fatalf("Expected probe success %v, got %v", tc.success, result)
+			}
+			mfs, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRegistryResults(map[string]float64{"probe_grpc_status_code": tc.statusCode}, mfs, t)
+			for key, want := range tc.metadata {
+				if got := received.Get(key); len(got) != 1 || got[0] != want {
+					t.Errorf("Expected metadata %s=%s, got %v", key, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGRPCConnectionRefused(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening on socket: %s", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	registry := prometheus.NewRegistry()
+
+	start := time.Now()
+	result := ProbeGRPC(testCTX, addr, config.Module{Timeout: time.Second, GRPC: config.GRPCProbe{PreferredIPProtocol: "ip4"}}, registry, log.NewNopLogger())
+	if result {
+		t.Fatalf("GRPC probe succeed")
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected refused connection to fail before the timeout")
+	}
+
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRegistryResults(map[string]float64{"probe_grpc_status_code": 14}, mfs, t)
+	checkMetrics(map[string]map[string]map[string]struct{}{
+		"probe_grpc_duration_seconds": {
+			"phase": {"resolve": {}, "connect": {}, "check": {}},
+		},
+	}, mfs, t)
+}
+
+func TestGRPCClientCertificate(t *testing.T) {
+	certExpiry := time.Now().AddDate(0, 0, 1)
+	testCertTmpl := generateCertificateTemplate(certExpiry, false)
+	testCertTmpl.IsCA = true
+	testCert, testCertPem, testKey := generateSelfSignedCertificate(testCertTmpl)
+	testKeyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(testKey)})
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, testCertPem, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, testKeyPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyPair, err := tls.X509KeyPair(testCertPem, testKeyPem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(testCert)
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{keyPair},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening on socket: %s", err)
+	}
+	defer ln.Close()
+
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Error retrieving port for socket: %s", err)
+	}
+
+	s := grpc.NewServer(grpc.Creds(credentials.NewTLS(tlsConfig)))
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("service", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(ln); err != nil {
+			t.Errorf("failed to serve: %v", err)
+			return
+		}
+	}()
+	defer s.GracefulStop()
+
+	for _, withCert := range []bool{true, false} {
+		testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		probe := config.GRPCProbe{
+			TLS:                 true,
+			TLSConfig:           pconfig.TLSConfig{CAFile: certFile},
+			PreferredIPProtocol: "ip4",
+		}
+		if withCert {
+			probe.TLSConfig.CertFile, probe.TLSConfig.KeyFile = certFile, keyFile
+		}
+		result := ProbeGRPC(testCTX, "localhost:"+port, config.Module{Timeout: time.Second, GRPC: probe}, prometheus.NewRegistry(), log.NewNopLogger())
+		if result != withCert {
+			t.Errorf("Expected probe success %v with client certificate %v, got %v", withCert, withCert, result)
+		}
+	}
+}
+
+func TestGRPCHealthWatch(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Error listening on socket: %s", err)
+	}
+	defer ln.Close()
+
+	_, port, err := net.SplitHostPort(ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Error retrieving port for socket: %s", err)
+	}
+	s := grpc.NewServer()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("stable", grpc_health_v1.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus("flapping", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+
+	go func() {
+		if err := s.Serve(ln); err != nil {
+			t.Errorf("failed to serve: %v", err)
+			return
+		}
+	}()
+	defer s.GracefulStop()
+
+	for _, tc := range []struct {
+		service     string
+		success     bool
+		transitions float64
+	}{
+		{service: "stable", success: true},
+		{service: "flapping", transitions: 2},
+	} {
+		t.Run(tc.service, func(t *testing.T) {
+			if tc.service == "flapping" {
+				go func() {
+					time.Sleep(100 * time.Millisecond)
+					healthServer.SetServingStatus("flapping", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+					time.Sleep(100 * time.Millisecond)
+					healthServer.SetServingStatus("flapping", grpc_health_v1.HealthCheckResponse_SERVING)
+				}()
+			}
+
+			testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			registry := prometheus.NewRegistry()
+			result := ProbeGRPC(testCTX, "localhost:"+port, config.Module{Timeout: time.Second, GRPC: config.GRPCProbe{
+				PreferredIPProtocol: "ip4",
+				Service:             tc.service,
+				WatchWindow:         400 * time.Millisecond,
+			}}, registry, log.NewNopLogger())
+			if result != tc.success {
+				t.Fatalf("Expected probe success %v, got %v", tc.success, result)
+			}
+
+			mfs, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRegistryResults(map[string]float64{
+				"probe_grpc_status_code":             0,
+				"probe_grpc_healthcheck_transitions": tc.transitions,
+			}, mfs, t)
+			for _, mf := range mfs {
+				if len(mf.Metric) == 0 || len(mf.Metric[0].GetLabel()) == 0 {
+					continue
+				}
+				for _, m := range mf.Metric {
+					v, servingStatus := m.GetGauge().GetValue(), m.GetLabel()[0].GetValue()
+					if mf.GetName() == "probe_grpc_healthcheck_response" && (v == 1) != (servingStatus == "SERVING") {
+						t.Errorf("Unexpected last status %s=%v", servingStatus, v)
+					}
+					if mf.GetName() != "probe_grpc_healthcheck_status_duration_seconds" {
+						continue
+					}
+					switch servingStatus {
+					case "SERVING":
+						if v < 0.1 || v > 0.5 {
+							t.Errorf("Unexpected time serving %v", v)
+						}
+					case "NOT_SERVING":
+						if (tc.transitions > 0) != (v > 0) {
+							t.Errorf("Unexpected time not serving %v", v)
+						}
+					}
+				}
+			}
+		})
+	}
+}
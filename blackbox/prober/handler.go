@@ -16,8 +16,10 @@ package prober
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/abialemuel/prometheus-exporter/blackbox/config"
@@ -26,32 +28,74 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/expfmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v2"
 )
 
 var (
-	Probers = map[string]ProbeFn{
-		"http":     ProbeHTTP,
-		"tcp":      ProbeTCP,
-		"icmp":     ProbeICMP,
-		"icmp_qos": ProbeICMPQoS,
-		"dns":      ProbeDNS,
-		"grpc":     ProbeGRPC,
-	}
-	moduleUnknownCounter = promauto.NewCounter(prometheus.CounterOpts{
-		Name: "blackbox_module_unknown_total",
-		Help: "Count of unknown modules requested by probes",
-	})
+	probersMtx sync.RWMutex
+	probers    = map[string]ProbeFn{
+		"http":            ProbeHTTP,
+		"tcp":             ProbeTCP,
+		"icmp":            ProbeICMP,
+		"icmp_qos":        ProbeICMPQoS,
+		"dns":             ProbeDNS,
+		"dns_consistency": ProbeDNSConsistency,
+		"grpc":            ProbeGRPC,
+		"traceroute":      ProbeTraceroute,
+		"pmtu":            ProbePMTU,
+		"icmp_batch":      ProbeICMPBatch,
+		"twamp":           ProbeTWAMP,
+	}
+	probeSuccessGaugeOpts = prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Displays whether or not the probe was a success",
+	}
+	probeDurationGaugeOpts = prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "Returns how long the probe took to complete in seconds",
+	}
 )
 
+// RegisterProber adds a prober that modules can use by name. The
+// "extension" block of those modules is decoded into the value returned by
+// configFactory and checked with its Validate() error method, if any, when
+// the configuration is loaded. The prober finds it in
+// module.Extension.Config. configFactory can be nil for probers without
+// configuration. Probers have to be registered before the configuration is
+// loaded.
+func RegisterProber(name string, fn ProbeFn, configFactory config.ExtensionFactory) error {
+	if name == "" || fn == nil {
+		return errors.New("prober name and function must be set")
+	}
+	if name == "composite" {
+		return errors.New("prober name composite is reserved")
+	}
+	probersMtx.Lock()
+	defer probersMtx.Unlock()
+	if _, ok := probers[name]; ok {
+		return fmt.Errorf("prober %q is already registered", name)
+	}
+	probers[name] = fn
+	config.RegisterExtension(name, configFactory)
+	return nil
+}
+
+// LookupProber returns the prober registered under a name.
+func LookupProber(name string) (ProbeFn, bool) {
+	probersMtx.RLock()
+	defer probersMtx.RUnlock()
+	fn, ok := probers[name]
+	return fn, ok
+}
+
 // Call is a function that calls the prober
 func Call(target string, moduleName string, c *config.Config, logger log.Logger, rh *ResultHistory, timeoutOffset float64) (helper.ProbeResult, error) {
 	module, ok := c.Modules[moduleName]
 	if !ok {
 		level.Debug(logger).Log("msg", "Unknown module", "module", moduleName)
-		moduleUnknownCounter.Add(1)
 		return nil, fmt.Errorf("unknown module %q", moduleName)
 	}
 
@@ -63,39 +107,124 @@ func Call(target string, moduleName string, c *config.Config, logger log.Logger,
 	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds*float64(time.Second)))
 	defer cancel()
 
-	prober, ok := Probers[module.Prober]
-	if !ok {
+	prober, ok := LookupProber(module.Prober)
+	if !ok && module.Prober != "composite" {
 		return nil, fmt.Errorf("unknown prober %q", module.Prober)
 	}
 
 	sl := newScrapeLogger(logger, moduleName, target)
 	level.Info(sl).Log("msg", "Beginning probe", "probe", module.Prober, "timeout_seconds", timeoutSeconds)
 
-	probeSuccessGauge := prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "probe_success",
-		Help: "Displays whether or not the probe was a success",
-	})
-	probeDurationGauge := prometheus.NewGauge(prometheus.GaugeOpts{
-		Name: "probe_duration_seconds",
-		Help: "Returns how long the probe took to complete in seconds",
-	})
+	ctx, span := probeTracer().Start(ctx, "probe "+module.Prober, trace.WithAttributes(
+		attribute.String("probe.target", target),
+		attribute.String("probe.module", moduleName),
+		attribute.String("probe.prober", module.Prober),
+	))
+	start := time.Now()
+	var (
+		registry *prometheus.Registry
+		success  bool
+		reason   string
+	)
+	if module.Prober == "composite" {
+		registry = prometheus.NewRegistry()
+		success = probeComposite(ctx, target, module.Composite, c, registry, sl, logger)
+	} else {
+		registry, success, reason = probeWithRetry(ctx, prober, target, module, sl)
+	}
+	endProbeSpan(span, success, reason)
+	return finishProbe(moduleName, target, &module, sl, registry, rh, success, reason, start, time.Since(start))
+}
+
+// CallBatch pings all targets in a single sweep of an icmp_batch module and
+// returns the result of each target. The module timeout applies to the whole
+// sweep.
+func CallBatch(targets []string, moduleName string, c *config.Config, logger log.Logger, rh *ResultHistory, timeoutOffset float64) (map[string]helper.ProbeResult, error) {
+	module, ok := c.Modules[moduleName]
+	if !ok {
+		level.Debug(logger).Log("msg", "Unknown module", "module", moduleName)
+		return nil, fmt.Errorf("unknown module %q", moduleName)
+	}
+	if module.Prober != "icmp_batch" {
+		return nil, fmt.Errorf("module %q uses prober %q, batches require icmp_batch", moduleName, module.Prober)
+	}
+
+	timeoutSeconds, err := getTimeout(nil, module, timeoutOffset)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeoutSeconds*float64(time.Second)))
+	defer cancel()
+
+	type batchProbe struct {
+		sl     *scrapeLogger
+		target *batchTarget
+	}
+	probes := make([]batchProbe, 0, len(targets))
+	batch := make([]*batchTarget, 0, len(targets))
+	seen := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+		sl := newScrapeLogger(logger, moduleName, target)
+		level.Info(sl).Log("msg", "Beginning probe", "probe", module.Prober, "timeout_seconds", timeoutSeconds, "batch_size", len(targets))
+		t := newBatchTarget(target, prometheus.NewRegistry(), sl)
+		probes = append(probes, batchProbe{sl: sl, target: t})
+		batch = append(batch, t)
+	}
 
 	start := time.Now()
-	registry := prometheus.NewRegistry()
+	runICMPSweep(ctx, module.ICMPBatch, batch)
+	duration := time.Since(start)
+
+	results := make(map[string]helper.ProbeResult, len(probes))
+	for _, p := range probes {
+		success := p.target.finish()
+		var reason string
+		if !success {
+			reason = attemptFailureReason(ctx, p.target.registry)
+		}
+		result, err := finishProbe(moduleName, p.target.target, &module, p.sl, p.target.registry, rh, success, reason, start, duration)
+		if err != nil {
+			return nil, err
+		}
+		results[p.target.target] = result
+	}
+	return results, nil
+}
+
+// finishProbe adds the success and duration of a probe to its registry,
+// records it in the history and returns the gathered result.
+func finishProbe(moduleName, target string, module *config.Module, sl *scrapeLogger, registry *prometheus.Registry, rh *ResultHistory, success bool, reason string, start time.Time, duration time.Duration) (helper.ProbeResult, error) {
+	probeSuccessGauge := prometheus.NewGauge(probeSuccessGaugeOpts)
+	probeDurationGauge := prometheus.NewGauge(probeDurationGaugeOpts)
 	registry.MustRegister(probeSuccessGauge)
 	registry.MustRegister(probeDurationGauge)
-	success := prober(ctx, target, module, registry, sl)
-	duration := time.Since(start).Seconds()
-	probeDurationGauge.Set(duration)
+
+	probeDurationGauge.Set(duration.Seconds())
 	if success {
 		probeSuccessGauge.Set(1)
-		level.Info(sl).Log("msg", "Probe succeeded", "duration_seconds", duration)
+		level.Info(sl).Log("msg", "Probe succeeded", "duration_seconds", duration.Seconds())
 	} else {
-		level.Error(sl).Log("msg", "Probe failed", "duration_seconds", duration)
+		level.Error(sl).Log("msg", "Probe failed", "duration_seconds", duration.Seconds(), "reason", reason)
 	}
 
-	debugOutput := DebugOutput(&module, &sl.buffer, registry)
-	rh.Add(moduleName, target, debugOutput, success)
+	debugOutput := DebugOutput(module, &sl.buffer, registry)
+	err := rh.Record(&Result{
+		ModuleName:    moduleName,
+		Target:        target,
+		DebugOutput:   debugOutput,
+		Success:       success,
+		Timestamp:     start,
+		Duration:      duration,
+		FailureReason: reason,
+	})
+	if err != nil {
+		level.Warn(sl.next).Log("msg", "Error storing probe result", "err", err)
+	}
 
 	// Gather metrics
 	metricFamilies, err := registry.Gather()
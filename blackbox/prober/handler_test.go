@@ -15,12 +15,19 @@ package prober
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/go-kit/log"
 	"github.com/prometheus/client_golang/prometheus"
 	pconfig "github.com/prometheus/common/config"
 
@@ -253,3 +260,194 @@ func TestTimeoutIsSetCorrectly(t *testing.T) {
 // 	}
 
 // }
+
+type greetingConfig struct {
+	Greeting string `yaml:"greeting"`
+	Repeat   int    `yaml:"repeat"`
+}
+
+func (c *greetingConfig) Validate() error {
+	if c.Repeat < 1 {
+		return errors.New("repeat must be at least 1")
+	}
+	return nil
+}
+
+func TestRegisterProber(t *testing.T) {
+	probeGreeting := func(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger log.Logger) bool {
+		cfg := module.Extension.Config.(*greetingConfig)
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "probe_greeting_length", Help: "Length of the greeting"})
+		registry.MustRegister(g)
+		g.Set(float64(len(strings.Repeat(cfg.Greeting, cfg.Repeat))))
+		return true
+	}
+	if err := RegisterProber("test_greeting", probeGreeting, func() interface{} { return &greetingConfig{Repeat: 1} }); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterProber("test_greeting", probeGreeting, nil); err == nil {
+		t.Errorf("Expected error registering a prober twice")
+	}
+	if err := RegisterProber("http", probeGreeting, nil); err == nil {
+		t.Errorf("Expected error registering a built-in prober")
+	}
+
+	// Lookups and registrations can happen concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := LookupProber("test_greeting"); !ok {
+				t.Errorf("Registered prober not found")
+			}
+			RegisterProber("test_greeting", probeGreeting, nil)
+		}()
+	}
+	wg.Wait()
+
+	load := func(module string) (*config.SafeConfig, error) {
+		file := filepath.Join(t.TempDir(), "blackbox.yml")
+		if err := os.WriteFile(file, []byte("modules:\n  greeting:\n"+module), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		sc := &config.SafeConfig{}
+		return sc, sc.ReloadConfig(file, nil)
+	}
+
+	sc, err := load("    prober: test_greeting\n    timeout: 1s\n    extension:\n      greeting: hello\n      repeat: 2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := Call("target", "greeting", sc.C, log.NewNopLogger(), &ResultHistory{MaxResults: 1}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success() {
+		t.Fatalf("Expected registered prober to succeed")
+	}
+	text, err := result.Text()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), "probe_greeting_length 10") {
+		t.Errorf("Expected metric of the registered prober, got %s", text)
+	}
+
+	for module, want := range map[string]string{
+		"    prober: test_greeting\n    extension:\n      repeat: 0\n":      "repeat must be at least 1",
+		"    prober: test_greeting\n    extension:\n      greting: hello\n": "field greting not found",
+		"    prober: http\n    extension:\n      greeting: hello\n":         `prober "http" does not take an extension configuration`,
+	} {
+		if _, err := load(module); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error containing %q, got %v", want, err)
+		}
+	}
+}
+
+func TestProbeRetry(t *testing.T) {
+	var requests int
+	var mu sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	module := func(retryOn ...string) config.Module {
+		return config.Module{
+			Prober:  "http",
+			Timeout: 5 * time.Second,
+			HTTP: config.HTTPProbe{
+				IPProtocol:       "ip4",
+				HTTPClientConfig: pconfig.DefaultHTTPClientConfig,
+			},
+			Retry: config.RetryPolicy{Attempts: 3, Backoff: 10 * time.Millisecond, RetryOn: retryOn},
+		}
+	}
+	testCases := []struct {
+		module   config.Module
+		success  bool
+		contains []string
+	}{
+		{
+			module:  module("assertion_failure"),
+			success: true,
+			contains: []string{
+				"probe_attempts 2",
+				`probe_last_attempt_failure_reason{reason="assertion_failure"} 0`,
+				"probe_http_status_code 200",
+			},
+		},
+		{
+			module:  module("connect_error", "timeout"),
+			success: false,
+			contains: []string{
+				"probe_attempts 1",
+				`probe_last_attempt_failure_reason{reason="assertion_failure"} 1`,
+				"probe_http_status_code 503",
+			},
+		},
+	}
+	for i, tc := range testCases {
+		requests = 0
+		rh := &ResultHistory{MaxResults: 1}
+		cfg := &config.Config{Modules: map[string]config.Module{"http_retry": tc.module}}
+		result, err := Call(ts.URL, "http_retry", cfg, log.NewNopLogger(), rh, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Success() != tc.success {
+			t.Errorf("Test %d: expected success %v, got %v", i, tc.success, result.Success())
+		}
+		text, err := result.Text()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tc.contains {
+			if !strings.Contains(string(text), want) {
+				t.Errorf("Test %d: expected %q in metrics, got %s", i, want, text)
+			}
+		}
+		debugOutput := rh.List()[0].DebugOutput
+		if tc.success && strings.Count(debugOutput, "Beginning attempt") != 2 {
+			t.Errorf("Test %d: expected the logs of both attempts in the debug output, got %s", i, debugOutput)
+		}
+	}
+
+	// Attempts fail on connection errors until the attempts are used up.
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	registry, success, reason := probeWithRetry(context.Background(), ProbeTCP, addr, config.Module{
+		TCP:   config.TCPProbe{IPProtocol: "ip4"},
+		Retry: config.RetryPolicy{Attempts: 2, RetryOn: []string{"connect_error"}},
+	}, log.NewNopLogger())
+	if success {
+		t.Fatalf("Expected probe of a closed port to fail")
+	}
+	if reason != "connect_error" {
+		t.Errorf("Expected failure reason connect_error, got %q", reason)
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRegistryResults(map[string]float64{"probe_attempts": 2}, mfs, t)
+	for _, mf := range mfs {
+		if mf.GetName() != "probe_last_attempt_failure_reason" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if want := m.GetLabel()[0].GetValue() == "connect_error"; (m.GetGauge().GetValue() == 1) != want {
+				t.Errorf("Unexpected failure reason %s: %v", m.GetLabel()[0].GetValue(), m.GetGauge().GetValue())
+			}
+		}
+	}
+}
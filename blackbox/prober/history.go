@@ -14,85 +14,26 @@
 package prober
 
 import (
-	"sync"
+	"github.com/abialemuel/prometheus-exporter/helper"
 )
 
-// Result contains the result of the execution of a probe
-type Result struct {
-	Id          int64
-	ModuleName  string
-	Target      string
-	DebugOutput string
-	Success     bool
-}
-
-// ResultHistory contains two history slices: `results` contains most recent `maxResults` results.
-// After they expire out of `results`, failures will be saved in `preservedFailedResults`. This
-// ensures that we are always able to see debug information about recent failures.
-type ResultHistory struct {
-	mu                     sync.Mutex
-	nextId                 int64
-	results                []*Result
-	preservedFailedResults []*Result
-	MaxResults             uint
-}
-
-// Add a result to the history.
-func (rh *ResultHistory) Add(moduleName, target, debugOutput string, success bool) {
-	rh.mu.Lock()
-	defer rh.mu.Unlock()
-
-	r := &Result{
-		Id:          rh.nextId,
-		ModuleName:  moduleName,
-		Target:      target,
-		DebugOutput: debugOutput,
-		Success:     success,
-	}
-	rh.nextId++
-
-	rh.results = append(rh.results, r)
-	if uint(len(rh.results)) > rh.MaxResults {
-		// If we are about to remove a failure, add it to the failed result history, then
-		// remove the oldest failed result, if needed.
-		if !rh.results[0].Success {
-			rh.preservedFailedResults = append(rh.preservedFailedResults, rh.results[0])
-			if uint(len(rh.preservedFailedResults)) > rh.MaxResults {
-				preservedFailedResults := make([]*Result, len(rh.preservedFailedResults)-1)
-				copy(preservedFailedResults, rh.preservedFailedResults[1:])
-				rh.preservedFailedResults = preservedFailedResults
-			}
-		}
-		results := make([]*Result, len(rh.results)-1)
-		copy(results, rh.results[1:])
-		rh.results = results
-	}
-}
-
-// List returns a list of all results.
-func (rh *ResultHistory) List() []*Result {
-	rh.mu.Lock()
-	defer rh.mu.Unlock()
+// The result history is shared with the SNMP exporter, see the helper
+// package for the documentation of these types.
+type (
+	Result        = helper.Result
+	ResultHistory = helper.ResultHistory
+	HistoryStore  = helper.HistoryStore
+	HistoryQuery  = helper.HistoryQuery
+	FileStore     = helper.FileStore
+)
 
-	// Results in each slice are disjoint. We can simply concatenate the results.
-	return append(rh.preservedFailedResults[:], rh.results...)
+// NewResultHistory returns a history keeping the results of the given
+// store, or in memory only if store is nil.
+func NewResultHistory(maxResults uint, store HistoryStore) (*ResultHistory, error) {
+	return helper.NewResultHistory(maxResults, store)
 }
 
-// Get returns a given result.
-func (rh *ResultHistory) Get(id int64) *Result {
-	rh.mu.Lock()
-	defer rh.mu.Unlock()
-
-	for _, r := range rh.preservedFailedResults {
-		if r.Id == id {
-			return r
-		}
-	}
-	for _, r := range rh.results {
-		if r.Id == id {
-			return r
-		}
-	}
-
-	return nil
+// OpenFileStore opens a history store appending results to a file.
+func OpenFileStore(path string, maxBytes int64, maxBackups int) (*FileStore, error) {
+	return helper.OpenFileStore(path, maxBytes, maxBackups)
 }
@@ -117,7 +117,9 @@ func matchRegularExpressionsOnHeaders(header http.Header, httpConfig config.HTTP
 
 // roundTripTrace holds timings for a single HTTP roundtrip.
 type roundTripTrace struct {
+	url           string
 	tls           bool
+	requested     time.Time
 	start         time.Time
 	dnsDone       time.Time
 	connectDone   time.Time
@@ -153,7 +155,7 @@ func newTransport(rt, noServerName http.RoundTripper, logger log.Logger) *transp
 func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	level.Info(t.logger).Log("msg", "Making HTTP request", "url", req.URL.String(), "host", req.Host)
 
-	trace := &roundTripTrace{}
+	trace := &roundTripTrace{url: req.URL.String(), requested: time.Now()}
 	if req.URL.Scheme == "https" {
 		trace.tls = true
 	}
@@ -606,6 +608,7 @@ func ProbeHTTP(ctx context.Context, target string, module config.Module, registr
 			"tlsDone", trace.tlsDone,
 			"end", trace.end,
 		)
+		recordRoundTrip(ctx, i, trace)
 		// We get the duration for the first request from chooseProtocol.
 		if i != 0 {
 			durationGaugeVec.WithLabelValues("resolve").Add(trace.dnsDone.Sub(trace.start).Seconds())
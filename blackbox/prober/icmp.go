@@ -230,6 +230,7 @@ func ProbeICMP(ctx context.Context, target string, module config.Module, registr
 	}
 
 	durationGaugeVec.WithLabelValues("setup").Add(time.Since(setupStart).Seconds())
+	recordPhase(ctx, "setup", setupStart, time.Now(), nil)
 	level.Info(logger).Log("msg", "Writing out packet")
 	rttStart := time.Now()
 
@@ -365,6 +366,7 @@ func ProbeICMP(ctx context.Context, target string, module config.Module, registr
 		}
 		if bytes.Equal(rb[:n], wb) {
 			durationGaugeVec.WithLabelValues("rtt").Add(time.Since(rttStart).Seconds())
+			recordPhase(ctx, "rtt", rttStart, time.Now(), nil)
 			if hopLimit >= 0 {
 				hopLimitGauge.Set(hopLimit)
 				registry.MustRegister(hopLimitGauge)
@@ -0,0 +1,421 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+// batchResolveConcurrency limits the number of concurrent DNS lookups of a
+// sweep.
+const batchResolveConcurrency = 32
+
+// batchTarget is one target of an ICMP sweep. Every target has its own
+// registry and logger, so that it produces the same result as a single probe.
+type batchTarget struct {
+	target   string
+	registry *prometheus.Registry
+	logger   log.Logger
+	dst      *net.IPAddr
+
+	sent int
+	// rtts is written by the receivers while the sweep runs.
+	rtts []time.Duration
+
+	durationGaugeVec *prometheus.GaugeVec
+	sentGauge        prometheus.Gauge
+	receivedGauge    prometheus.Gauge
+	rttGaugeVec      *prometheus.GaugeVec
+}
+
+func newBatchTarget(target string, registry *prometheus.Registry, logger log.Logger) *batchTarget {
+	t := &batchTarget{
+		target:   target,
+		registry: registry,
+		logger:   logger,
+
+		durationGaugeVec: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_icmp_duration_seconds",
+			Help: "Duration of icmp request by phase",
+		}, []string{"phase"}),
+
+		sentGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_icmp_packets_sent",
+			Help: "Returns the number of echo requests sent to the target",
+		}),
+
+		receivedGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_icmp_packets_received",
+			Help: "Returns the number of echo replies received from the target",
+		}),
+
+		rttGaugeVec: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_icmp_rtt_seconds",
+			Help: "Returns the round trip time of the echo replies",
+		}, []string{"aggregate"}),
+	}
+	for _, lv := range []string{"resolve", "rtt"} {
+		t.durationGaugeVec.WithLabelValues(lv)
+	}
+	registry.MustRegister(t.durationGaugeVec)
+	registry.MustRegister(t.sentGauge)
+	registry.MustRegister(t.receivedGauge)
+	registry.MustRegister(t.rttGaugeVec)
+	return t
+}
+
+// finish exports the results of the target once the sweep is over. The
+// target is up when any echo request was answered.
+func (t *batchTarget) finish() bool {
+	t.sentGauge.Set(float64(t.sent))
+	t.receivedGauge.Set(float64(len(t.rtts)))
+	if len(t.rtts) == 0 {
+		if t.dst != nil {
+			level.Warn(t.logger).Log("msg", "No reply received", "sent", t.sent)
+		}
+		return false
+	}
+
+	var sum time.Duration
+	min, max := t.rtts[0], t.rtts[0]
+	for _, rtt := range t.rtts {
+		sum += rtt
+		if rtt < min {
+			min = rtt
+		}
+		if rtt > max {
+			max = rtt
+		}
+	}
+	avg := sum / time.Duration(len(t.rtts))
+	t.durationGaugeVec.WithLabelValues("rtt").Set(avg.Seconds())
+	t.rttGaugeVec.WithLabelValues("min").Set(min.Seconds())
+	t.rttGaugeVec.WithLabelValues("avg").Set(avg.Seconds())
+	t.rttGaugeVec.WithLabelValues("max").Set(max.Seconds())
+	level.Info(t.logger).Log("msg", "Received replies", "sent", t.sent, "received", len(t.rtts), "rtt_avg", avg)
+	return true
+}
+
+// sweepConn is a socket shared by all targets of one address family.
+type sweepConn struct {
+	conn       *icmp.PacketConn
+	isIPv6     bool
+	privileged bool
+}
+
+func (c *sweepConn) dst(addr *net.IPAddr) net.Addr {
+	if c.privileged {
+		return addr
+	}
+	return &net.UDPAddr{IP: addr.IP, Zone: addr.Zone}
+}
+
+// listenSweep opens an unprivileged socket where supported and falls back to
+// a raw socket.
+func listenSweep(isIPv6 bool, srcIP net.IP) (*sweepConn, error) {
+	udpNetwork, rawNetwork, listenAddr := "udp4", "ip4:icmp", "0.0.0.0"
+	if isIPv6 {
+		udpNetwork, rawNetwork, listenAddr = "udp6", "ip6:ipv6-icmp", "::"
+	}
+	if srcIP != nil && (srcIP.To4() == nil) == isIPv6 {
+		listenAddr = srcIP.String()
+	}
+	// Unprivileged sockets are supported on Darwin and Linux only.
+	if runtime.GOOS == "darwin" || runtime.GOOS == "linux" {
+		if conn, err := icmp.ListenPacket(udpNetwork, listenAddr); err == nil {
+			return &sweepConn{conn: conn, isIPv6: isIPv6}, nil
+		}
+	}
+	conn, err := icmp.ListenPacket(rawNetwork, listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &sweepConn{conn: conn, isIPv6: isIPv6, privileged: true}, nil
+}
+
+type pendingEcho struct {
+	target *batchTarget
+	sent   time.Time
+}
+
+// icmpSweep pings a set of targets over one socket per address family and
+// matches the replies to the targets by sequence number. Sequence numbers are
+// 16 bits, so at most 65535 requests can be outstanding at a time.
+type icmpSweep struct {
+	probe config.ICMPBatchProbe
+
+	mu      sync.Mutex
+	pending map[int]pendingEcho
+	allSent bool
+	done    chan struct{}
+}
+
+// checkDone closes done once every request was sent and answered. It must be
+// called with mu held.
+func (s *icmpSweep) checkDone() {
+	if s.allSent && len(s.pending) == 0 {
+		select {
+		case <-s.done:
+		default:
+			close(s.done)
+		}
+	}
+}
+
+func (s *icmpSweep) reply(seq int, peer net.IP, received time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[seq]
+	if !ok || !p.target.dst.IP.Equal(peer) {
+		return
+	}
+	delete(s.pending, seq)
+	p.target.rtts = append(p.target.rtts, received.Sub(p.sent))
+	s.checkDone()
+}
+
+// receive reads replies until the read deadline of the socket passes. It
+// must not log, the loggers of the targets are not safe for concurrent use.
+func (s *icmpSweep) receive(c *sweepConn) {
+	proto, replyType := protocolICMP, icmp.Type(ipv4.ICMPTypeEchoReply)
+	if c.isIPv6 {
+		proto, replyType = protocolICMPv6, ipv6.ICMPTypeEchoReply
+	}
+	// Unprivileged cannot set IDs on Linux, the kernel only passes the
+	// replies for the socket.
+	idUnknown := !c.privileged && runtime.GOOS == "linux"
+
+	rb := make([]byte, 65536)
+	for {
+		n, peer, err := c.conn.ReadFrom(rb)
+		received := time.Now()
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		msg, err := icmp.ParseMessage(proto, rb[:n])
+		if err != nil || msg.Type != replyType {
+			continue
+		}
+		echo, ok := msg.Body.(*icmp.Echo)
+		if !ok || (!idUnknown && echo.ID != icmpID) {
+			continue
+		}
+		var peerIP net.IP
+		switch p := peer.(type) {
+		case *net.IPAddr:
+			peerIP = p.IP
+		case *net.UDPAddr:
+			peerIP = p.IP
+		}
+		s.reply(echo.Seq, peerIP, received)
+	}
+}
+
+// send sends Count echo requests to every resolved target, spaced by Interval
+// per target and by the rate limit overall.
+func (s *icmpSweep) send(ctx context.Context, targets []*batchTarget, conns map[bool]*sweepConn) {
+	defer func() {
+		s.mu.Lock()
+		s.allSent = true
+		s.checkDone()
+		s.mu.Unlock()
+	}()
+
+	data := []byte("Prometheus Blackbox Exporter")
+	if s.probe.PayloadSize != 0 {
+		data = make([]byte, s.probe.PayloadSize)
+		copy(data, "Prometheus Blackbox Exporter")
+	}
+
+	ticker := time.NewTicker(time.Second / time.Duration(s.probe.RateLimit))
+	defer ticker.Stop()
+	var roundStart time.Time
+	for round := 0; round < s.probe.Count; round++ {
+		if round > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Until(roundStart.Add(s.probe.Interval))):
+			}
+		}
+		roundStart = time.Now()
+		for _, t := range targets {
+			c := conns[t.dst != nil && t.dst.IP.To4() == nil]
+			if t.dst == nil || c == nil {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			requestType := icmp.Type(ipv4.ICMPTypeEcho)
+			if c.isIPv6 {
+				requestType = ipv6.ICMPTypeEchoRequest
+			}
+			seq := int(getICMPSequence())
+			wm := icmp.Message{
+				Type: requestType,
+				Body: &icmp.Echo{ID: icmpID, Seq: seq, Data: data},
+			}
+			wb, err := wm.Marshal(nil)
+			if err != nil {
+				level.Error(t.logger).Log("msg", "Error marshalling packet", "err", err)
+				continue
+			}
+
+			s.mu.Lock()
+			s.pending[seq] = pendingEcho{target: t, sent: time.Now()}
+			s.mu.Unlock()
+			if _, err := c.conn.WriteTo(wb, c.dst(t.dst)); err != nil {
+				s.mu.Lock()
+				delete(s.pending, seq)
+				s.mu.Unlock()
+				level.Warn(t.logger).Log("msg", "Error writing to socket", "err", err)
+				continue
+			}
+			t.sent++
+		}
+	}
+}
+
+// resolveBatchTargets resolves all targets concurrently. Targets that cannot
+// be resolved keep a nil destination.
+func resolveBatchTargets(ctx context.Context, probe config.ICMPBatchProbe, targets []*batchTarget) {
+	sem := make(chan struct{}, batchResolveConcurrency)
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		go func(t *batchTarget) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			dstIPAddr, lookupTime, err := chooseProtocol(ctx, probe.IPProtocol, probe.IPProtocolFallback, t.target, t.registry, t.logger)
+			if err != nil {
+				level.Warn(t.logger).Log("msg", "Error resolving address", "err", err)
+				return
+			}
+			t.durationGaugeVec.WithLabelValues("resolve").Add(lookupTime)
+			t.dst = dstIPAddr
+		}(t)
+	}
+	wg.Wait()
+}
+
+// runICMPSweep resolves and pings all targets, and waits until every request
+// was answered or the context is done.
+func runICMPSweep(ctx context.Context, probe config.ICMPBatchProbe, targets []*batchTarget) {
+	if probe.Count < 1 {
+		probe.Count = 1
+	}
+	if probe.RateLimit < 1 {
+		probe.RateLimit = config.DefaultICMPBatchProbe.RateLimit
+	}
+	if probe.RateLimit > int(time.Second) {
+		probe.RateLimit = int(time.Second)
+	}
+
+	var srcIP net.IP
+	if len(probe.SourceIPAddress) > 0 {
+		if srcIP = net.ParseIP(probe.SourceIPAddress); srcIP == nil {
+			for _, t := range targets {
+				level.Error(t.logger).Log("msg", "Error parsing source ip address", "srcIP", probe.SourceIPAddress)
+			}
+			return
+		}
+	}
+
+	resolveBatchTargets(ctx, probe, targets)
+
+	conns := make(map[bool]*sweepConn)
+	for _, t := range targets {
+		if t.dst == nil {
+			continue
+		}
+		isIPv6 := t.dst.IP.To4() == nil
+		if _, ok := conns[isIPv6]; ok {
+			if conns[isIPv6] == nil {
+				level.Error(t.logger).Log("msg", "Error listening to socket")
+			}
+			continue
+		}
+		c, err := listenSweep(isIPv6, srcIP)
+		if err != nil {
+			level.Error(t.logger).Log("msg", "Error listening to socket", "err", err)
+			conns[isIPv6] = nil
+			continue
+		}
+		defer c.conn.Close()
+		conns[isIPv6] = c
+	}
+
+	s := &icmpSweep{
+		probe:   probe,
+		pending: make(map[int]pendingEcho),
+		done:    make(chan struct{}),
+	}
+	deadline, _ := ctx.Deadline()
+	var wg sync.WaitGroup
+	for _, c := range conns {
+		if c == nil {
+			continue
+		}
+		c.conn.SetReadDeadline(deadline)
+		wg.Add(1)
+		go func(c *sweepConn) {
+			defer wg.Done()
+			s.receive(c)
+		}(c)
+	}
+
+	s.send(ctx, targets, conns)
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+	}
+	// Wake up the receivers.
+	for _, c := range conns {
+		if c != nil {
+			c.conn.SetReadDeadline(time.Now())
+		}
+	}
+	wg.Wait()
+}
+
+// ProbeICMPBatch pings a single target with the icmp_batch settings. Many
+// targets are pinged in one sweep with CallBatch.
+func ProbeICMPBatch(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger log.Logger) (success bool) {
+	t := newBatchTarget(target, registry, logger)
+	runICMPSweep(ctx, module.ICMPBatch, []*batchTarget{t})
+	return t.finish()
+}
@@ -0,0 +1,129 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+func TestICMPSweepReply(t *testing.T) {
+	a := newBatchTarget("a", prometheus.NewRegistry(), log.NewNopLogger())
+	a.dst = &net.IPAddr{IP: net.ParseIP("192.0.2.1")}
+	b := newBatchTarget("b", prometheus.NewRegistry(), log.NewNopLogger())
+	b.dst = &net.IPAddr{IP: net.ParseIP("192.0.2.2")}
+
+	s := &icmpSweep{pending: make(map[int]pendingEcho), done: make(chan struct{})}
+	sent := time.Now()
+	s.pending[1] = pendingEcho{target: a, sent: sent}
+	s.pending[2] = pendingEcho{target: b, sent: sent}
+	s.pending[3] = pendingEcho{target: a, sent: sent}
+	s.allSent = true
+
+	// Replies from the wrong peer or for unknown sequences are ignored.
+	s.reply(1, net.ParseIP("192.0.2.2"), sent.Add(time.Millisecond))
+	s.reply(7, net.ParseIP("192.0.2.1"), sent.Add(time.Millisecond))
+	if len(a.rtts) != 0 || len(s.pending) != 3 {
+		t.Fatalf("Unexpected match of a foreign reply")
+	}
+
+	s.reply(2, net.ParseIP("192.0.2.2"), sent.Add(2*time.Millisecond))
+	s.reply(1, net.ParseIP("192.0.2.1"), sent.Add(5*time.Millisecond))
+	// Duplicates are only counted once.
+	s.reply(1, net.ParseIP("192.0.2.1"), sent.Add(6*time.Millisecond))
+	select {
+	case <-s.done:
+		t.Fatalf("Sweep done with a reply outstanding")
+	default:
+	}
+	s.reply(3, net.ParseIP("192.0.2.1"), sent.Add(7*time.Millisecond))
+	select {
+	case <-s.done:
+	default:
+		t.Fatalf("Sweep not done after the last reply")
+	}
+
+	a.sent, b.sent = 2, 1
+	if !a.finish() || !b.finish() {
+		t.Fatalf("Expected both targets to succeed")
+	}
+	mfs, err := a.registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRegistryResults(map[string]float64{
+		"probe_icmp_packets_sent":     2,
+		"probe_icmp_packets_received": 2,
+	}, mfs, t)
+	if len(a.rtts) != 2 || a.rtts[0] != 5*time.Millisecond || a.rtts[1] != 7*time.Millisecond {
+		t.Errorf("Unexpected RTTs %v", a.rtts)
+	}
+}
+
+func TestCallBatch(t *testing.T) {
+	if !canPingLocalhost() {
+		t.Skip("Neither unprivileged nor raw ICMP sockets are available")
+	}
+
+	c := &config.Config{Modules: map[string]config.Module{
+		"icmp_batch": {
+			Prober:  "icmp_batch",
+			Timeout: 5 * time.Second,
+			ICMPBatch: config.ICMPBatchProbe{
+				IPProtocol:         "ip4",
+				IPProtocolFallback: false,
+				Count:              2,
+				Interval:           20 * time.Millisecond,
+				RateLimit:          1000,
+			},
+		},
+		"icmp": {Prober: "icmp", Timeout: time.Second},
+	}}
+	rh := &ResultHistory{MaxResults: 10}
+
+	if _, err := CallBatch([]string{"127.0.0.1"}, "icmp", c, log.NewNopLogger(), rh, 0); err == nil {
+		t.Errorf("Expected error for a module without the icmp_batch prober")
+	}
+
+	targets := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.1"}
+	results, err := CallBatch(targets, "icmp_batch", c, log.NewNopLogger(), rh, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results for 3 distinct targets, got %d", len(results))
+	}
+	for target, result := range results {
+		if !result.Success() {
+			t.Errorf("Expected ping of %s to succeed", target)
+		}
+		text, err := result.Text()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(text), "probe_icmp_packets_received 2\n") {
+			t.Errorf("Expected 2 replies from %s, got:\n%s", target, text)
+		}
+	}
+	if n := len(rh.List()); n != 3 {
+		t.Errorf("Expected 3 results in the history, got %d", n)
+	}
+}
@@ -15,6 +15,10 @@ package prober
 
 import (
 	"context"
+	"math"
+	"net"
+	"runtime"
+	"sort"
 	"time"
 
 	"github.com/abialemuel/prometheus-exporter/blackbox/config"
@@ -22,28 +26,92 @@ import (
 	"github.com/go-kit/log/level"
 	ping "github.com/prometheus-community/pro-bing"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/icmp"
 )
 
-func ProbeICMPQoS(_ context.Context, target string, module config.Module, registry *prometheus.Registry, logger log.Logger) (success bool) {
+// qosStats accumulates the replies of a ping run in arrival order.
+type qosStats struct {
+	rtts       []time.Duration
+	seen       map[int]bool
+	maxSeq     int
+	outOfOrder int
+	duplicates int
+
+	// jitter is the RFC 3550 interarrival jitter in nanoseconds.
+	jitter  float64
+	lastRTT time.Duration
+
+	// Absolute RTT differences between consecutive replies.
+	diffCount int
+	diffSum   time.Duration
+	diffMin   time.Duration
+	diffMax   time.Duration
+}
+
+func newQoSStats() *qosStats {
+	return &qosStats{seen: make(map[int]bool), maxSeq: -1}
+}
+
+// observe records a reply with the given sequence number and RTT.
+func (s *qosStats) observe(seq int, rtt time.Duration) {
+	if s.seen[seq] {
+		s.duplicates++
+		return
+	}
+	s.seen[seq] = true
+	if seq < s.maxSeq {
+		s.outOfOrder++
+	} else {
+		s.maxSeq = seq
+	}
+
+	if len(s.rtts) > 0 {
+		// With a common sender clock the difference of the transit times of
+		// two packets (RFC 3550 section 6.4.1) equals the difference of their
+		// RTTs.
+		d := rtt - s.lastRTT
+		if d < 0 {
+			d = -d
+		}
+		s.jitter += (float64(d) - s.jitter) / 16
+		if s.diffCount == 0 || d < s.diffMin {
+			s.diffMin = d
+		}
+		if d > s.diffMax {
+			s.diffMax = d
+		}
+		s.diffSum += d
+		s.diffCount++
+	}
+	s.lastRTT = rtt
+	s.rtts = append(s.rtts, rtt)
+}
+
+// percentile returns the nearest-rank percentile of the RTTs, p is in (0, 1].
+func (s *qosStats) percentile(p float64) time.Duration {
+	if len(s.rtts) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(s.rtts))
+	copy(sorted, s.rtts)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
+
+func ProbeICMPQoS(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger log.Logger) (success bool) {
 	var (
 		// durations
 		startDuration time.Time
 		endDuration   time.Time
 		totalDuration time.Duration
 
-		// jitters
-		prevTime  time.Duration
-		jitter    time.Duration
-		jitterSum time.Duration
-		jitterMax time.Duration
-		jitterMin time.Duration
-
-		rttMapping map[int]time.Duration
-		seqMax     int
+		stats = newQoSStats()
 	)
 
-	rttMapping = make(map[int]time.Duration)
-
 	// start duration benchmark
 	startDuration = time.Now()
 
@@ -62,7 +130,7 @@ func ProbeICMPQoS(_ context.Context, target string, module config.Module, regist
 		Name: "probe_qos_latency",
 		Help: "Probe QoS Latency Gauge (all are in milliseconds)",
 	}, []string{"aggregate"})
-	for _, lv := range []string{"total", "min", "max", "avg", "standard_deviation"} {
+	for _, lv := range []string{"total", "min", "max", "avg", "standard_deviation", "p50", "p90", "p99"} {
 		probeQosLatencyGauge.WithLabelValues(lv)
 	}
 
@@ -70,7 +138,7 @@ func ProbeICMPQoS(_ context.Context, target string, module config.Module, regist
 		Name: "probe_qos_packet_loss_gauge",
 		Help: "Probe QoS Latency Packet Loss",
 	}, []string{"total"})
-	for _, lv := range []string{"sent", "received", "loss", "loss_percentage"} {
+	for _, lv := range []string{"sent", "received", "loss", "loss_percentage", "duplicate", "out_of_order"} {
 		probeQoSPacketLoss.WithLabelValues(lv)
 	}
 
@@ -84,7 +152,7 @@ func ProbeICMPQoS(_ context.Context, target string, module config.Module, regist
 
 	var probeQoSJitter = prometheus.NewGauge(prometheus.GaugeOpts{
 		Name: "probe_qos_jitter",
-		Help: "Jitter Calculations and Aggregates (in microseconds)",
+		Help: "RFC 3550 interarrival jitter of the replies (in microseconds)",
 	})
 
 	var probeQoSPacketCount = prometheus.NewGauge(prometheus.GaugeOpts{
@@ -92,14 +160,65 @@ func ProbeICMPQoS(_ context.Context, target string, module config.Module, regist
 		Help: "Total number of tested data to be sent to target",
 	})
 
-	_ = level.Debug(logger).Log("msg", "Set Pinger")
-	pinger, err := ping.NewPinger(target)
+	var probeQoSRTTHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:                        "probe_qos_rtt_seconds",
+		Help:                        "Round trip time of the replies",
+		Buckets:                     prometheus.ExponentialBuckets(0.0005, 2, 14),
+		NativeHistogramBucketFactor: 1.1,
+	})
+
+	var probeQoSRFactor = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_qos_r_factor",
+		Help: "ITU-T G.107 E-model transmission rating estimated for the configured codec",
+	})
+
+	var probeQoSMOS = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_qos_mos",
+		Help: "Mean opinion score estimated from the R-factor for the configured codec",
+	})
+
+	dstIPAddr, _, err := chooseProtocol(ctx, module.ICMPQOS.IPProtocol, module.ICMPQOS.IPProtocolFallback, target, registry, logger)
 	if err != nil {
-		_ = level.Error(logger).Log("msg", "set pinger failed", "err", err)
+		_ = level.Error(logger).Log("msg", "Error resolving address", "err", err)
 		return false
 	}
 
-	pinger.SetPrivileged(true)
+	_ = level.Debug(logger).Log("msg", "Set Pinger")
+	pinger := ping.New(dstIPAddr.String())
+	pinger.SetIPAddr(dstIPAddr)
+
+	if len(module.ICMPQOS.SourceIPAddress) > 0 {
+		srcIP := net.ParseIP(module.ICMPQOS.SourceIPAddress)
+		if srcIP == nil {
+			_ = level.Error(logger).Log("msg", "Error parsing source ip address", "srcIP", module.ICMPQOS.SourceIPAddress)
+			return false
+		}
+		_ = level.Info(logger).Log("msg", "Using source address", "srcIP", srcIP)
+		pinger.Source = srcIP.String()
+	}
+
+	// Unprivileged sockets are supported on Darwin and Linux only, they do not
+	// need CAP_NET_RAW.
+	privileged := true
+	if runtime.GOOS == "darwin" || runtime.GOOS == "linux" {
+		network := "udp4"
+		if dstIPAddr.IP.To4() == nil {
+			network = "udp6"
+		}
+		// "udp" here means unprivileged -- not the protocol "udp".
+		conn, err := icmp.ListenPacket(network, pinger.Source)
+		if err != nil {
+			_ = level.Debug(logger).Log("msg", "Unable to do unprivileged listen on socket, will attempt privileged", "err", err)
+		} else {
+			conn.Close()
+			privileged = false
+		}
+	}
+	pinger.SetPrivileged(privileged)
+
+	// Always set the traffic class, the pinger marks packets as network
+	// control unless told otherwise.
+	pinger.SetTrafficClass(uint8(module.ICMPQOS.DSCP << 2))
 
 	pinger.Count = module.ICMPQOS.Count
 	pinger.Size = module.ICMPQOS.PacketSize // in bytes
@@ -116,94 +235,46 @@ func ProbeICMPQoS(_ context.Context, target string, module config.Module, regist
 	registry.MustRegister(probeQoSJitter)
 	registry.MustRegister(probeQoSJitterGauge)
 	registry.MustRegister(probeQoSPacketCount)
+	registry.MustRegister(probeQoSRTTHistogram)
 
-	pinger.OnRecvError = func(_ error) {
-		jitter = prevTime
-		jitter = pinger.Timeout - prevTime
-		if jitter < 0 {
-			jitter = -jitter
-		}
-		jitterSum += jitter
-		prevTime = pinger.Timeout
-		jitterMax = prevTime
+	codec, scoreVoice := codecProfiles[module.ICMPQOS.Codec]
+	if scoreVoice {
+		registry.MustRegister(probeQoSRFactor)
+		registry.MustRegister(probeQoSMOS)
 	}
 
 	pinger.OnRecv = func(pkt *ping.Packet) {
-		rttMapping[pkt.Seq] = pkt.Rtt
-		if pkt.Seq > seqMax {
-			seqMax = pkt.Seq
-		}
-		// jitter (in microseconds)
-		jitter = pkt.Rtt - prevTime
-		if jitter < 0 {
-			jitter = -jitter
-		}
-		if jitter > jitterMax {
-			jitterMax = jitter
-		}
-		if jitter != 0 && jitter < jitterMin {
-			jitterMin = jitter
-		}
-		jitterSum += jitter
+		_ = level.Debug(logger).Log("msg", "Ping Log",
+			"Sequence", pkt.Seq,
+			"RTT", pkt.Rtt,
+		)
+		stats.observe(pkt.Seq, pkt.Rtt)
+		probeQoSRTTHistogram.Observe(pkt.Rtt.Seconds())
+	}
 
-		prevTime = pkt.Rtt
+	pinger.OnDuplicateRecv = func(pkt *ping.Packet) {
+		stats.observe(pkt.Seq, pkt.Rtt)
 	}
 
 	pinger.OnFinish = func(s *ping.Statistics) {
-
-		var i int
-		var jitterCount int64
-		for i = 0; i <= seqMax; i++ {
-
-			if i == 0 {
-				jitterSum = 0
-				jitterMax = 0
-				jitterMin = pinger.Timeout
-			}
-			if rtt, ok := rttMapping[i]; ok {
-				_ = level.Debug(logger).Log("msg", "Ping Log",
-					"Sequence", i,
-					"RTT", rtt,
-				)
-				if i == 0 {
-					prevTime = rtt
-					continue
-				}
-				jitterCount++
-				jitter = prevTime - rtt
-				if jitter < 0 {
-					jitter = -jitter
-				}
-				jitterSum += jitter
-				if jitter < jitterMin {
-					jitterMin = jitter
-				}
-				if jitter > jitterMax {
-					jitterMax = jitter
-				}
-				prevTime = rtt
-			}
-		}
-
 		// probe_qos_packet_count
 		probeQoSPacketCount.Set(float64(pinger.Count))
 
-		probeQoSPacketLoss.WithLabelValues("sent").Add(float64(s.PacketsSent))
-		probeQoSPacketLoss.WithLabelValues("received").Add(float64(s.PacketsRecv))
-		probeQoSPacketLoss.WithLabelValues("loss").Add(float64(s.PacketsSent - s.PacketsRecv))
-		probeQoSPacketLoss.WithLabelValues("loss_percentage").Add(s.PacketLoss)
-
-		if jitterCount == 0 {
-			probeQoSJitter.Set(0)
-			probeQoSJitterGauge.WithLabelValues("total_diff").Add(0)
-			probeQoSJitterGauge.WithLabelValues("max_diff").Add(0)
-			probeQoSJitterGauge.WithLabelValues("min_diff").Add(0)
-
-			probeQosLatencyGauge.WithLabelValues("total").Add(0)
-			probeQosLatencyGauge.WithLabelValues("max").Add(0)
-			probeQosLatencyGauge.WithLabelValues("min").Add(0)
-			probeQosLatencyGauge.WithLabelValues("avg").Add(0)
-			probeQosLatencyGauge.WithLabelValues("standard_deviation").Add(0)
+		probeQoSPacketLoss.WithLabelValues("sent").Set(float64(s.PacketsSent))
+		probeQoSPacketLoss.WithLabelValues("received").Set(float64(s.PacketsRecv))
+		probeQoSPacketLoss.WithLabelValues("loss").Set(float64(s.PacketsSent - s.PacketsRecv))
+		probeQoSPacketLoss.WithLabelValues("loss_percentage").Set(s.PacketLoss)
+		probeQoSPacketLoss.WithLabelValues("duplicate").Set(float64(stats.duplicates))
+		probeQoSPacketLoss.WithLabelValues("out_of_order").Set(float64(stats.outOfOrder))
+
+		if scoreVoice {
+			r, mos := voiceQuality(codec, s.AvgRtt, time.Duration(stats.jitter), s.PacketLoss)
+			probeQoSRFactor.Set(r)
+			probeQoSMOS.Set(mos)
+			_ = level.Info(logger).Log("msg", "Voice quality estimate", "codec", module.ICMPQOS.Codec, "r_factor", r, "mos", mos)
+		}
+
+		if len(stats.rtts) == 0 {
 			_ = level.Info(logger).Log(
 				"msg", "ICMP Gauge summary",
 				"error", "100% packet loss",
@@ -211,20 +282,23 @@ func ProbeICMPQoS(_ context.Context, target string, module config.Module, regist
 
 			return
 		}
-		probeQoSJitter.Set(float64(jitterSum.Nanoseconds()) / float64(jitterCount*1000))
-		probeQoSJitterGauge.WithLabelValues("total_diff").Add(float64(jitterSum.Nanoseconds()) / 1000)
-		probeQoSJitterGauge.WithLabelValues("max_diff").Add(float64(jitterMax.Nanoseconds()) / 1000)
-		probeQoSJitterGauge.WithLabelValues("min_diff").Add(float64(jitterMin.Nanoseconds()) / 1000)
+		probeQoSJitter.Set(stats.jitter / 1000)
+		probeQoSJitterGauge.WithLabelValues("total_diff").Set(float64(stats.diffSum.Nanoseconds()) / 1000)
+		probeQoSJitterGauge.WithLabelValues("max_diff").Set(float64(stats.diffMax.Nanoseconds()) / 1000)
+		probeQoSJitterGauge.WithLabelValues("min_diff").Set(float64(stats.diffMin.Nanoseconds()) / 1000)
 
 		var probeQosLatencyGaugeTotal time.Duration
 		for _, rtt := range s.Rtts {
 			probeQosLatencyGaugeTotal += rtt
 		}
-		probeQosLatencyGauge.WithLabelValues("total").Add(probeQosLatencyGaugeTotal.Seconds() * 1000)
-		probeQosLatencyGauge.WithLabelValues("max").Add(s.MaxRtt.Seconds() * 1000)
-		probeQosLatencyGauge.WithLabelValues("min").Add(s.MinRtt.Seconds() * 1000)
-		probeQosLatencyGauge.WithLabelValues("avg").Add(s.AvgRtt.Seconds() * 1000)
-		probeQosLatencyGauge.WithLabelValues("standard_deviation").Add(s.StdDevRtt.Seconds() * 1000)
+		probeQosLatencyGauge.WithLabelValues("total").Set(probeQosLatencyGaugeTotal.Seconds() * 1000)
+		probeQosLatencyGauge.WithLabelValues("max").Set(s.MaxRtt.Seconds() * 1000)
+		probeQosLatencyGauge.WithLabelValues("min").Set(s.MinRtt.Seconds() * 1000)
+		probeQosLatencyGauge.WithLabelValues("avg").Set(s.AvgRtt.Seconds() * 1000)
+		probeQosLatencyGauge.WithLabelValues("standard_deviation").Set(s.StdDevRtt.Seconds() * 1000)
+		probeQosLatencyGauge.WithLabelValues("p50").Set(stats.percentile(0.5).Seconds() * 1000)
+		probeQosLatencyGauge.WithLabelValues("p90").Set(stats.percentile(0.9).Seconds() * 1000)
+		probeQosLatencyGauge.WithLabelValues("p99").Set(stats.percentile(0.99).Seconds() * 1000)
 
 		// Logging the result
 		_ = level.Info(logger).Log("msg", "ICMP Gauge summary",
@@ -233,6 +307,8 @@ func ProbeICMPQoS(_ context.Context, target string, module config.Module, regist
 			"packet_received", s.PacketsRecv,
 			"packet_loss", s.PacketsSent-s.PacketsRecv,
 			"packet_loss_percentage", s.PacketLoss,
+			"packet_duplicate", stats.duplicates,
+			"packet_out_of_order", stats.outOfOrder,
 
 			// latency
 			"latency_total", probeQosLatencyGaugeTotal,
@@ -240,12 +316,15 @@ func ProbeICMPQoS(_ context.Context, target string, module config.Module, regist
 			"latency_min", s.MinRtt,
 			"latency_avg", s.AvgRtt,
 			"latency_std_deviation", s.StdDevRtt,
+			"latency_p50", stats.percentile(0.5),
+			"latency_p90", stats.percentile(0.9),
+			"latency_p99", stats.percentile(0.99),
 
 			// jitters
-			"jitters", jitter,
-			"jitter_max", jitterMax,
-			"jitter_min", jitterMin,
-			"jitter_total", jitterSum,
+			"jitter", time.Duration(stats.jitter),
+			"jitter_max", stats.diffMax,
+			"jitter_min", stats.diffMin,
+			"jitter_total", stats.diffSum,
 		)
 
 		// probe_duration_seconds
@@ -255,7 +334,7 @@ func ProbeICMPQoS(_ context.Context, target string, module config.Module, regist
 		_ = level.Info(logger).Log("msg", "ICMP Execution duration", "duration", totalDuration.Seconds())
 	}
 
-	err = pinger.Run()
+	err = pinger.RunWithContext(ctx)
 	if err != nil {
 		_ = level.Error(logger).Log("msg", "Pinger failed to run", "err", err)
 		return false
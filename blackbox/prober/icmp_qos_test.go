@@ -0,0 +1,167 @@
+// Copyright 2024 Arieditya Pr.dH [for netmonk.id]
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/icmp"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+func TestQoSStatsJitter(t *testing.T) {
+	stats := newQoSStats()
+	for seq, rtt := range []time.Duration{10 * time.Millisecond, 12 * time.Millisecond, 11 * time.Millisecond, 15 * time.Millisecond} {
+		stats.observe(seq, rtt)
+	}
+
+	// J(i) = J(i-1) + (|D(i-1,i)| - J(i-1))/16 for the differences 2ms, 1ms and 4ms.
+	want := 0.0
+	for _, d := range []float64{2e6, 1e6, 4e6} {
+		want += (d - want) / 16
+	}
+	if math.Abs(stats.jitter-want) > 1e-6 {
+		t.Errorf("Expected jitter %v ns, got %v ns", want, stats.jitter)
+	}
+	if stats.diffMin != time.Millisecond || stats.diffMax != 4*time.Millisecond || stats.diffSum != 7*time.Millisecond {
+		t.Errorf("Unexpected differences min=%v max=%v total=%v", stats.diffMin, stats.diffMax, stats.diffSum)
+	}
+}
+
+func TestQoSStatsConstantRTT(t *testing.T) {
+	stats := newQoSStats()
+	for seq := 0; seq < 50; seq++ {
+		stats.observe(seq, 20*time.Millisecond)
+	}
+	if stats.jitter != 0 || stats.diffMax != 0 {
+		t.Errorf("Expected no jitter for constant RTT, got %v and max difference %v", stats.jitter, stats.diffMax)
+	}
+}
+
+func TestQoSStatsOrdering(t *testing.T) {
+	stats := newQoSStats()
+	stream := []struct {
+		seq int
+		rtt time.Duration
+	}{
+		{0, 10 * time.Millisecond},
+		{2, 10 * time.Millisecond},
+		{1, 30 * time.Millisecond}, // overtaken by seq 2
+		{2, 11 * time.Millisecond}, // duplicate
+		{3, 10 * time.Millisecond},
+		{5, 10 * time.Millisecond},
+		{4, 25 * time.Millisecond}, // overtaken by seq 5
+	}
+	for _, pkt := range stream {
+		stats.observe(pkt.seq, pkt.rtt)
+	}
+	if stats.outOfOrder != 2 {
+		t.Errorf("Expected 2 out of order packets, got %d", stats.outOfOrder)
+	}
+	if stats.duplicates != 1 {
+		t.Errorf("Expected 1 duplicate packet, got %d", stats.duplicates)
+	}
+	if len(stats.rtts) != 6 {
+		t.Errorf("Expected duplicates to be left out of the RTTs, got %d RTTs", len(stats.rtts))
+	}
+}
+
+func TestQoSStatsPercentile(t *testing.T) {
+	stats := newQoSStats()
+	if p := stats.percentile(0.5); p != 0 {
+		t.Errorf("Expected 0 without replies, got %v", p)
+	}
+	// Replies arrive in reverse RTT order to check the percentiles are sorted.
+	for i := 100; i >= 1; i-- {
+		stats.observe(100-i, time.Duration(i)*time.Millisecond)
+	}
+	for p, want := range map[float64]time.Duration{
+		0.5:  50 * time.Millisecond,
+		0.9:  90 * time.Millisecond,
+		0.99: 99 * time.Millisecond,
+		1:    100 * time.Millisecond,
+	} {
+		if got := stats.percentile(p); got != want {
+			t.Errorf("Expected p%v %v, got %v", p*100, want, got)
+		}
+	}
+}
+
+// canPingLocalhost reports whether an unprivileged or a raw ICMP socket can
+// be opened.
+func canPingLocalhost() bool {
+	for _, network := range []string{"udp4", "ip4:icmp"} {
+		if conn, err := icmp.ListenPacket(network, "127.0.0.1"); err == nil {
+			conn.Close()
+			return true
+		}
+	}
+	return false
+}
+
+func TestICMPQoSLocalhost(t *testing.T) {
+	if !canPingLocalhost() {
+		t.Skip("Neither unprivileged nor raw ICMP sockets are available")
+	}
+
+	module := config.Module{
+		Timeout: 5 * time.Second,
+		ICMPQOS: config.ICMPQOSProbe{
+			IPProtocol:         "ip4",
+			IPProtocolFallback: true,
+			SourceIPAddress:    "127.0.0.1",
+			DSCP:               46,
+			Count:              5,
+			Interval:           10,
+			Timeout:            2000,
+			PacketSize:         64,
+			TTL:                64,
+			Codec:              "g711",
+		},
+	}
+	registry := prometheus.NewRegistry()
+	testCTX, cancel := context.WithTimeout(context.Background(), module.Timeout)
+	defer cancel()
+	if !ProbeICMPQoS(testCTX, "localhost", module, registry, log.NewNopLogger()) {
+		t.Fatalf("ICMP QoS probe to localhost failed")
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRegistryResults(map[string]float64{
+		"probe_ip_protocol":      4,
+		"probe_qos_packet_count": 5,
+	}, mfs, t)
+	for _, mf := range mfs {
+		switch mf.GetName() {
+		case "probe_qos_packet_loss_gauge":
+			for _, m := range mf.Metric {
+				if m.GetLabel()[0].GetValue() == "received" && m.GetGauge().GetValue() != 5 {
+					t.Errorf("Expected 5 replies, got %v", m.GetGauge().GetValue())
+				}
+			}
+		case "probe_qos_mos":
+			if mos := mf.Metric[0].GetGauge().GetValue(); mos < 4 {
+				t.Errorf("Expected a high MOS on localhost, got %v", mos)
+			}
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+type pmtuStatus int
+
+const (
+	// pmtuFits means the target replied to a packet of the size.
+	pmtuFits pmtuStatus = iota
+	// pmtuTooBig means a router answered with fragmentation needed (IPv4) or
+	// packet too big (IPv6).
+	pmtuTooBig
+	// pmtuLocalTooBig means the packet exceeds the MTU of the outgoing interface.
+	pmtuLocalTooBig
+	// pmtuLost means no reply was received.
+	pmtuLost
+)
+
+// pmtuResult is the outcome of sending packets of one size.
+type pmtuResult struct {
+	status pmtuStatus
+	// mtu is the next-hop MTU reported with pmtuTooBig, 0 if unknown.
+	mtu int
+}
+
+// pmtuSearch binary-searches the largest size between minMTU and maxMTU for
+// which try returns pmtuFits. It returns 0 when not even minMTU fits. MTUs
+// reported by routers are tried next, so that an ICMP-compliant path is found
+// in a few steps.
+func pmtuSearch(minMTU, maxMTU int, try func(size int) pmtuResult) (mtu, tooBig int, blackHole bool) {
+	lo, hi := minMTU-1, maxMTU
+	largestLost := 0
+	size := maxMTU
+	for {
+		r := try(size)
+		next := 0
+		switch r.status {
+		case pmtuFits:
+			lo = size
+		case pmtuTooBig:
+			tooBig++
+			hi = size - 1
+			if r.mtu > lo && r.mtu < size {
+				hi, next = r.mtu, r.mtu
+			}
+		case pmtuLocalTooBig:
+			hi = size - 1
+		case pmtuLost:
+			hi = size - 1
+			if size > largestLost {
+				largestLost = size
+			}
+		}
+		if lo >= hi {
+			break
+		}
+		if next == 0 {
+			next = (lo + hi + 1) / 2
+		}
+		size = next
+	}
+	if lo < minMTU {
+		return 0, tooBig, false
+	}
+	// Larger packets vanished without an ICMP error while smaller ones got
+	// through.
+	return lo, tooBig, largestLost > lo
+}
+
+// pmtuProber sends echo requests of a given size with the don't fragment flag
+// set.
+type pmtuProber struct {
+	probe  config.PMTUProbe
+	dst    net.IP
+	isIPv6 bool
+	conn   *net.IPConn
+	logger log.Logger
+}
+
+// try sends a packet of the given total size and classifies the reply.
+func (p *pmtuProber) try(ctx context.Context, size int) (pmtuResult, error) {
+	requestType, replyType, proto, hdrLen := icmp.Type(ipv4.ICMPTypeEcho), icmp.Type(ipv4.ICMPTypeEchoReply), protocolICMP, ipv4.HeaderLen
+	if p.isIPv6 {
+		requestType, replyType, proto, hdrLen = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, protocolICMPv6, ipv6.HeaderLen
+	}
+	payloadSize := size - hdrLen - 8
+	if payloadSize < 0 {
+		payloadSize = 0
+	}
+	seq := int(getICMPSequence())
+	wm := icmp.Message{
+		Type: requestType,
+		Body: &icmp.Echo{
+			ID:   icmpID,
+			Seq:  seq,
+			Data: make([]byte, payloadSize),
+		},
+	}
+	wb, err := wm.Marshal(nil)
+	if err != nil {
+		return pmtuResult{}, err
+	}
+
+	deadline := time.Now().Add(p.probe.QueryTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if _, err := p.conn.WriteTo(wb, &net.IPAddr{IP: p.dst}); err != nil {
+		if errors.Is(err, syscall.EMSGSIZE) {
+			return pmtuResult{status: pmtuLocalTooBig}, nil
+		}
+		return pmtuResult{}, err
+	}
+	if err := p.conn.SetReadDeadline(deadline); err != nil {
+		return pmtuResult{}, err
+	}
+
+	rb := make([]byte, 65536)
+	for {
+		n, peer, err := p.conn.ReadFrom(rb)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return pmtuResult{status: pmtuLost}, nil
+			}
+			return pmtuResult{}, err
+		}
+		msg, err := icmp.ParseMessage(proto, rb[:n])
+		if err != nil {
+			continue
+		}
+		switch body := msg.Body.(type) {
+		case *icmp.Echo:
+			if msg.Type == replyType && body.ID == icmpID && body.Seq == seq && peer.(*net.IPAddr).IP.Equal(p.dst) {
+				return pmtuResult{status: pmtuFits}, nil
+			}
+		case *icmp.DstUnreach:
+			// Code 4 is fragmentation needed, the next-hop MTU is in the
+			// second half of the otherwise unused header field.
+			if p.isIPv6 || msg.Code != 4 || !p.quotes(body.Data, seq) {
+				continue
+			}
+			level.Debug(p.logger).Log("msg", "Received fragmentation needed", "size", size, "router", peer)
+			return pmtuResult{status: pmtuTooBig, mtu: int(binary.BigEndian.Uint16(rb[6:8]))}, nil
+		case *icmp.PacketTooBig:
+			if !p.quotes(body.Data, seq) {
+				continue
+			}
+			level.Debug(p.logger).Log("msg", "Received packet too big", "size", size, "router", peer)
+			return pmtuResult{status: pmtuTooBig, mtu: body.MTU}, nil
+		}
+	}
+}
+
+// quotes reports whether an ICMP error quotes our echo request.
+func (p *pmtuProber) quotes(data []byte, seq int) bool {
+	q, err := parseQuotedPacket(data, p.isIPv6)
+	return err == nil && q.dst.Equal(p.dst) && q.icmpID == icmpID && q.icmpSeq == seq
+}
+
+func ProbePMTU(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger log.Logger) (success bool) {
+	var (
+		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_pmtu_duration_seconds",
+			Help: "Duration of path MTU discovery by phase",
+		}, []string{"phase"})
+
+		pathMTUGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_path_mtu_bytes",
+			Help: "Returns the largest packet size that reached the target without fragmentation",
+		})
+
+		tooBigGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_pmtu_too_big_messages",
+			Help: "Returns the number of fragmentation needed or packet too big messages received",
+		})
+
+		blackHoleGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_pmtu_black_hole_detected",
+			Help: "Displays whether or not packets larger than the path MTU were dropped without an ICMP error",
+		})
+	)
+
+	for _, lv := range []string{"resolve", "search"} {
+		durationGaugeVec.WithLabelValues(lv)
+	}
+
+	registry.MustRegister(durationGaugeVec)
+	registry.MustRegister(pathMTUGauge)
+	registry.MustRegister(tooBigGauge)
+	registry.MustRegister(blackHoleGauge)
+
+	probe := module.PMTU
+	if probe.QueryTimeout <= 0 {
+		probe.QueryTimeout = config.DefaultPMTUProbe.QueryTimeout
+	}
+	if probe.Queries < 1 {
+		probe.Queries = 1
+	}
+	if probe.MaxMTU == 0 {
+		probe.MaxMTU = config.DefaultPMTUProbe.MaxMTU
+	}
+
+	dstIPAddr, lookupTime, err := chooseProtocol(ctx, probe.IPProtocol, probe.IPProtocolFallback, target, registry, logger)
+	if err != nil {
+		level.Warn(logger).Log("msg", "Error resolving address", "err", err)
+		return false
+	}
+	durationGaugeVec.WithLabelValues("resolve").Add(lookupTime)
+
+	p := &pmtuProber{
+		probe:  probe,
+		dst:    dstIPAddr.IP,
+		isIPv6: dstIPAddr.IP.To4() == nil,
+		logger: logger,
+	}
+
+	// The smallest MTU every link has to support.
+	minMTU := 576
+	network, listenAddr := "ip4:icmp", "0.0.0.0"
+	if p.isIPv6 {
+		minMTU = 1280
+		network, listenAddr = "ip6:ipv6-icmp", "::"
+	}
+	if probe.MinMTU > 0 {
+		minMTU = probe.MinMTU
+	}
+	if minMTU > probe.MaxMTU {
+		minMTU = probe.MaxMTU
+	}
+	if len(probe.SourceIPAddress) > 0 {
+		srcIP := net.ParseIP(probe.SourceIPAddress)
+		if srcIP == nil {
+			level.Error(logger).Log("msg", "Error parsing source ip address", "srcIP", probe.SourceIPAddress)
+			return false
+		}
+		level.Info(logger).Log("msg", "Using source address", "srcIP", srcIP)
+		listenAddr = srcIP.String()
+	}
+
+	lc := net.ListenConfig{Control: dontFragmentControl(p.isIPv6)}
+	conn, err := lc.ListenPacket(ctx, network, listenAddr)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error listening to socket", "err", err)
+		return false
+	}
+	defer conn.Close()
+	p.conn = conn.(*net.IPConn)
+
+	level.Info(logger).Log("msg", "Starting path MTU discovery", "dst", p.dst, "min_mtu", minMTU, "max_mtu", probe.MaxMTU)
+	searchStart := time.Now()
+	var tryErr error
+	mtu, tooBig, blackHole := pmtuSearch(minMTU, probe.MaxMTU, func(size int) pmtuResult {
+		for q := 0; q < probe.Queries && tryErr == nil && ctx.Err() == nil; q++ {
+			r, err := p.try(ctx, size)
+			if err != nil {
+				tryErr = err
+				break
+			}
+			if r.status != pmtuLost {
+				level.Debug(logger).Log("msg", "Probed size", "size", size, "fits", r.status == pmtuFits)
+				return r
+			}
+		}
+		level.Debug(logger).Log("msg", "No reply for size", "size", size)
+		return pmtuResult{status: pmtuLost}
+	})
+	durationGaugeVec.WithLabelValues("search").Add(time.Since(searchStart).Seconds())
+
+	if tryErr != nil {
+		level.Error(logger).Log("msg", "Error sending packet", "err", tryErr)
+		return false
+	}
+	if ctx.Err() != nil {
+		level.Error(logger).Log("msg", "Timeout during path MTU discovery", "err", ctx.Err())
+		return false
+	}
+
+	tooBigGauge.Set(float64(tooBig))
+	if blackHole {
+		level.Warn(logger).Log("msg", "Larger packets were dropped without an ICMP error", "path_mtu", mtu)
+		blackHoleGauge.Set(1)
+	}
+	if mtu == 0 {
+		level.Error(logger).Log("msg", "No reply for the smallest size", "min_mtu", minMTU)
+		return false
+	}
+	level.Info(logger).Log("msg", "Found path MTU", "path_mtu", mtu)
+	pathMTUGauge.Set(float64(mtu))
+	return true
+}
@@ -0,0 +1,156 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/icmp"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+func TestPMTUSearch(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       func(size int) pmtuResult
+		wantMTU    int
+		wantTooBig int
+		blackHole  bool
+		maxTries   int
+	}{
+		{
+			name:     "path fits the maximum",
+			path:     func(size int) pmtuResult { return pmtuResult{status: pmtuFits} },
+			wantMTU:  1500,
+			maxTries: 1,
+		},
+		{
+			name: "router reports the next-hop MTU",
+			path: func(size int) pmtuResult {
+				if size > 1400 {
+					return pmtuResult{status: pmtuTooBig, mtu: 1400}
+				}
+				return pmtuResult{status: pmtuFits}
+			},
+			wantMTU:    1400,
+			wantTooBig: 1,
+			maxTries:   2,
+		},
+		{
+			name: "router reports no MTU",
+			path: func(size int) pmtuResult {
+				if size > 1420 {
+					return pmtuResult{status: pmtuTooBig}
+				}
+				return pmtuResult{status: pmtuFits}
+			},
+			wantMTU:    1420,
+			wantTooBig: -1,
+			maxTries:   12,
+		},
+		{
+			name: "black hole",
+			path: func(size int) pmtuResult {
+				if size > 1280 {
+					return pmtuResult{status: pmtuLost}
+				}
+				return pmtuResult{status: pmtuFits}
+			},
+			wantMTU:   1280,
+			blackHole: true,
+			maxTries:  12,
+		},
+		{
+			name: "local interface MTU",
+			path: func(size int) pmtuResult {
+				if size > 1492 {
+					return pmtuResult{status: pmtuLocalTooBig}
+				}
+				return pmtuResult{status: pmtuFits}
+			},
+			wantMTU:  1492,
+			maxTries: 12,
+		},
+		{
+			name:     "target unreachable",
+			path:     func(size int) pmtuResult { return pmtuResult{status: pmtuLost} },
+			wantMTU:  0,
+			maxTries: 12,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tries := 0
+			mtu, tooBig, blackHole := pmtuSearch(576, 1500, func(size int) pmtuResult {
+				tries++
+				if size < 576 || size > 1500 {
+					t.Fatalf("Size %d outside of the search range", size)
+				}
+				return test.path(size)
+			})
+			if mtu != test.wantMTU {
+				t.Errorf("Expected path MTU %d, got %d", test.wantMTU, mtu)
+			}
+			if test.wantTooBig >= 0 && tooBig != test.wantTooBig {
+				t.Errorf("Expected %d too big messages, got %d", test.wantTooBig, tooBig)
+			}
+			if blackHole != test.blackHole {
+				t.Errorf("Expected black hole %v, got %v", test.blackHole, blackHole)
+			}
+			if tries > test.maxTries {
+				t.Errorf("Expected at most %d sizes to be tried, got %d", test.maxTries, tries)
+			}
+		})
+	}
+}
+
+func TestPMTULocalhost(t *testing.T) {
+	conn, err := icmp.ListenPacket("ip4:icmp", "127.0.0.1")
+	if err != nil {
+		t.Skipf("Raw ICMP sockets are not available: %s", err)
+	}
+	conn.Close()
+
+	module := config.Module{
+		Timeout: time.Second,
+		PMTU: config.PMTUProbe{
+			IPProtocol:         "ip4",
+			IPProtocolFallback: true,
+			MaxMTU:             9000,
+			Queries:            1,
+			QueryTimeout:       time.Second,
+		},
+	}
+	registry := prometheus.NewRegistry()
+	testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if !ProbePMTU(testCTX, "127.0.0.1", module, registry, log.NewNopLogger()) {
+		t.Fatalf("Path MTU discovery to localhost failed")
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRegistryResults(map[string]float64{
+		"probe_path_mtu_bytes":           9000,
+		"probe_pmtu_too_big_messages":    0,
+		"probe_pmtu_black_hole_detected": 0,
+	}, mfs, t)
+}
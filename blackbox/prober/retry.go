@@ -0,0 +1,163 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+// Reasons of failed probe attempts, matching the retry_on conditions of a
+// retry policy.
+const (
+	failureConnectError     = "connect_error"
+	failureAssertionFailure = "assertion_failure"
+	failureTimeout          = "timeout"
+)
+
+var failureReasons = []string{failureConnectError, failureAssertionFailure, failureTimeout}
+
+// responseGauges mark that a probe got an answer from the target. A probe
+// that failed after one of them was set failed on the content of the
+// answer rather than on reaching the target.
+var responseGauges = map[string]bool{
+	"probe_http_status_code":    true,
+	"probe_dns_query_succeeded": true,
+}
+
+// attemptFailureReason classifies a failed attempt from its context and
+// the metrics it left in its registry.
+func attemptFailureReason(ctx context.Context, registry *prometheus.Registry) string {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return failureTimeout
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		return failureConnectError
+	}
+	for _, mf := range mfs {
+		if !strings.Contains(mf.GetName(), "failed_due_to") && !responseGauges[mf.GetName()] {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if m.GetGauge().GetValue() > 0 {
+				return failureAssertionFailure
+			}
+		}
+	}
+	return failureConnectError
+}
+
+// probeWithRetry runs a prober until it succeeds, the attempts of the retry
+// policy of the module are used up, it fails for a reason that is not
+// retried or no time is left for another attempt. It returns the registry
+// of the last attempt, which exposes the number of attempts and the reason
+// the last attempt failed if the module retries, and the failure reason of
+// the last attempt.
+func probeWithRetry(ctx context.Context, prober ProbeFn, target string, module config.Module, sl log.Logger) (*prometheus.Registry, bool, string) {
+	policy := module.Retry
+	if policy.Attempts <= 1 {
+		registry := prometheus.NewRegistry()
+		if !prober(ctx, target, module, registry, sl) {
+			return registry, false, attemptFailureReason(ctx, registry)
+		}
+		return registry, true, ""
+	}
+
+	var (
+		registry *prometheus.Registry
+		success  bool
+		reason   string
+		attempt  int
+		backoff  = policy.Backoff
+	)
+	for attempt = 1; ; attempt++ {
+		level.Info(sl).Log("msg", "Beginning attempt", "attempt", attempt, "max_attempts", policy.Attempts)
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if policy.AttemptTimeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, policy.AttemptTimeout)
+		}
+		attemptCtx, span := probeTracer().Start(attemptCtx, "attempt", trace.WithAttributes(attribute.Int("probe.attempt", attempt)))
+		registry = prometheus.NewRegistry()
+		success = prober(attemptCtx, target, module, registry, sl)
+		reason = ""
+		if !success {
+			reason = attemptFailureReason(attemptCtx, registry)
+		}
+		endProbeSpan(span, success, reason)
+		cancel()
+
+		if success || attempt >= policy.Attempts {
+			break
+		}
+		if !retries(policy, reason) {
+			level.Info(sl).Log("msg", "Attempt failed, not retrying", "attempt", attempt, "reason", reason)
+			break
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+			level.Info(sl).Log("msg", "Attempt failed, no time left for another attempt", "attempt", attempt, "reason", reason)
+			break
+		}
+		level.Warn(sl).Log("msg", "Attempt failed, retrying", "attempt", attempt, "reason", reason, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+		if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+
+	attemptsGauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "probe_attempts",
+		Help: "Number of attempts made by the probe",
+	})
+	failureReasonGauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "probe_last_attempt_failure_reason",
+		Help: "Indicates the reason the last attempt of the probe failed",
+	}, []string{"reason"})
+	registry.MustRegister(attemptsGauge, failureReasonGauge)
+	attemptsGauge.Set(float64(attempt))
+	for _, r := range failureReasons {
+		if r == reason {
+			failureReasonGauge.WithLabelValues(r).Set(1)
+		} else {
+			failureReasonGauge.WithLabelValues(r).Set(0)
+		}
+	}
+	return registry, success, reason
+}
+
+func retries(policy config.RetryPolicy, reason string) bool {
+	for _, condition := range policy.RetryOn {
+		if condition == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !unix
+
+package prober
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+)
+
+// ttlControl returns a net.Dialer control function setting the TTL (hop
+// limit for IPv6) of outgoing packets before the socket connects.
+func ttlControl(ttl int, ipv6 bool) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		return fmt.Errorf("setting the TTL of TCP sockets is not supported on %s", runtime.GOOS)
+	}
+}
@@ -0,0 +1,544 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+const (
+	protocolICMP   = 1
+	protocolTCP    = 6
+	protocolUDP    = 17
+	protocolICMPv6 = 58
+)
+
+// traceHop collects the replies received for one TTL.
+type traceHop struct {
+	ttl       int
+	sent      int
+	rtts      []time.Duration
+	addresses []string
+	reached   bool
+}
+
+func (h *traceHop) record(addr string, rtt time.Duration) {
+	h.rtts = append(h.rtts, rtt)
+	for _, a := range h.addresses {
+		if a == addr {
+			return
+		}
+	}
+	h.addresses = append(h.addresses, addr)
+}
+
+// lossRatio returns the fraction of probes for the hop that got no reply.
+func (h *traceHop) lossRatio() float64 {
+	if h.sent == 0 {
+		return 0
+	}
+	return float64(h.sent-len(h.rtts)) / float64(h.sent)
+}
+
+// rttStats returns the minimum, average and maximum RTT of the hop.
+func (h *traceHop) rttStats() (min, avg, max time.Duration) {
+	if len(h.rtts) == 0 {
+		return 0, 0, 0
+	}
+	var sum time.Duration
+	min = h.rtts[0]
+	for _, rtt := range h.rtts {
+		sum += rtt
+		if rtt < min {
+			min = rtt
+		}
+		if rtt > max {
+			max = rtt
+		}
+	}
+	return min, sum / time.Duration(len(h.rtts)), max
+}
+
+// tracePathHash hashes the addresses of all hops, so that a route change shows
+// up as a change of a single value.
+func tracePathHash(hops []*traceHop) float64 {
+	h := fnv.New32a()
+	for _, hop := range hops {
+		if len(hop.addresses) == 0 {
+			h.Write([]byte("*"))
+		} else {
+			// Replies can arrive in any order, sort them so that only a
+			// change of addresses changes the hash.
+			addresses := slices.Clone(hop.addresses)
+			slices.Sort(addresses)
+			h.Write([]byte(strings.Join(addresses, ",")))
+		}
+		h.Write([]byte{0})
+	}
+	return float64(h.Sum32())
+}
+
+// quotedPacket is the start of the original packet quoted in ICMP time
+// exceeded and destination unreachable messages.
+type quotedPacket struct {
+	protocol int
+	dst      net.IP
+	srcPort  int
+	dstPort  int
+	icmpID   int
+	icmpSeq  int
+}
+
+// parseQuotedPacket parses the IP header and the first 8 bytes of the
+// transport header quoted in an ICMP error.
+func parseQuotedPacket(data []byte, isIPv6 bool) (*quotedPacket, error) {
+	var q quotedPacket
+	var l4 []byte
+	if isIPv6 {
+		if len(data) < ipv6.HeaderLen+8 {
+			return nil, errors.New("quoted IPv6 packet too short")
+		}
+		q.protocol = int(data[6])
+		q.dst = net.IP(data[24:40])
+		l4 = data[ipv6.HeaderLen:]
+	} else {
+		if len(data) < ipv4.HeaderLen {
+			return nil, errors.New("quoted IPv4 packet too short")
+		}
+		hdrLen := int(data[0]&0x0f) * 4
+		if hdrLen < ipv4.HeaderLen || len(data) < hdrLen+8 {
+			return nil, errors.New("quoted IPv4 packet too short")
+		}
+		q.protocol = int(data[9])
+		q.dst = net.IP(data[16:20])
+		l4 = data[hdrLen:]
+	}
+	switch q.protocol {
+	case protocolICMP, protocolICMPv6:
+		q.icmpID = int(binary.BigEndian.Uint16(l4[4:6]))
+		q.icmpSeq = int(binary.BigEndian.Uint16(l4[6:8]))
+	case protocolTCP, protocolUDP:
+		q.srcPort = int(binary.BigEndian.Uint16(l4[0:2]))
+		q.dstPort = int(binary.BigEndian.Uint16(l4[2:4]))
+	}
+	return &q, nil
+}
+
+// tracer sends the probes of a traceroute and reads the ICMP replies.
+type tracer struct {
+	probe  config.TracerouteProbe
+	dst    net.IP
+	src    net.IP
+	isIPv6 bool
+	conn   *icmp.PacketConn
+	logger log.Logger
+}
+
+// quotedError returns the packet quoted by an ICMP error message, if any.
+func (t *tracer) quotedError(msg *icmp.Message) (*quotedPacket, bool) {
+	var data []byte
+	switch body := msg.Body.(type) {
+	case *icmp.TimeExceeded:
+		data = body.Data
+	case *icmp.DstUnreach:
+		data = body.Data
+	default:
+		return nil, false
+	}
+	q, err := parseQuotedPacket(data, t.isIPv6)
+	if err != nil {
+		level.Debug(t.logger).Log("msg", "Ignoring ICMP error", "err", err)
+		return nil, false
+	}
+	return q, q.dst.Equal(t.dst)
+}
+
+// awaitReply reads ICMP messages until match accepts one or the deadline
+// passes. It returns the address of the sender and when the reply arrived.
+func (t *tracer) awaitReply(deadline time.Time, match func(peer net.IP, msg *icmp.Message) bool) (net.IP, time.Time, error) {
+	if err := t.conn.SetReadDeadline(deadline); err != nil {
+		return nil, time.Time{}, err
+	}
+	return t.readReply(match)
+}
+
+// readReply reads ICMP messages until match accepts one or the read deadline
+// of the connection passes.
+func (t *tracer) readReply(match func(peer net.IP, msg *icmp.Message) bool) (net.IP, time.Time, error) {
+	proto := protocolICMP
+	if t.isIPv6 {
+		proto = protocolICMPv6
+	}
+	rb := make([]byte, 1500)
+	for {
+		n, peer, err := t.conn.ReadFrom(rb)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		received := time.Now()
+		msg, err := icmp.ParseMessage(proto, rb[:n])
+		if err != nil {
+			continue
+		}
+		var peerIP net.IP
+		switch p := peer.(type) {
+		case *net.IPAddr:
+			peerIP = p.IP
+		case *net.UDPAddr:
+			peerIP = p.IP
+		}
+		if match(peerIP, msg) {
+			return peerIP, received, nil
+		}
+	}
+}
+
+// sendICMP sends an echo request with the given TTL.
+func (t *tracer) sendICMP(ttl int, deadline time.Time) (net.IP, time.Duration, bool, error) {
+	requestType, replyType := icmp.Type(ipv4.ICMPTypeEcho), icmp.Type(ipv4.ICMPTypeEchoReply)
+	if t.isIPv6 {
+		requestType, replyType = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply
+	}
+	seq := int(getICMPSequence())
+	wm := icmp.Message{
+		Type: requestType,
+		Body: &icmp.Echo{
+			ID:   icmpID,
+			Seq:  seq,
+			Data: make([]byte, t.probe.PayloadSize),
+		},
+	}
+	wb, err := wm.Marshal(nil)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if err := t.setTTL(ttl); err != nil {
+		return nil, 0, false, err
+	}
+
+	start := time.Now()
+	if _, err := t.conn.WriteTo(wb, &net.IPAddr{IP: t.dst}); err != nil {
+		return nil, 0, false, err
+	}
+	var reached bool
+	peer, received, err := t.awaitReply(deadline, func(peer net.IP, msg *icmp.Message) bool {
+		if msg.Type == replyType {
+			echo, ok := msg.Body.(*icmp.Echo)
+			reached = ok && echo.ID == icmpID && echo.Seq == seq && peer.Equal(t.dst)
+			return reached
+		}
+		q, ok := t.quotedError(msg)
+		return ok && q.icmpID == icmpID && q.icmpSeq == seq
+	})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return peer, received.Sub(start), reached, nil
+}
+
+// sendUDP sends a datagram with the given TTL. The destination is reached when
+// it answers with port unreachable.
+func (t *tracer) sendUDP(ttl int, deadline time.Time) (net.IP, time.Duration, bool, error) {
+	network := "udp4"
+	if t.isIPv6 {
+		network = "udp6"
+	}
+	conn, err := net.ListenUDP(network, &net.UDPAddr{IP: t.src})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer conn.Close()
+	if t.isIPv6 {
+		err = ipv6.NewPacketConn(conn).SetHopLimit(ttl)
+	} else {
+		err = ipv4.NewPacketConn(conn).SetTTL(ttl)
+	}
+	if err != nil {
+		return nil, 0, false, err
+	}
+	srcPort := conn.LocalAddr().(*net.UDPAddr).Port
+
+	start := time.Now()
+	if _, err := conn.WriteTo(make([]byte, t.probe.PayloadSize), &net.UDPAddr{IP: t.dst, Port: t.probe.Port}); err != nil {
+		return nil, 0, false, err
+	}
+	var reached bool
+	peer, received, err := t.awaitReply(deadline, func(peer net.IP, msg *icmp.Message) bool {
+		var ok bool
+		ok, reached = t.udpReply(peer, msg, srcPort)
+		return ok
+	})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return peer, received.Sub(start), reached, nil
+}
+
+// udpReply returns whether msg answers the datagram sent from srcPort and
+// whether it comes from the destination. Other unreachable errors, such as
+// host unreachable or administratively prohibited from a router, answer
+// for a hop without reaching the destination.
+func (t *tracer) udpReply(peer net.IP, msg *icmp.Message, srcPort int) (ok, reached bool) {
+	q, ok := t.quotedError(msg)
+	if !ok || q.protocol != protocolUDP || q.srcPort != srcPort || q.dstPort != t.probe.Port {
+		return false, false
+	}
+	portUnreachable := msg.Type == ipv4.ICMPTypeDestinationUnreachable && msg.Code == 3
+	if t.isIPv6 {
+		portUnreachable = msg.Type == ipv6.ICMPTypeDestinationUnreachable && msg.Code == 4
+	}
+	return true, portUnreachable && peer.Equal(t.dst)
+}
+
+// sendTCP opens a connection whose SYN has the given TTL. The destination is
+// reached when the connection is established or refused.
+func (t *tracer) sendTCP(ctx context.Context, ttl int, deadline time.Time) (net.IP, time.Duration, bool, error) {
+	dialer := &net.Dialer{
+		LocalAddr: &net.TCPAddr{IP: t.src},
+		Control:   ttlControl(ttl, t.isIPv6),
+	}
+	dialCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	type dialResult struct {
+		err      error
+		finished time.Time
+	}
+	dialDone := make(chan dialResult, 1)
+	// The deadline is set before dialing, so that the wake-up below cannot be
+	// overwritten by it.
+	if err := t.conn.SetReadDeadline(deadline); err != nil {
+		return nil, 0, false, err
+	}
+	start := time.Now()
+	go func() {
+		conn, err := dialer.DialContext(dialCtx, "tcp", net.JoinHostPort(t.dst.String(), strconv.Itoa(t.probe.Port)))
+		finished := time.Now()
+		if err == nil {
+			conn.Close()
+		}
+		// Wake up readReply, the answer did not come over ICMP.
+		t.conn.SetReadDeadline(time.Now())
+		dialDone <- dialResult{err: err, finished: finished}
+	}()
+
+	peer, received, err := t.readReply(func(peer net.IP, msg *icmp.Message) bool {
+		// The source port is only known once connected, probes are sent one
+		// at a time so the destination port identifies them.
+		q, ok := t.quotedError(msg)
+		return ok && q.protocol == protocolTCP && q.dstPort == t.probe.Port
+	})
+	if err == nil {
+		cancel()
+		<-dialDone
+		return peer, received.Sub(start), false, nil
+	}
+
+	result := <-dialDone
+	if result.err == nil || errors.Is(result.err, syscall.ECONNREFUSED) {
+		return t.dst, result.finished.Sub(start), true, nil
+	}
+	return nil, 0, false, result.err
+}
+
+func (t *tracer) setTTL(ttl int) error {
+	if t.isIPv6 {
+		return t.conn.IPv6PacketConn().SetHopLimit(ttl)
+	}
+	return t.conn.IPv4PacketConn().SetTTL(ttl)
+}
+
+// send sends one probe with the given TTL and waits for its reply.
+func (t *tracer) send(ctx context.Context, ttl int) (peer net.IP, rtt time.Duration, reached bool, err error) {
+	deadline := time.Now().Add(t.probe.QueryTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	switch t.probe.Protocol {
+	case "udp":
+		return t.sendUDP(ttl, deadline)
+	case "tcp":
+		return t.sendTCP(ctx, ttl, deadline)
+	default:
+		return t.sendICMP(ttl, deadline)
+	}
+}
+
+func ProbeTraceroute(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger log.Logger) (success bool) {
+	var (
+		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_traceroute_duration_seconds",
+			Help: "Duration of traceroute by phase",
+		}, []string{"phase"})
+
+		hopsGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_traceroute_hops",
+			Help: "Returns the number of hops traced",
+		})
+
+		reachedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_traceroute_destination_reached",
+			Help: "Displays whether or not the target answered",
+		})
+
+		pathHashGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_traceroute_path_hash",
+			Help: "Specifies the hash of the addresses of all hops. It's useful to detect route changes.",
+		})
+
+		hopInfoGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_traceroute_hop_info",
+			Help: "Contains the addresses that answered for each hop",
+		}, []string{"hop", "address"})
+
+		hopRTTGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_traceroute_hop_rtt_seconds",
+			Help: "Returns the round trip time to each hop",
+		}, []string{"hop", "aggregate"})
+
+		hopLossGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_traceroute_hop_loss_ratio",
+			Help: "Returns the fraction of probes to each hop that got no reply",
+		}, []string{"hop"})
+	)
+
+	for _, lv := range []string{"resolve", "trace"} {
+		durationGaugeVec.WithLabelValues(lv)
+	}
+
+	registry.MustRegister(durationGaugeVec)
+	registry.MustRegister(hopsGauge)
+	registry.MustRegister(reachedGauge)
+	registry.MustRegister(pathHashGauge)
+	registry.MustRegister(hopInfoGaugeVec)
+	registry.MustRegister(hopRTTGaugeVec)
+	registry.MustRegister(hopLossGaugeVec)
+
+	probe := module.Traceroute
+	if probe.Port == 0 {
+		switch probe.Protocol {
+		case "udp":
+			probe.Port = 33434
+		case "tcp":
+			probe.Port = 80
+		}
+	}
+	if probe.QueryTimeout <= 0 {
+		probe.QueryTimeout = config.DefaultTracerouteProbe.QueryTimeout
+	}
+	if probe.Queries < 1 {
+		probe.Queries = 1
+	}
+	if probe.FirstHop < 1 {
+		probe.FirstHop = 1
+	}
+
+	dstIPAddr, lookupTime, err := chooseProtocol(ctx, probe.IPProtocol, probe.IPProtocolFallback, target, registry, logger)
+	if err != nil {
+		level.Warn(logger).Log("msg", "Error resolving address", "err", err)
+		return false
+	}
+	durationGaugeVec.WithLabelValues("resolve").Add(lookupTime)
+
+	t := &tracer{
+		probe:  probe,
+		dst:    dstIPAddr.IP,
+		isIPv6: dstIPAddr.IP.To4() == nil,
+		logger: logger,
+	}
+	if len(probe.SourceIPAddress) > 0 {
+		if t.src = net.ParseIP(probe.SourceIPAddress); t.src == nil {
+			level.Error(logger).Log("msg", "Error parsing source ip address", "srcIP", probe.SourceIPAddress)
+			return false
+		}
+		level.Info(logger).Log("msg", "Using source address", "srcIP", t.src)
+	}
+
+	network, listenAddr := "ip4:icmp", "0.0.0.0"
+	if t.isIPv6 {
+		network, listenAddr = "ip6:ipv6-icmp", "::"
+	}
+	if t.src != nil {
+		listenAddr = t.src.String()
+	}
+	t.conn, err = icmp.ListenPacket(network, listenAddr)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error listening to socket", "err", err)
+		return false
+	}
+	defer t.conn.Close()
+
+	level.Info(logger).Log("msg", "Starting traceroute", "protocol", probe.Protocol, "dst", t.dst, "first_hop", probe.FirstHop, "max_hops", probe.MaxHops)
+	traceStart := time.Now()
+	var hops []*traceHop
+	reached := false
+	for ttl := probe.FirstHop; ttl <= probe.MaxHops && !reached && ctx.Err() == nil; ttl++ {
+		hop := &traceHop{ttl: ttl}
+		for q := 0; q < probe.Queries && ctx.Err() == nil; q++ {
+			hop.sent++
+			peer, rtt, hopReached, err := t.send(ctx, ttl)
+			if err != nil {
+				level.Debug(logger).Log("msg", "No reply for probe", "ttl", ttl, "err", err)
+				continue
+			}
+			hop.record(peer.String(), rtt)
+			hop.reached = hop.reached || hopReached
+		}
+		level.Info(logger).Log("msg", "Traced hop", "ttl", ttl, "addresses", strings.Join(hop.addresses, ","), "sent", hop.sent, "received", len(hop.rtts))
+		hops = append(hops, hop)
+		reached = hop.reached
+	}
+	durationGaugeVec.WithLabelValues("trace").Add(time.Since(traceStart).Seconds())
+
+	for _, hop := range hops {
+		ttl := strconv.Itoa(hop.ttl)
+		for _, addr := range hop.addresses {
+			hopInfoGaugeVec.WithLabelValues(ttl, addr).Set(1)
+		}
+		if len(hop.rtts) > 0 {
+			min, avg, max := hop.rttStats()
+			hopRTTGaugeVec.WithLabelValues(ttl, "min").Set(min.Seconds())
+			hopRTTGaugeVec.WithLabelValues(ttl, "avg").Set(avg.Seconds())
+			hopRTTGaugeVec.WithLabelValues(ttl, "max").Set(max.Seconds())
+		}
+		hopLossGaugeVec.WithLabelValues(ttl).Set(hop.lossRatio())
+	}
+	hopsGauge.Set(float64(len(hops)))
+	pathHashGauge.Set(tracePathHash(hops))
+
+	if !reached {
+		level.Error(logger).Log("msg", "Destination not reached", "max_hops", probe.MaxHops)
+		return false
+	}
+	reachedGauge.Set(1)
+	return true
+}
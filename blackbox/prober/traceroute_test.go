@@ -0,0 +1,331 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+// quotedIPv4 builds the IPv4 header and transport header start that routers
+// quote in ICMP errors.
+func quotedIPv4(protocol int, dst net.IP, l4 []byte) []byte {
+	b := make([]byte, 20, 20+len(l4))
+	b[0] = 0x45
+	b[9] = byte(protocol)
+	copy(b[16:20], dst.To4())
+	return append(b, l4...)
+}
+
+func TestParseQuotedPacket(t *testing.T) {
+	dst := net.ParseIP("192.0.2.1")
+
+	echo := make([]byte, 8)
+	echo[0] = 8
+	binary.BigEndian.PutUint16(echo[4:6], 4321)
+	binary.BigEndian.PutUint16(echo[6:8], 17)
+	q, err := parseQuotedPacket(quotedIPv4(protocolICMP, dst, echo), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.protocol != protocolICMP || !q.dst.Equal(dst) || q.icmpID != 4321 || q.icmpSeq != 17 {
+		t.Errorf("Unexpected quoted echo request: %+v", q)
+	}
+
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:2], 50000)
+	binary.BigEndian.PutUint16(udp[2:4], 33434)
+	q, err = parseQuotedPacket(quotedIPv4(protocolUDP, dst, udp), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.protocol != protocolUDP || q.srcPort != 50000 || q.dstPort != 33434 {
+		t.Errorf("Unexpected quoted datagram: %+v", q)
+	}
+
+	udp6 := make([]byte, 48)
+	udp6[6] = protocolUDP
+	copy(udp6[24:40], net.ParseIP("2001:db8::1"))
+	binary.BigEndian.PutUint16(udp6[40:42], 50001)
+	binary.BigEndian.PutUint16(udp6[42:44], 33435)
+	q, err = parseQuotedPacket(udp6, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.dst.Equal(net.ParseIP("2001:db8::1")) || q.srcPort != 50001 || q.dstPort != 33435 {
+		t.Errorf("Unexpected quoted IPv6 datagram: %+v", q)
+	}
+
+	if _, err := parseQuotedPacket(quotedIPv4(protocolUDP, dst, udp[:4]), false); err == nil {
+		t.Errorf("Expected error for truncated quoted packet")
+	}
+}
+
+func TestTracerQuotedError(t *testing.T) {
+	tr := &tracer{dst: net.ParseIP("192.0.2.1"), logger: log.NewNopLogger()}
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[2:4], 33434)
+
+	msg := &icmp.Message{Body: &icmp.TimeExceeded{Data: quotedIPv4(protocolUDP, net.ParseIP("192.0.2.1"), udp)}}
+	if _, ok := tr.quotedError(msg); !ok {
+		t.Errorf("Expected time exceeded for the target to match")
+	}
+	msg = &icmp.Message{Body: &icmp.TimeExceeded{Data: quotedIPv4(protocolUDP, net.ParseIP("192.0.2.2"), udp)}}
+	if _, ok := tr.quotedError(msg); ok {
+		t.Errorf("Expected time exceeded for another target not to match")
+	}
+	msg = &icmp.Message{Body: &icmp.Echo{}}
+	if _, ok := tr.quotedError(msg); ok {
+		t.Errorf("Expected echo reply not to be treated as an error")
+	}
+}
+
+func TestTracerUDPReply(t *testing.T) {
+	dst := net.ParseIP("192.0.2.1")
+	router := net.ParseIP("198.51.100.1")
+	tr := &tracer{dst: dst, probe: config.TracerouteProbe{Port: 33434}, logger: log.NewNopLogger()}
+	udp := make([]byte, 8)
+	binary.BigEndian.PutUint16(udp[0:2], 50000)
+	binary.BigEndian.PutUint16(udp[2:4], 33434)
+	unreachable := func(code int) *icmp.Message {
+		return &icmp.Message{
+			Type: ipv4.ICMPTypeDestinationUnreachable,
+			Code: code,
+			Body: &icmp.DstUnreach{Data: quotedIPv4(protocolUDP, dst, udp)},
+		}
+	}
+
+	for _, tc := range []struct {
+		name        string
+		peer        net.IP
+		msg         *icmp.Message
+		ok, reached bool
+	}{
+		{"port unreachable from target", dst, unreachable(3), true, true},
+		{"host unreachable from router", router, unreachable(1), true, false},
+		{"prohibited from router", router, unreachable(13), true, false},
+		{"port unreachable from router", router, unreachable(3), true, false},
+		{"time exceeded", router, &icmp.Message{
+			Type: ipv4.ICMPTypeTimeExceeded,
+			Body: &icmp.TimeExceeded{Data: quotedIPv4(protocolUDP, dst, udp)},
+		}, true, false},
+	} {
+		ok, reached := tr.udpReply(tc.peer, tc.msg, 50000)
+		if ok != tc.ok || reached != tc.reached {
+			t.Errorf("%s: expected answer %v and reached %v, got %v and %v", tc.name, tc.ok, tc.reached, ok, reached)
+		}
+	}
+	if ok, _ := tr.udpReply(dst, unreachable(3), 50001); ok {
+		t.Errorf("Expected an error for another datagram not to match")
+	}
+
+	dst6 := net.ParseIP("2001:db8::1")
+	tr6 := &tracer{dst: dst6, isIPv6: true, probe: config.TracerouteProbe{Port: 33434}, logger: log.NewNopLogger()}
+	udp6 := make([]byte, 48)
+	udp6[6] = protocolUDP
+	copy(udp6[24:40], dst6)
+	binary.BigEndian.PutUint16(udp6[40:42], 50000)
+	binary.BigEndian.PutUint16(udp6[42:44], 33434)
+	for code, want := range map[int]bool{4: true, 0: false, 1: false, 3: false} {
+		msg := &icmp.Message{Type: ipv6.ICMPTypeDestinationUnreachable, Code: code, Body: &icmp.DstUnreach{Data: udp6}}
+		if ok, reached := tr6.udpReply(dst6, msg, 50000); !ok || reached != want {
+			t.Errorf("IPv6 code %d: expected answer with reached %v, got %v and %v", code, want, ok, reached)
+		}
+	}
+}
+
+func TestTraceHopStatistics(t *testing.T) {
+	hop := &traceHop{ttl: 3, sent: 4}
+	hop.record("198.51.100.1", 10*time.Millisecond)
+	hop.record("198.51.100.1", 30*time.Millisecond)
+	hop.record("198.51.100.2", 20*time.Millisecond)
+
+	if len(hop.addresses) != 2 {
+		t.Errorf("Expected 2 distinct addresses, got %v", hop.addresses)
+	}
+	if loss := hop.lossRatio(); loss != 0.25 {
+		t.Errorf("Expected loss ratio 0.25, got %v", loss)
+	}
+	min, avg, max := hop.rttStats()
+	if min != 10*time.Millisecond || avg != 20*time.Millisecond || max != 30*time.Millisecond {
+		t.Errorf("Unexpected RTT statistics min=%v avg=%v max=%v", min, avg, max)
+	}
+
+	silent := &traceHop{ttl: 4, sent: 3}
+	if loss := silent.lossRatio(); loss != 1 {
+		t.Errorf("Expected loss ratio 1 for silent hop, got %v", loss)
+	}
+}
+
+func TestTracePathHash(t *testing.T) {
+	path := func(addrs ...string) []*traceHop {
+		var hops []*traceHop
+		for i, a := range addrs {
+			hop := &traceHop{ttl: i + 1, sent: 1}
+			if a != "" {
+				hop.record(a, time.Millisecond)
+			}
+			hops = append(hops, hop)
+		}
+		return hops
+	}
+
+	a := tracePathHash(path("10.0.0.1", "", "192.0.2.1"))
+	if b := tracePathHash(path("10.0.0.1", "", "192.0.2.1")); a != b {
+		t.Errorf("Expected identical paths to hash the same")
+	}
+	if b := tracePathHash(path("10.0.0.1", "10.0.0.2", "192.0.2.1")); a == b {
+		t.Errorf("Expected a changed hop to change the hash")
+	}
+	if b := tracePathHash(path("10.0.0.1", "192.0.2.1")); a == b {
+		t.Errorf("Expected a shorter path to change the hash")
+	}
+
+	// Replies from load balanced routers arrive in any order.
+	balanced := path("10.0.0.1", "10.0.0.2", "192.0.2.1")
+	balanced[1].record("10.0.0.3", time.Millisecond)
+	reordered := path("10.0.0.1", "10.0.0.3", "192.0.2.1")
+	reordered[1].record("10.0.0.2", time.Millisecond)
+	if a, b := tracePathHash(balanced), tracePathHash(reordered); a != b {
+		t.Errorf("Expected the order of replies not to change the hash")
+	}
+	if reordered[1].addresses[0] != "10.0.0.3" {
+		t.Errorf("Expected the addresses of the hop to be left unchanged, got %v", reordered[1].addresses)
+	}
+}
+
+func TestTracerouteLocalhostTCP(t *testing.T) {
+	conn, err := icmp.ListenPacket("ip4:icmp", "127.0.0.1")
+	if err != nil {
+		t.Skipf("Raw ICMP sockets are not available: %s", err)
+	}
+	conn.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+	// A port that was just closed refuses connections, which also reaches
+	// the destination.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	for name, port := range map[string]int{
+		"open":    ln.Addr().(*net.TCPAddr).Port,
+		"refused": closedPort,
+	} {
+		t.Run(name, func(t *testing.T) {
+			module := config.Module{
+				Timeout: 10 * time.Second,
+				Traceroute: config.TracerouteProbe{
+					Protocol:     "tcp",
+					IPProtocol:   "ip4",
+					Port:         port,
+					FirstHop:     1,
+					MaxHops:      3,
+					Queries:      3,
+					QueryTimeout: 5 * time.Second,
+				},
+			}
+			registry := prometheus.NewRegistry()
+			testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			start := time.Now()
+			if !ProbeTraceroute(testCTX, "127.0.0.1", module, registry, log.NewNopLogger()) {
+				t.Fatalf("TCP traceroute to localhost failed")
+			}
+			// A lost wake-up waits out the query timeout.
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Errorf("Expected the connections to end the queries, took %s", elapsed)
+			}
+			mfs, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRegistryResults(map[string]float64{
+				"probe_traceroute_hops":                1,
+				"probe_traceroute_destination_reached": 1,
+				"probe_traceroute_hop_loss_ratio":      0,
+			}, mfs, t)
+		})
+	}
+}
+
+func TestTracerouteLocalhost(t *testing.T) {
+	conn, err := icmp.ListenPacket("ip4:icmp", "127.0.0.1")
+	if err != nil {
+		t.Skipf("Raw ICMP sockets are not available: %s", err)
+	}
+	conn.Close()
+
+	for _, protocol := range []string{"icmp", "udp"} {
+		t.Run(protocol, func(t *testing.T) {
+			module := config.Module{
+				Timeout: time.Second,
+				Traceroute: config.TracerouteProbe{
+					Protocol:           protocol,
+					IPProtocol:         "ip4",
+					IPProtocolFallback: true,
+					FirstHop:           1,
+					MaxHops:            3,
+					Queries:            2,
+					QueryTimeout:       time.Second,
+				},
+			}
+			registry := prometheus.NewRegistry()
+			testCTX, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if !ProbeTraceroute(testCTX, "127.0.0.1", module, registry, log.NewNopLogger()) {
+				t.Fatalf("Traceroute to localhost failed")
+			}
+			mfs, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkRegistryResults(map[string]float64{
+				"probe_traceroute_hops":                1,
+				"probe_traceroute_destination_reached": 1,
+				"probe_traceroute_hop_loss_ratio":      0,
+			}, mfs, t)
+			checkRegistryLabels(map[string]map[string]string{
+				"probe_traceroute_hop_info": {"hop": "1", "address": "127.0.0.1"},
+			}, mfs, t)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of probes. Probes are traced with the
+// global tracer provider, they are not traced unless an application sets
+// one, for example with tracing.NewOTLPProvider.
+const tracerName = "github.com/abialemuel/prometheus-exporter/blackbox/prober"
+
+func probeTracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+// recordPhase adds a span for a phase of a probe that ran from start to end
+// to the span in ctx.
+func recordPhase(ctx context.Context, phase string, start, end time.Time, err error, attrs ...attribute.KeyValue) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return
+	}
+	_, span := probeTracer().Start(ctx, phase, trace.WithTimestamp(start),
+		trace.WithAttributes(append(attrs, attribute.String("probe.phase", phase))...))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+}
+
+// endProbeSpan records the outcome of a probe or attempt and ends its span.
+func endProbeSpan(span trace.Span, success bool, reason string) {
+	span.SetAttributes(attribute.Bool("probe.success", success))
+	if !success {
+		if reason != "" {
+			span.SetAttributes(attribute.String("probe.failure_reason", reason))
+		}
+		span.SetStatus(codes.Error, "probe failed")
+	}
+	span.End()
+}
+
+// recordRoundTrip adds a span for an HTTP round trip, one per redirect,
+// with spans for its phases.
+func recordRoundTrip(ctx context.Context, redirect int, rt *roundTripTrace) {
+	if !trace.SpanFromContext(ctx).IsRecording() {
+		return
+	}
+	end := rt.end
+	for _, t := range []time.Time{rt.responseStart, rt.gotConn, rt.tlsDone, rt.connectDone, rt.dnsDone, rt.requested} {
+		if end.IsZero() {
+			end = t
+		}
+	}
+	ctx, span := probeTracer().Start(ctx, "http.request", trace.WithTimestamp(rt.requested),
+		trace.WithAttributes(attribute.String("url.full", rt.url), attribute.Int("http.redirect", redirect)))
+	defer span.End(trace.WithTimestamp(end))
+
+	// The connection of a request can be reused, leaving its resolve and
+	// connect phases unset.
+	if !rt.start.IsZero() && rt.dnsDone.After(rt.start) {
+		recordPhase(ctx, "resolve", rt.start, rt.dnsDone, nil)
+	}
+	if rt.gotConn.IsZero() {
+		span.SetStatus(codes.Error, "no connection")
+		return
+	}
+	if !rt.dnsDone.IsZero() {
+		if rt.tls {
+			recordPhase(ctx, "connect", rt.dnsDone, rt.connectDone, nil)
+			recordPhase(ctx, "tls", rt.tlsStart, rt.tlsDone, nil)
+		} else {
+			recordPhase(ctx, "connect", rt.dnsDone, rt.gotConn, nil)
+		}
+	}
+	if rt.responseStart.IsZero() {
+		span.SetStatus(codes.Error, "no response")
+		return
+	}
+	recordPhase(ctx, "processing", rt.gotConn, rt.responseStart, nil)
+	if !rt.end.IsZero() {
+		recordPhase(ctx, "transfer", rt.responseStart, rt.end, nil)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	pconfig "github.com/prometheus/common/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+)
+
+func TestProbeSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	cfg := &config.Config{Modules: map[string]config.Module{"http_2xx": {
+		Prober:  "http",
+		Timeout: 5 * time.Second,
+		HTTP: config.HTTPProbe{
+			IPProtocol:       "ip4",
+			HTTPClientConfig: pconfig.DefaultHTTPClientConfig,
+		},
+	}}}
+	result, err := Call(ts.URL+"/redirect", "http_2xx", cfg, log.NewNopLogger(), &ResultHistory{MaxResults: 1}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success() {
+		t.Fatalf("Expected probe to succeed")
+	}
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = append(spans[s.Name()], s)
+	}
+	root := spans["probe http"]
+	if len(root) != 1 {
+		t.Fatalf("Expected one probe span, got %v", spans)
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range root[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	for key, want := range map[attribute.Key]string{
+		"probe.target": ts.URL + "/redirect",
+		"probe.module": "http_2xx",
+		"probe.ip":     "127.0.0.1",
+	} {
+		if got := attrs[key].AsString(); got != want {
+			t.Errorf("Expected %s %q, got %q", key, want, got)
+		}
+	}
+	if !attrs["probe.success"].AsBool() {
+		t.Errorf("Expected probe span to record success")
+	}
+
+	rootID := root[0].SpanContext().SpanID()
+	if r := spans["resolve"]; len(r) != 1 || r[0].Parent().SpanID() != rootID {
+		t.Errorf("Expected a resolve span below the probe span, got %v", r)
+	}
+	requests := spans["http.request"]
+	if len(requests) != 2 {
+		t.Fatalf("Expected a span per redirect, got %d", len(requests))
+	}
+	requestIDs := map[string]bool{}
+	for _, r := range requests {
+		if r.Parent().SpanID() != rootID {
+			t.Errorf("Expected request span below the probe span")
+		}
+		requestIDs[r.SpanContext().SpanID().String()] = true
+	}
+	// The body of the redirect is not read.
+	for phase, want := range map[string]int{"processing": 2, "transfer": 1} {
+		if len(spans[phase]) != want {
+			t.Errorf("Expected %d %s spans, got %d", want, phase, len(spans[phase]))
+		}
+		for _, s := range spans[phase] {
+			if !requestIDs[s.Parent().SpanID().String()] {
+				t.Errorf("Expected %s span below a request span", phase)
+			}
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+	"github.com/abialemuel/prometheus-exporter/blackbox/twamp"
+)
+
+// twampReply is a reflected test packet with the four timestamps of its
+// round trip: T1 sent, T2 received and T3 reflected by the reflector, T4
+// received back.
+type twampReply struct {
+	seq            int
+	t1, t2, t3, t4 time.Time
+	ttl            uint8
+}
+
+func (r twampReply) roundTrip() time.Duration { return r.t4.Sub(r.t1) - r.t3.Sub(r.t2) }
+func (r twampReply) forward() time.Duration   { return r.t2.Sub(r.t1) }
+func (r twampReply) backward() time.Duration  { return r.t4.Sub(r.t3) }
+
+// twampSession collects the replies to a test session. Replies are recorded
+// by the receiving goroutine and read once it returned.
+type twampSession struct {
+	count   int
+	mu      sync.Mutex
+	replies []twampReply
+	unique  map[int]bool
+	invalid int
+	done    chan struct{}
+}
+
+func newTWAMPSession(count int) *twampSession {
+	return &twampSession{count: count, unique: make(map[int]bool), done: make(chan struct{})}
+}
+
+// receive reads replies until the connection is closed.
+func (s *twampSession) receive(conn net.Conn) {
+	b := make([]byte, 65536)
+	for {
+		n, err := conn.Read(b)
+		t4 := time.Now()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// ICMP errors for earlier packets surface as read errors on
+			// connected sockets.
+			continue
+		}
+		p, err := twamp.ParseReflectorPacket(b[:n])
+		if err != nil || int(p.SenderSeq) >= s.count {
+			s.mu.Lock()
+			s.invalid++
+			s.mu.Unlock()
+			continue
+		}
+		s.record(twampReply{
+			seq: int(p.SenderSeq),
+			t1:  p.SenderTimestamp,
+			t2:  p.ReceiveTimestamp,
+			t3:  p.Timestamp,
+			t4:  t4,
+			ttl: p.SenderTTL,
+		})
+	}
+}
+
+func (s *twampSession) record(r twampReply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, r)
+	if s.unique[r.seq] {
+		return
+	}
+	s.unique[r.seq] = true
+	if len(s.unique) == s.count {
+		close(s.done)
+	}
+}
+
+// twampDelayStats returns the minimum, average and maximum of the observed
+// delays.
+func twampDelayStats(ds []time.Duration) (min, avg, max time.Duration) {
+	if len(ds) == 0 {
+		return 0, 0, 0
+	}
+	min, max = ds[0], ds[0]
+	var sum time.Duration
+	for _, d := range ds {
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+		sum += d
+	}
+	return min, sum / time.Duration(len(ds)), max
+}
+
+// twampTarget splits the target into host and port, the port is optional.
+func twampTarget(target string, port int) (string, string) {
+	if host, p, err := net.SplitHostPort(target); err == nil {
+		return host, p
+	}
+	if port == 0 {
+		port = twamp.DefaultPort
+	}
+	return target, strconv.Itoa(port)
+}
+
+func ProbeTWAMP(ctx context.Context, target string, module config.Module, registry *prometheus.Registry, logger log.Logger) (success bool) {
+	var (
+		durationGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_twamp_duration_seconds",
+			Help: "Duration of the TWAMP probe by phase",
+		}, []string{"phase"})
+
+		packetsSentGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_twamp_packets_sent",
+			Help: "Returns the number of test packets sent",
+		})
+
+		packetsReceivedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_twamp_packets_received",
+			Help: "Returns the number of test packets reflected, without duplicates",
+		})
+
+		packetLossGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_twamp_packet_loss_ratio",
+			Help: "Returns the ratio of test packets that were not reflected",
+		})
+
+		packetsDuplicatedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_twamp_packets_duplicated",
+			Help: "Returns the number of duplicated reflected packets",
+		})
+
+		packetsReorderedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_twamp_packets_reordered",
+			Help: "Returns the number of reflected packets received out of order",
+		})
+
+		delayGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_twamp_delay_seconds",
+			Help: "Delay of the test packets by direction. One-way delays include the clock offset between sender and reflector",
+		}, []string{"direction", "aggregate"})
+
+		jitterGaugeVec = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_twamp_jitter_seconds",
+			Help: "RFC 3550 interarrival jitter of the test packets by direction",
+		}, []string{"direction"})
+
+		reflectorTTLGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "probe_twamp_reflector_received_ttl",
+			Help: "Returns the TTL of the last test packet received by the reflector",
+		})
+	)
+
+	for _, lv := range []string{"resolve", "test"} {
+		durationGaugeVec.WithLabelValues(lv)
+	}
+
+	registry.MustRegister(durationGaugeVec)
+	registry.MustRegister(packetsSentGauge)
+	registry.MustRegister(packetsReceivedGauge)
+	registry.MustRegister(packetLossGauge)
+	registry.MustRegister(packetsDuplicatedGauge)
+	registry.MustRegister(packetsReorderedGauge)
+	registry.MustRegister(delayGaugeVec)
+	registry.MustRegister(jitterGaugeVec)
+	registry.MustRegister(reflectorTTLGauge)
+
+	probe := module.TWAMP
+	if probe.Count < 1 {
+		probe.Count = config.DefaultTWAMPProbe.Count
+	}
+	if probe.ReplyTimeout <= 0 {
+		probe.ReplyTimeout = config.DefaultTWAMPProbe.ReplyTimeout
+	}
+
+	host, port := twampTarget(target, probe.Port)
+	dstIPAddr, lookupTime, err := chooseProtocol(ctx, probe.IPProtocol, probe.IPProtocolFallback, host, registry, logger)
+	if err != nil {
+		level.Warn(logger).Log("msg", "Error resolving address", "err", err)
+		return false
+	}
+	durationGaugeVec.WithLabelValues("resolve").Add(lookupTime)
+
+	dstPort, err := strconv.Atoi(port)
+	if err != nil {
+		level.Error(logger).Log("msg", "Error parsing port", "port", port, "err", err)
+		return false
+	}
+	isIPv6 := dstIPAddr.IP.To4() == nil
+	network := "udp4"
+	if isIPv6 {
+		network = "udp6"
+	}
+	var srcAddr *net.UDPAddr
+	if len(probe.SourceIPAddress) > 0 {
+		srcIP := net.ParseIP(probe.SourceIPAddress)
+		if srcIP == nil {
+			level.Error(logger).Log("msg", "Error parsing source ip address", "srcIP", probe.SourceIPAddress)
+			return false
+		}
+		level.Info(logger).Log("msg", "Using source address", "srcIP", srcIP)
+		srcAddr = &net.UDPAddr{IP: srcIP}
+	}
+	conn, err := net.DialUDP(network, srcAddr, &net.UDPAddr{IP: dstIPAddr.IP, Port: dstPort, Zone: dstIPAddr.Zone})
+	if err != nil {
+		level.Error(logger).Log("msg", "Error dialing reflector", "err", err)
+		return false
+	}
+	defer conn.Close()
+
+	if probe.DSCP > 0 {
+		if isIPv6 {
+			err = ipv6.NewConn(conn).SetTrafficClass(probe.DSCP << 2)
+		} else {
+			err = ipv4.NewConn(conn).SetTOS(probe.DSCP << 2)
+		}
+		if err != nil {
+			level.Error(logger).Log("msg", "Error setting DSCP", "err", err)
+			return false
+		}
+	}
+
+	session := newTWAMPSession(probe.Count)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		session.receive(conn)
+	}()
+
+	level.Info(logger).Log("msg", "Starting TWAMP test session", "reflector", conn.RemoteAddr(), "count", probe.Count)
+	testStart := time.Now()
+	sent := 0
+	var sendErr error
+	for seq := 0; seq < probe.Count && ctx.Err() == nil; seq++ {
+		if seq > 0 && probe.Interval > 0 {
+			select {
+			case <-time.After(probe.Interval):
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+		p := twamp.SenderPacket{
+			Seq:           uint32(seq),
+			Timestamp:     time.Now(),
+			ErrorEstimate: twamp.DefaultErrorEstimate,
+			Padding:       probe.PaddingSize,
+		}
+		if _, err := conn.Write(p.Marshal()); err != nil {
+			sendErr = err
+			continue
+		}
+		sent++
+	}
+	if sent > 0 {
+		select {
+		case <-session.done:
+		case <-time.After(probe.ReplyTimeout):
+		case <-ctx.Done():
+		}
+	}
+	conn.Close()
+	wg.Wait()
+	durationGaugeVec.WithLabelValues("test").Add(time.Since(testStart).Seconds())
+	recordPhase(ctx, "test", testStart, time.Now(), sendErr)
+
+	if sendErr != nil {
+		level.Warn(logger).Log("msg", "Error sending test packet", "err", sendErr)
+	}
+	if session.invalid > 0 {
+		level.Debug(logger).Log("msg", "Ignored invalid reflected packets", "count", session.invalid)
+	}
+	packetsSentGauge.Set(float64(sent))
+	if sent == 0 {
+		level.Error(logger).Log("msg", "No test packets sent")
+		return false
+	}
+
+	rtt, fwd, bwd := newQoSStats(), newQoSStats(), newQoSStats()
+	for _, r := range session.replies {
+		rtt.observe(r.seq, r.roundTrip())
+		fwd.observe(r.seq, r.forward())
+		bwd.observe(r.seq, r.backward())
+		reflectorTTLGauge.Set(float64(r.ttl))
+	}
+	received := len(rtt.rtts)
+	packetsReceivedGauge.Set(float64(received))
+	packetLossGauge.Set(float64(sent-received) / float64(sent))
+	packetsDuplicatedGauge.Set(float64(rtt.duplicates))
+	packetsReorderedGauge.Set(float64(rtt.outOfOrder))
+
+	var rttAvg time.Duration
+	for direction, stats := range map[string]*qosStats{"round_trip": rtt, "forward": fwd, "backward": bwd} {
+		min, avg, max := twampDelayStats(stats.rtts)
+		if stats == rtt {
+			rttAvg = avg
+		}
+		delayGaugeVec.WithLabelValues(direction, "min").Set(min.Seconds())
+		delayGaugeVec.WithLabelValues(direction, "avg").Set(avg.Seconds())
+		delayGaugeVec.WithLabelValues(direction, "max").Set(max.Seconds())
+		jitterGaugeVec.WithLabelValues(direction).Set(time.Duration(stats.jitter).Seconds())
+	}
+
+	if received == 0 {
+		level.Error(logger).Log("msg", "No test packets reflected", "sent", sent)
+		return false
+	}
+	level.Info(logger).Log("msg", "TWAMP test session finished", "sent", sent, "received", received, "rtt_avg", rttAvg)
+	return true
+}
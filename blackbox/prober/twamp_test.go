@@ -0,0 +1,124 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abialemuel/prometheus-exporter/blackbox/config"
+	"github.com/abialemuel/prometheus-exporter/blackbox/twamp"
+)
+
+func TestTWAMPReplyDelays(t *testing.T) {
+	t1 := time.Unix(1700000000, 0)
+	// The reflector clock is 1s ahead of the sender clock.
+	r := twampReply{
+		t1: t1,
+		t2: t1.Add(time.Second + 3*time.Millisecond),
+		t3: t1.Add(time.Second + 4*time.Millisecond),
+		t4: t1.Add(9 * time.Millisecond),
+	}
+	if rtt := r.roundTrip(); rtt != 8*time.Millisecond {
+		t.Errorf("Expected round trip of 8ms without reflector processing time, got %v", rtt)
+	}
+	if fwd, bwd := r.forward(), r.backward(); fwd+bwd != r.roundTrip() {
+		t.Errorf("Expected one-way delays %v and %v to add up to the round trip", fwd, bwd)
+	}
+}
+
+func TestTWAMPTarget(t *testing.T) {
+	for _, tc := range []struct {
+		target, host, port string
+		configured         int
+	}{
+		{"192.0.2.1", "192.0.2.1", "862", 0},
+		{"192.0.2.1", "192.0.2.1", "4000", 4000},
+		{"192.0.2.1:5000", "192.0.2.1", "5000", 4000},
+		{"[2001:db8::1]:5000", "2001:db8::1", "5000", 0},
+		{"2001:db8::1", "2001:db8::1", "862", 0},
+	} {
+		host, port := twampTarget(tc.target, tc.configured)
+		if host != tc.host || port != tc.port {
+			t.Errorf("Target %q: expected %s %s, got %s %s", tc.target, tc.host, tc.port, host, port)
+		}
+	}
+}
+
+func TestTWAMPLocalhost(t *testing.T) {
+	reflector, err := twamp.Listen("udp4", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reflector.Close()
+	go reflector.Serve()
+
+	module := config.Module{
+		Timeout: 5 * time.Second,
+		TWAMP: config.TWAMPProbe{
+			IPProtocol:         "ip4",
+			IPProtocolFallback: true,
+			Port:               reflector.Addr().(*net.UDPAddr).Port,
+			Count:              5,
+			Interval:           10 * time.Millisecond,
+			ReplyTimeout:       time.Second,
+			PaddingSize:        twamp.SymmetricPadding,
+			DSCP:               46,
+		},
+	}
+	registry := prometheus.NewRegistry()
+	testCTX, cancel := context.WithTimeout(context.Background(), module.Timeout)
+	defer cancel()
+	if !ProbeTWAMP(testCTX, "127.0.0.1", module, registry, log.NewNopLogger()) {
+		t.Fatalf("TWAMP probe of localhost reflector failed")
+	}
+	mfs, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRegistryResults(map[string]float64{
+		"probe_twamp_packets_sent":       5,
+		"probe_twamp_packets_received":   5,
+		"probe_twamp_packet_loss_ratio":  0,
+		"probe_twamp_packets_duplicated": 0,
+		"probe_twamp_packets_reordered":  0,
+	}, mfs, t)
+	for _, mf := range mfs {
+		if mf.GetName() != "probe_twamp_delay_seconds" {
+			continue
+		}
+		for _, m := range mf.Metric {
+			if v := m.GetGauge().GetValue(); v <= 0 || v > 1 {
+				t.Errorf("Unexpected delay %v for %v", v, m.GetLabel())
+			}
+		}
+	}
+
+	// Nothing listens on the port of a closed reflector.
+	closed, err := twamp.Listen("udp4", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+	module.TWAMP.ReplyTimeout = 100 * time.Millisecond
+	if ProbeTWAMP(testCTX, "127.0.0.1:"+strconv.Itoa(closed.Addr().(*net.UDPAddr).Port), module, prometheus.NewRegistry(), log.NewNopLogger()) {
+		t.Errorf("Expected TWAMP probe without reflector to fail")
+	}
+}
@@ -25,6 +25,8 @@ import (
 	"github.com/go-kit/log/level"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var protocolToGauge = map[string]float64{
@@ -67,6 +69,10 @@ func chooseProtocol(ctx context.Context, IPProtocol string, fallbackIPProtocol b
 	defer func() {
 		lookupTime = time.Since(resolveStart).Seconds()
 		probeDNSLookupTimeSeconds.Add(lookupTime)
+		recordPhase(ctx, "resolve", resolveStart, time.Now(), err, attribute.String("probe.ip_protocol", IPProtocol))
+		if ip != nil {
+			trace.SpanFromContext(ctx).SetAttributes(attribute.String("probe.ip", ip.IP.String()))
+		}
 	}()
 
 	resolver := &net.Resolver{}
@@ -0,0 +1,77 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"math"
+	"time"
+)
+
+// codecProfile holds the ITU-T G.113 impairment values of a codec.
+type codecProfile struct {
+	// ie is the equipment impairment factor without packet loss.
+	ie float64
+	// bpl is the packet-loss robustness factor.
+	bpl float64
+	// delay is the packetization and algorithmic delay added to the network
+	// delay.
+	delay time.Duration
+}
+
+var codecProfiles = map[string]codecProfile{
+	// G.711 with packet loss concealment, 20ms packets.
+	"g711": {ie: 0, bpl: 25.1, delay: 20 * time.Millisecond},
+	// G.729A with VAD, two 10ms frames per packet plus 5ms look-ahead.
+	"g729": {ie: 11, bpl: 19, delay: 25 * time.Millisecond},
+}
+
+// rFactor computes the ITU-T G.107 E-model transmission rating for a call
+// with the given one-way delay and packet loss percentage. All parameters
+// other than delay and loss are left at their G.107 defaults, for which the
+// basic signal-to-noise ratio minus the simultaneous impairment is 93.2.
+func rFactor(profile codecProfile, oneWayDelay time.Duration, lossPercentage float64) float64 {
+	const r0MinusIs = 93.2
+
+	// Delay impairment, only the talker echo free Idd term contributes with
+	// the default echo loss.
+	var idd float64
+	if ta := float64(oneWayDelay+profile.delay) / float64(time.Millisecond); ta > 100 {
+		x := math.Log10(ta/100) / math.Log10(2)
+		idd = 25 * (math.Pow(1+math.Pow(x, 6), 1.0/6) - 3*math.Pow(1+math.Pow(x/3, 6), 1.0/6) + 2)
+	}
+
+	// Effective equipment impairment for random loss (BurstR = 1).
+	ieEff := profile.ie + (95-profile.ie)*lossPercentage/(lossPercentage+profile.bpl)
+
+	return r0MinusIs - idd - ieEff
+}
+
+// mosFromR converts an R-factor to an estimated mean opinion score (G.107
+// Annex B). The polynomial dips below 1 for R under 6.5, where it is clamped.
+func mosFromR(r float64) float64 {
+	switch {
+	case r <= 0:
+		return 1
+	case r >= 100:
+		return 4.5
+	}
+	return math.Max(1, 1+0.035*r+r*(r-60)*(100-r)*7e-6)
+}
+
+// voiceQuality estimates the R-factor and MOS from ping results. The one-way
+// delay is half the average RTT plus a jitter buffer of twice the jitter.
+func voiceQuality(profile codecProfile, avgRTT, jitter time.Duration, lossPercentage float64) (r, mos float64) {
+	r = rFactor(profile, avgRTT/2+2*jitter, lossPercentage)
+	return r, mosFromR(r)
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestVoiceQuality(t *testing.T) {
+	tests := []struct {
+		name    string
+		codec   string
+		avgRTT  time.Duration
+		jitter  time.Duration
+		loss    float64
+		wantR   float64
+		wantMOS float64
+	}{
+		{name: "g711 clean", codec: "g711", avgRTT: 40 * time.Millisecond, wantR: 93.2, wantMOS: 4.409},
+		{name: "g729 clean", codec: "g729", avgRTT: 40 * time.Millisecond, wantR: 82.2, wantMOS: 4.104},
+		{name: "g711 one percent loss", codec: "g711", avgRTT: 40 * time.Millisecond, loss: 1, wantR: 89.56, wantMOS: 4.328},
+		// One-way delay of 380ms plus 20ms packetization.
+		{name: "g711 satellite delay", codec: "g711", avgRTT: 760 * time.Millisecond, wantR: 69.13, wantMOS: 3.556},
+		// A jitter buffer of 2*90ms pushes the delay past 100ms.
+		{name: "g711 jitter", codec: "g711", avgRTT: 0, jitter: 90 * time.Millisecond, wantR: 90.16, wantMOS: 4.343},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, mos := voiceQuality(codecProfiles[test.codec], test.avgRTT, test.jitter, test.loss)
+			if math.Abs(r-test.wantR) > 0.05 {
+				t.Errorf("Expected R-factor %v, got %v", test.wantR, r)
+			}
+			if math.Abs(mos-test.wantMOS) > 0.005 {
+				t.Errorf("Expected MOS %v, got %v", test.wantMOS, mos)
+			}
+		})
+	}
+}
+
+func TestMOSFromR(t *testing.T) {
+	for r, want := range map[float64]float64{-10: 1, 0: 1, 100: 4.5, 120: 4.5} {
+		if got := mosFromR(r); got != want {
+			t.Errorf("Expected MOS %v for R %v, got %v", want, r, got)
+		}
+	}
+	prev := 0.0
+	for r := 1.0; r < 100; r++ {
+		mos := mosFromR(r)
+		if mos < prev {
+			t.Errorf("MOS decreased from %v to %v at R %v", prev, mos, r)
+		}
+		prev = mos
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package twamp implements the unauthenticated test packets of TWAMP-Light
+// (RFC 5357) and a reflector answering them.
+package twamp
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultPort is the well-known TWAMP port, used by TWAMP-Light
+	// reflectors by convention.
+	DefaultPort = 862
+
+	// SenderHeaderLen is the size of an unauthenticated sender packet
+	// without padding.
+	SenderHeaderLen = 14
+	// ReflectorHeaderLen is the size of an unauthenticated reflector packet
+	// without padding.
+	ReflectorHeaderLen = 41
+	// SymmetricPadding pads sender packets to the size of the reflected
+	// packets, so that both directions carry packets of the same size.
+	SymmetricPadding = ReflectorHeaderLen - SenderHeaderLen
+
+	// DefaultErrorEstimate claims an unsynchronized clock with an error of
+	// 2^-10 seconds (about 1ms): S=0, Z=0, Scale=22, Multiplier=1.
+	DefaultErrorEstimate uint16 = 22<<8 | 1
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch (1900) and
+// the Unix epoch (1970).
+const ntpEpochOffset = 2208988800
+
+// ToNTP converts a time to the 64 bit NTP timestamp format used by TWAMP.
+// Conversions round to the nearest unit, so that times survive a round trip
+// with nanosecond precision.
+func ToNTP(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := (uint64(t.Nanosecond())<<32 + uint64(time.Second)/2) / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+// FromNTP converts a 64 bit NTP timestamp to a time.
+func FromNTP(ts uint64) time.Time {
+	secs := int64(ts>>32) - ntpEpochOffset
+	nsecs := ((ts&0xffffffff)*uint64(time.Second) + 1<<31) >> 32
+	return time.Unix(secs, int64(nsecs))
+}
+
+// SenderPacket is an unauthenticated TWAMP-Test packet sent by the
+// Session-Sender (RFC 5357 section 4.1.2).
+type SenderPacket struct {
+	Seq           uint32
+	Timestamp     time.Time
+	ErrorEstimate uint16
+	// Padding is the number of zero bytes appended to the packet.
+	Padding int
+}
+
+// Marshal encodes the packet.
+func (p *SenderPacket) Marshal() []byte {
+	b := make([]byte, SenderHeaderLen+p.Padding)
+	binary.BigEndian.PutUint32(b[0:4], p.Seq)
+	binary.BigEndian.PutUint64(b[4:12], ToNTP(p.Timestamp))
+	binary.BigEndian.PutUint16(b[12:14], p.ErrorEstimate)
+	return b
+}
+
+// ParseSenderPacket decodes a sender packet.
+func ParseSenderPacket(b []byte) (*SenderPacket, error) {
+	if len(b) < SenderHeaderLen {
+		return nil, errors.New("sender packet too short")
+	}
+	return &SenderPacket{
+		Seq:           binary.BigEndian.Uint32(b[0:4]),
+		Timestamp:     FromNTP(binary.BigEndian.Uint64(b[4:12])),
+		ErrorEstimate: binary.BigEndian.Uint16(b[12:14]),
+		Padding:       len(b) - SenderHeaderLen,
+	}, nil
+}
+
+// ReflectorPacket is an unauthenticated TWAMP-Test packet sent by the
+// Session-Reflector (RFC 5357 section 4.2.1).
+type ReflectorPacket struct {
+	Seq                 uint32
+	Timestamp           time.Time
+	ErrorEstimate       uint16
+	ReceiveTimestamp    time.Time
+	SenderSeq           uint32
+	SenderTimestamp     time.Time
+	SenderErrorEstimate uint16
+	SenderTTL           uint8
+	// Padding is the number of zero bytes appended to the packet.
+	Padding int
+}
+
+// Marshal encodes the packet.
+func (p *ReflectorPacket) Marshal() []byte {
+	b := make([]byte, ReflectorHeaderLen+p.Padding)
+	binary.BigEndian.PutUint32(b[0:4], p.Seq)
+	binary.BigEndian.PutUint64(b[4:12], ToNTP(p.Timestamp))
+	binary.BigEndian.PutUint16(b[12:14], p.ErrorEstimate)
+	// b[14:16] must be zero.
+	binary.BigEndian.PutUint64(b[16:24], ToNTP(p.ReceiveTimestamp))
+	binary.BigEndian.PutUint32(b[24:28], p.SenderSeq)
+	binary.BigEndian.PutUint64(b[28:36], ToNTP(p.SenderTimestamp))
+	binary.BigEndian.PutUint16(b[36:38], p.SenderErrorEstimate)
+	// b[38:40] must be zero.
+	b[40] = p.SenderTTL
+	return b
+}
+
+// ParseReflectorPacket decodes a reflector packet.
+func ParseReflectorPacket(b []byte) (*ReflectorPacket, error) {
+	if len(b) < ReflectorHeaderLen {
+		return nil, errors.New("reflector packet too short")
+	}
+	return &ReflectorPacket{
+		Seq:                 binary.BigEndian.Uint32(b[0:4]),
+		Timestamp:           FromNTP(binary.BigEndian.Uint64(b[4:12])),
+		ErrorEstimate:       binary.BigEndian.Uint16(b[12:14]),
+		ReceiveTimestamp:    FromNTP(binary.BigEndian.Uint64(b[16:24])),
+		SenderSeq:           binary.BigEndian.Uint32(b[24:28]),
+		SenderTimestamp:     FromNTP(binary.BigEndian.Uint64(b[28:36])),
+		SenderErrorEstimate: binary.BigEndian.Uint16(b[36:38]),
+		SenderTTL:           b[40],
+		Padding:             len(b) - ReflectorHeaderLen,
+	}, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package twamp
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// Reflector answers TWAMP-Light test packets. It is stateless, every
+// reflected packet carries the sequence number of the packet it answers.
+type Reflector struct {
+	conn   *net.UDPConn
+	p4     *ipv4.PacketConn
+	p6     *ipv6.PacketConn
+	logger log.Logger
+}
+
+// Listen starts a reflector on a UDP address such as ":862". Serve has to be
+// called to answer packets.
+func Listen(network, address string, logger log.Logger) (*Reflector, error) {
+	addr, err := net.ResolveUDPAddr(network, address)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewReflector(conn, logger), nil
+}
+
+// NewReflector returns a reflector answering packets received on conn.
+func NewReflector(conn *net.UDPConn, logger log.Logger) *Reflector {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+	r := &Reflector{conn: conn, logger: logger}
+	// Reflected packets are sent with the maximum TTL, and the TTL of the
+	// received packets is reported back to the sender (RFC 5357 section
+	// 4.2.1). Both are best effort.
+	if ip := conn.LocalAddr().(*net.UDPAddr).IP; ip.To4() != nil {
+		r.p4 = ipv4.NewPacketConn(conn)
+		r.p4.SetControlMessage(ipv4.FlagTTL, true)
+		r.p4.SetTTL(255)
+	} else {
+		r.p6 = ipv6.NewPacketConn(conn)
+		r.p6.SetControlMessage(ipv6.FlagHopLimit, true)
+		r.p6.SetHopLimit(255)
+	}
+	return r
+}
+
+// Addr returns the local address of the reflector.
+func (r *Reflector) Addr() net.Addr {
+	return r.conn.LocalAddr()
+}
+
+// Close stops the reflector.
+func (r *Reflector) Close() error {
+	return r.conn.Close()
+}
+
+func (r *Reflector) read(b []byte) (n int, ttl uint8, peer net.Addr, err error) {
+	ttl = 255
+	if r.p4 != nil {
+		var cm *ipv4.ControlMessage
+		n, cm, peer, err = r.p4.ReadFrom(b)
+		if cm != nil {
+			ttl = uint8(cm.TTL)
+		}
+		return n, ttl, peer, err
+	}
+	var cm *ipv6.ControlMessage
+	n, cm, peer, err = r.p6.ReadFrom(b)
+	if cm != nil && cm.HopLimit > 0 {
+		ttl = uint8(cm.HopLimit)
+	}
+	return n, ttl, peer, err
+}
+
+// Serve reflects test packets until the reflector is closed.
+func (r *Reflector) Serve() error {
+	b := make([]byte, 65536)
+	for {
+		n, ttl, peer, err := r.read(b)
+		received := time.Now()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		req, err := ParseSenderPacket(b[:n])
+		if err != nil {
+			level.Debug(r.logger).Log("msg", "Ignoring invalid test packet", "peer", peer, "err", err)
+			continue
+		}
+
+		// Keep the size of the packet when the sender padded it for
+		// symmetric sizes.
+		padding := n - ReflectorHeaderLen
+		if padding < 0 {
+			padding = 0
+		}
+		reply := ReflectorPacket{
+			Seq:                 req.Seq,
+			ErrorEstimate:       DefaultErrorEstimate,
+			ReceiveTimestamp:    received,
+			SenderSeq:           req.Seq,
+			SenderTimestamp:     req.Timestamp,
+			SenderErrorEstimate: req.ErrorEstimate,
+			SenderTTL:           ttl,
+			Padding:             padding,
+		}
+		reply.Timestamp = time.Now()
+		if _, err := r.conn.WriteTo(reply.Marshal(), peer); err != nil {
+			level.Debug(r.logger).Log("msg", "Error reflecting test packet", "peer", peer, "err", err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package twamp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNTPTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 123456789)
+	got := FromNTP(ToNTP(now))
+	if d := got.Sub(now); d < -time.Nanosecond || d > time.Nanosecond {
+		t.Errorf("Expected %v after NTP round trip, got %v", now, got)
+	}
+	if secs := ToNTP(time.Unix(0, 0)) >> 32; secs != ntpEpochOffset {
+		t.Errorf("Expected Unix epoch at NTP second %d, got %d", ntpEpochOffset, secs)
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	ts := time.Unix(1700000000, 500000000)
+	sender := SenderPacket{Seq: 7, Timestamp: ts, ErrorEstimate: DefaultErrorEstimate, Padding: SymmetricPadding}
+	b := sender.Marshal()
+	if len(b) != ReflectorHeaderLen {
+		t.Fatalf("Expected padded sender packet of %d bytes, got %d", ReflectorHeaderLen, len(b))
+	}
+	parsed, err := ParseSenderPacket(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Seq != 7 || !parsed.Timestamp.Equal(ts) || parsed.ErrorEstimate != DefaultErrorEstimate || parsed.Padding != SymmetricPadding {
+		t.Errorf("Unexpected sender packet: %+v", parsed)
+	}
+
+	reflector := ReflectorPacket{
+		Seq:                 7,
+		Timestamp:           ts.Add(2 * time.Millisecond),
+		ErrorEstimate:       DefaultErrorEstimate,
+		ReceiveTimestamp:    ts.Add(time.Millisecond),
+		SenderSeq:           7,
+		SenderTimestamp:     ts,
+		SenderErrorEstimate: DefaultErrorEstimate,
+		SenderTTL:           62,
+	}
+	r, err := ParseReflectorPacket(reflector.Marshal())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SenderSeq != 7 || r.SenderTTL != 62 || !r.SenderTimestamp.Equal(ts) ||
+		!r.ReceiveTimestamp.Equal(ts.Add(time.Millisecond)) || !r.Timestamp.Equal(ts.Add(2*time.Millisecond)) {
+		t.Errorf("Unexpected reflector packet: %+v", r)
+	}
+
+	if _, err := ParseSenderPacket(b[:SenderHeaderLen-1]); err == nil {
+		t.Errorf("Expected error for truncated sender packet")
+	}
+	if _, err := ParseReflectorPacket(b[:ReflectorHeaderLen-1]); err == nil {
+		t.Errorf("Expected error for truncated reflector packet")
+	}
+}
+
+func TestReflector(t *testing.T) {
+	r, err := Listen("udp4", "127.0.0.1:0", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	go r.Serve()
+
+	conn, err := net.DialUDP("udp4", nil, r.Addr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Packets too short to be test packets are ignored.
+	if _, err := conn.Write([]byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	for seq, padding := range []int{0, SymmetricPadding, 100} {
+		sender := SenderPacket{Seq: uint32(seq), Timestamp: time.Now(), ErrorEstimate: DefaultErrorEstimate, Padding: padding}
+		if _, err := conn.Write(sender.Marshal()); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 1500)
+		n, err := conn.Read(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply, err := ParseReflectorPacket(b[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reply.Seq != uint32(seq) || reply.SenderSeq != uint32(seq) {
+			t.Errorf("Expected sequence number %d, got %d and sender %d", seq, reply.Seq, reply.SenderSeq)
+		}
+		if wantLen := max(ReflectorHeaderLen, SenderHeaderLen+padding); n != wantLen {
+			t.Errorf("Expected reflected packet of %d bytes, got %d", wantLen, n)
+		}
+		if reply.SenderTTL == 0 {
+			t.Errorf("Expected sender TTL to be set")
+		}
+		if reply.ReceiveTimestamp.Before(start.Add(-time.Millisecond)) || reply.Timestamp.Before(reply.ReceiveTimestamp) {
+			t.Errorf("Unexpected timestamps received %v reflected %v", reply.ReceiveTimestamp, reply.Timestamp)
+		}
+	}
+}
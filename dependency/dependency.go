@@ -0,0 +1,319 @@
+// Package dependency tracks parent/child dependencies between probed nodes,
+// so that nodes failing behind a failing parent are reported as
+// unreachable rather than down.
+package dependency
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abialemuel/prometheus-exporter/helper"
+	proto "github.com/abialemuel/prometheus-exporter/messages"
+	"github.com/abialemuel/prometheus-exporter/rules"
+)
+
+// Status of a node.
+type Status string
+
+const (
+	// Unknown nodes have no results yet.
+	Unknown Status = "unknown"
+	Up      Status = "up"
+	Down    Status = "down"
+	// UnreachableParent nodes fail while all of their parents fail too.
+	UnreachableParent Status = "unreachable_parent"
+)
+
+var statuses = []Status{Unknown, Up, Down, UnreachableParent}
+
+// Graph holds the parents of nodes, identified by the NodeId of their
+// WorkerProbes. A node with several parents is reachable through any of
+// them.
+type Graph struct {
+	parents map[string][]string
+}
+
+// NewGraph returns the graph of the parents of each node. It fails if the
+// dependencies contain a cycle.
+func NewGraph(parents map[string][]string) (*Graph, error) {
+	g := &Graph{parents: make(map[string][]string, len(parents))}
+	for node, ps := range parents {
+		if node == "" {
+			return nil, errors.New("node ID must be set")
+		}
+		seen := map[string]bool{}
+		for _, p := range ps {
+			if p == "" {
+				return nil, fmt.Errorf("parent of node %q must be set", node)
+			}
+			if !seen[p] {
+				seen[p] = true
+				g.parents[node] = append(g.parents[node], p)
+			}
+		}
+	}
+	if cycle := g.findCycle(); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+	}
+	return g, nil
+}
+
+// findCycle returns the nodes of a cycle, starting and ending with the same
+// node, or nil.
+func (g *Graph) findCycle() []string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(node string) []string
+	visit = func(node string) []string {
+		switch state[node] {
+		case visiting:
+			for i, n := range path {
+				if n == node {
+					return append(append([]string{}, path[i:]...), node)
+				}
+			}
+		case done:
+			return nil
+		}
+		state[node] = visiting
+		path = append(path, node)
+		for _, p := range g.parents[node] {
+			if cycle := visit(p); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[node] = done
+		return nil
+	}
+
+	// Visit nodes in a stable order for stable error messages.
+	nodes := make([]string, 0, len(g.parents))
+	for node := range g.parents {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	for _, node := range nodes {
+		if cycle := visit(node); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// Parents returns the parents of a node.
+func (g *Graph) Parents(node string) []string {
+	return append([]string(nil), g.parents[node]...)
+}
+
+// Tracker derives the status of nodes from their latest results and the
+// dependency graph. It is safe for concurrent use.
+type Tracker struct {
+	graph *Graph
+
+	mu          sync.RWMutex
+	success     map[string]bool
+	suppressors []*suppressor
+}
+
+// NewTracker returns a tracker for the nodes of a graph. Nodes outside of
+// the graph are tracked without dependencies.
+func NewTracker(g *Graph) *Tracker {
+	return &Tracker{graph: g, success: map[string]bool{}}
+}
+
+// Observe records the latest outcome of the probes of a node. Events held
+// back by Suppress for nodes that are no longer UnreachableParent are
+// passed on.
+func (t *Tracker) Observe(node string, success bool) {
+	t.mu.Lock()
+	t.success[node] = success
+	released := t.release()
+	t.mu.Unlock()
+	released.send()
+}
+
+// ObserveResult records the result of a WorkerProbe for its node.
+func (t *Tracker) ObserveResult(probe *proto.WorkerProbe, result helper.ProbeResult) {
+	t.Observe(probe.GetNodeId(), result.Success())
+}
+
+// Forget drops the results of a node and the events held back for it.
+func (t *Tracker) Forget(node string) {
+	t.mu.Lock()
+	delete(t.success, node)
+	for _, s := range t.suppressors {
+		delete(s.held, node)
+		delete(s.flapping, node)
+	}
+	released := t.release()
+	t.mu.Unlock()
+	released.send()
+}
+
+// Status returns the status of a node.
+func (t *Tracker) Status(node string) Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.status(node)
+}
+
+func (t *Tracker) status(node string) Status {
+	success, ok := t.success[node]
+	switch {
+	case !ok:
+		return Unknown
+	case success:
+		return Up
+	}
+	parents := t.graph.parents[node]
+	if len(parents) == 0 {
+		return Down
+	}
+	for _, p := range parents {
+		// The graph has no cycles, so the recursion ends.
+		if s := t.status(p); s != Down && s != UnreachableParent {
+			return Down
+		}
+	}
+	return UnreachableParent
+}
+
+// Suppress returns a rules event handler holding back the events of targets
+// whose status is UnreachableParent, passing the others to next. The
+// targets of the events are taken as node IDs.
+//
+// Once a target is no longer UnreachableParent, the events held back for
+// each of its rules are passed on as a single event, from the level before
+// the first of them to the level and flapping state of the last. It is
+// dropped if that changes neither the level nor the flapping state.
+func (t *Tracker) Suppress(next func(rules.Event)) func(rules.Event) {
+	s := &suppressor{
+		next:     next,
+		held:     map[string]map[string]rules.Event{},
+		flapping: map[string]map[string]bool{},
+	}
+	t.mu.Lock()
+	t.suppressors = append(t.suppressors, s)
+	t.mu.Unlock()
+
+	return func(ev rules.Event) {
+		t.mu.Lock()
+		s.hold(ev)
+		released := t.release()
+		t.mu.Unlock()
+		released.send()
+	}
+}
+
+// suppressor holds back the events of a Suppress handler by target and
+// rule.
+type suppressor struct {
+	next func(rules.Event)
+	held map[string]map[string]rules.Event
+	// flapping holds the rules last sent as flapping by target.
+	flapping map[string]map[string]bool
+}
+
+func (s *suppressor) hold(ev rules.Event) {
+	byRule, ok := s.held[ev.Target]
+	if !ok {
+		byRule = map[string]rules.Event{}
+		s.held[ev.Target] = byRule
+	}
+	if first, ok := byRule[ev.Rule]; ok {
+		ev.From = first.From
+	}
+	byRule[ev.Rule] = ev
+}
+
+// releasedEvent is an event to pass on once the tracker is unlocked, so
+// that handlers can call the tracker.
+type releasedEvent struct {
+	next func(rules.Event)
+	ev   rules.Event
+}
+
+type releasedEvents []releasedEvent
+
+func (r releasedEvents) send() {
+	for _, e := range r {
+		e.next(e.ev)
+	}
+}
+
+// release returns the held events of targets that are no longer
+// UnreachableParent, ordered by target and rule. t.mu must be held.
+func (t *Tracker) release() releasedEvents {
+	var released releasedEvents
+	for _, s := range t.suppressors {
+		targets := make([]string, 0, len(s.held))
+		for target := range s.held {
+			if t.status(target) != UnreachableParent {
+				targets = append(targets, target)
+			}
+		}
+		sort.Strings(targets)
+		for _, target := range targets {
+			byRule := s.held[target]
+			delete(s.held, target)
+			names := make([]string, 0, len(byRule))
+			for name := range byRule {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				ev := byRule[name]
+				if ev.From == ev.To && ev.Flapping == s.flapping[target][name] {
+					continue
+				}
+				if ev.Flapping {
+					if s.flapping[target] == nil {
+						s.flapping[target] = map[string]bool{}
+					}
+					s.flapping[target][name] = true
+				} else if len(s.flapping[target]) > 0 {
+					delete(s.flapping[target], name)
+					if len(s.flapping[target]) == 0 {
+						delete(s.flapping, target)
+					}
+				}
+				released = append(released, releasedEvent{next: s.next, ev: ev})
+			}
+		}
+	}
+	return released
+}
+
+var statusDesc = prometheus.NewDesc("dependency_node_status",
+	"Status of a node taking the status of its parents into account.", []string{"node_id", "status"}, nil)
+
+// Describe implements prometheus.Collector.
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	ch <- statusDesc
+}
+
+// Collect implements prometheus.Collector.
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for node := range t.success {
+		current := t.status(node)
+		for _, s := range statuses {
+			v := 0.0
+			if s == current {
+				v = 1
+			}
+			ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, v, node, string(s))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package dependency_test
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abialemuel/prometheus-exporter/dependency"
+	"github.com/abialemuel/prometheus-exporter/helper"
+	proto "github.com/abialemuel/prometheus-exporter/messages"
+	"github.com/abialemuel/prometheus-exporter/rules"
+)
+
+func TestNewGraphCycles(t *testing.T) {
+	_, err := dependency.NewGraph(map[string][]string{
+		"switch": {"router"},
+		"router": {"core"},
+		"core":   {"switch"},
+	})
+	assert.EqualError(t, err, "dependency cycle: core -> switch -> router -> core")
+
+	_, err = dependency.NewGraph(map[string][]string{"router": {"router"}})
+	assert.EqualError(t, err, "dependency cycle: router -> router")
+
+	_, err = dependency.NewGraph(map[string][]string{"router": {""}})
+	assert.Error(t, err)
+
+	g, err := dependency.NewGraph(map[string][]string{
+		"server":   {"switch-a", "switch-b", "switch-a"},
+		"switch-a": {"router"},
+		"switch-b": {"router"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"switch-a", "switch-b"}, g.Parents("server"))
+}
+
+func TestTrackerStatus(t *testing.T) {
+	g, err := dependency.NewGraph(map[string][]string{
+		"switch":   {"router"},
+		"server":   {"switch"},
+		"server-2": {"switch", "backup-switch"},
+	})
+	require.NoError(t, err)
+	tracker := dependency.NewTracker(g)
+
+	assert.Equal(t, dependency.Unknown, tracker.Status("server"))
+
+	tracker.ObserveResult(&proto.WorkerProbe{NodeId: "router"}, helper.NewProbeResult(false, nil))
+	for node, success := range map[string]bool{"switch": false, "server": false, "server-2": false, "backup-switch": true} {
+		tracker.Observe(node, success)
+	}
+	assert.Equal(t, dependency.Down, tracker.Status("router"))
+	assert.Equal(t, dependency.UnreachableParent, tracker.Status("switch"))
+	assert.Equal(t, dependency.UnreachableParent, tracker.Status("server"), "unreachable parents suppress transitively")
+	assert.Equal(t, dependency.Down, tracker.Status("server-2"), "server-2 is reachable through its backup switch")
+
+	tracker.Observe("router", true)
+	assert.Equal(t, dependency.Down, tracker.Status("switch"))
+	assert.Equal(t, dependency.UnreachableParent, tracker.Status("server"))
+
+	tracker.Forget("switch")
+	assert.Equal(t, dependency.Down, tracker.Status("server"), "parents without results do not suppress")
+
+	assert.Equal(t, 4*4, testutil.CollectAndCount(tracker, "dependency_node_status"))
+}
+
+func TestTrackerSuppress(t *testing.T) {
+	g, err := dependency.NewGraph(map[string][]string{"switch": {"router"}})
+	require.NoError(t, err)
+	tracker := dependency.NewTracker(g)
+	tracker.Observe("router", false)
+	tracker.Observe("switch", false)
+
+	var events []rules.Event
+	handler := tracker.Suppress(func(ev rules.Event) { events = append(events, ev) })
+	handler(rules.Event{Target: "switch", To: rules.Crit})
+	handler(rules.Event{Target: "router", To: rules.Crit})
+	require.Len(t, events, 1)
+	assert.Equal(t, "router", events[0].Target)
+}
+
+func TestTrackerSuppressRelease(t *testing.T) {
+	g, err := dependency.NewGraph(map[string][]string{
+		"switch": {"router"},
+		"server": {"router"},
+	})
+	require.NoError(t, err)
+	tracker := dependency.NewTracker(g)
+	tracker.Observe("router", false)
+	tracker.Observe("switch", false)
+	tracker.Observe("server", false)
+
+	var events []rules.Event
+	handler := tracker.Suppress(func(ev rules.Event) { events = append(events, ev) })
+	handler(rules.Event{Rule: "loss", Target: "switch", From: rules.OK, To: rules.Warn, Value: 10})
+	handler(rules.Event{Rule: "loss", Target: "switch", From: rules.Warn, To: rules.Crit, Value: 30})
+	handler(rules.Event{Rule: "latency", Target: "switch", From: rules.OK, To: rules.Crit})
+	handler(rules.Event{Rule: "latency", Target: "switch", From: rules.Crit, To: rules.OK})
+	handler(rules.Event{Rule: "loss", Target: "server", From: rules.OK, To: rules.Warn, Flapping: true})
+	require.Empty(t, events)
+
+	// The switch is down on its own once the router is up, its transitions
+	// are collapsed and the latency rule ended where it started.
+	tracker.Observe("router", true)
+	require.Len(t, events, 2)
+	assert.Equal(t, rules.Event{Rule: "loss", Target: "server", From: rules.OK, To: rules.Warn, Flapping: true}, events[0])
+	assert.Equal(t, rules.Event{Rule: "loss", Target: "switch", From: rules.OK, To: rules.Crit, Value: 30}, events[1])
+
+	// The server stops flapping behind the router at the same level.
+	events = nil
+	tracker.Observe("router", false)
+	handler(rules.Event{Rule: "loss", Target: "server", From: rules.Warn, To: rules.Warn})
+	handler(rules.Event{Rule: "loss", Target: "switch", From: rules.Crit, To: rules.OK})
+	handler(rules.Event{Rule: "loss", Target: "switch", From: rules.OK, To: rules.Crit})
+	tracker.Forget("router")
+	require.Len(t, events, 1)
+	assert.Equal(t, rules.Event{Rule: "loss", Target: "server", From: rules.Warn, To: rules.Warn}, events[0])
+
+	// Forgotten nodes drop their held events.
+	events = nil
+	tracker.Observe("router", false)
+	handler(rules.Event{Rule: "loss", Target: "switch", From: rules.Crit, To: rules.OK})
+	tracker.Forget("switch")
+	tracker.Observe("router", true)
+	assert.Empty(t, events)
+}
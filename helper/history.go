@@ -0,0 +1,211 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Result contains the result of the execution of a probe or an SNMP
+// scrape
+type Result struct {
+	Id          int64         `json:"id"`
+	ModuleName  string        `json:"module"`
+	Target      string        `json:"target"`
+	DebugOutput string        `json:"debug_output"`
+	Success     bool          `json:"success"`
+	Timestamp   time.Time     `json:"timestamp"`
+	Duration    time.Duration `json:"duration"`
+	// FailureReason classifies failed probes, for example as
+	// connect_error, assertion_failure or timeout. It is empty for
+	// successful probes and probes that cannot be classified.
+	FailureReason string `json:"failure_reason,omitempty"`
+}
+
+// HistoryStore persists the results of a ResultHistory.
+type HistoryStore interface {
+	// Append stores a result.
+	Append(r *Result) error
+	// Load returns the stored results, oldest first.
+	Load() ([]*Result, error)
+}
+
+// HistoryQuery selects results from a ResultHistory. Zero fields match all
+// results.
+type HistoryQuery struct {
+	ModuleName string
+	Target     string
+	// Since and Until limit the start time of the probes, Until is
+	// exclusive.
+	Since time.Time
+	Until time.Time
+	// Success selects successful or failed probes.
+	Success *bool
+	// Offset skips the first matching results, Limit caps the number of
+	// returned results.
+	Offset int
+	Limit  int
+}
+
+func (q *HistoryQuery) matches(r *Result) bool {
+	switch {
+	case q.ModuleName != "" && r.ModuleName != q.ModuleName,
+		q.Target != "" && r.Target != q.Target,
+		!q.Since.IsZero() && r.Timestamp.Before(q.Since),
+		!q.Until.IsZero() && !r.Timestamp.Before(q.Until),
+		q.Success != nil && r.Success != *q.Success:
+		return false
+	}
+	return true
+}
+
+// ResultHistory contains two history slices: `results` contains most recent `maxResults` results.
+// After they expire out of `results`, failures will be saved in `preservedFailedResults`. This
+// ensures that we are always able to see debug information about recent failures.
+type ResultHistory struct {
+	mu                     sync.Mutex
+	nextId                 int64
+	results                []*Result
+	preservedFailedResults []*Result
+	MaxResults             uint
+	// Store persists the results if set.
+	Store HistoryStore
+}
+
+// NewResultHistory returns a history keeping the results of the given
+// store, so that results survive restarts. The results already in the
+// store are loaded as if they had just been added. A nil store keeps the
+// results in memory only.
+func NewResultHistory(maxResults uint, store HistoryStore) (*ResultHistory, error) {
+	rh := &ResultHistory{MaxResults: maxResults, Store: store}
+	if store == nil {
+		return rh, nil
+	}
+	results, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		rh.add(r)
+		if r.Id >= rh.nextId {
+			rh.nextId = r.Id + 1
+		}
+	}
+	return rh, nil
+}
+
+// Add a result to the history.
+func (rh *ResultHistory) Add(moduleName, target, debugOutput string, success bool) {
+	rh.Record(&Result{
+		ModuleName:  moduleName,
+		Target:      target,
+		DebugOutput: debugOutput,
+		Success:     success,
+		Timestamp:   time.Now(),
+	})
+}
+
+// Record adds a result to the history, assigning its ID. The result is
+// kept in memory even if it cannot be persisted in the store, the returned
+// error reports the failure of the store.
+func (rh *ResultHistory) Record(r *Result) error {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	r.Id = rh.nextId
+	rh.nextId++
+	rh.add(r)
+	if rh.Store != nil {
+		return rh.Store.Append(r)
+	}
+	return nil
+}
+
+func (rh *ResultHistory) add(r *Result) {
+	rh.results = append(rh.results, r)
+	if uint(len(rh.results)) > rh.MaxResults {
+		// If we are about to remove a failure, add it to the failed result history, then
+		// remove the oldest failed result, if needed.
+		if !rh.results[0].Success {
+			rh.preservedFailedResults = append(rh.preservedFailedResults, rh.results[0])
+			if uint(len(rh.preservedFailedResults)) > rh.MaxResults {
+				preservedFailedResults := make([]*Result, len(rh.preservedFailedResults)-1)
+				copy(preservedFailedResults, rh.preservedFailedResults[1:])
+				rh.preservedFailedResults = preservedFailedResults
+			}
+		}
+		results := make([]*Result, len(rh.results)-1)
+		copy(results, rh.results[1:])
+		rh.results = results
+	}
+}
+
+// List returns a list of all results.
+func (rh *ResultHistory) List() []*Result {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	// Results in each slice are disjoint. We can simply concatenate the results.
+	return append(rh.preservedFailedResults[:], rh.results...)
+}
+
+// Get returns a given result.
+func (rh *ResultHistory) Get(id int64) *Result {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	for _, r := range rh.preservedFailedResults {
+		if r.Id == id {
+			return r
+		}
+	}
+	for _, r := range rh.results {
+		if r.Id == id {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// Query returns the results matching q ordered by ID, and the number of all
+// matching results for pagination.
+func (rh *ResultHistory) Query(q HistoryQuery) ([]*Result, int) {
+	rh.mu.Lock()
+	defer rh.mu.Unlock()
+
+	var matched []*Result
+	for _, results := range [][]*Result{rh.preservedFailedResults, rh.results} {
+		for _, r := range results {
+			if q.matches(r) {
+				matched = append(matched, r)
+			}
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Id < matched[j].Id })
+
+	total := len(matched)
+	if q.Offset > 0 {
+		if q.Offset >= len(matched) {
+			return nil, total
+		}
+		matched = matched[q.Offset:]
+	}
+	if q.Limit > 0 && q.Limit < len(matched) {
+		matched = matched[:q.Limit]
+	}
+	return matched, total
+}
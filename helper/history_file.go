@@ -0,0 +1,191 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// FileStore is a HistoryStore appending results as JSON lines to a file.
+// When the file would grow beyond MaxBytes it is rotated: path becomes
+// path.1, path.1 becomes path.2 and so on, and the oldest of MaxBackups
+// rotated files is removed.
+type FileStore struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenFileStore opens the store at path, creating the file if needed. A
+// maxBytes of zero disables rotation.
+func OpenFileStore(path string, maxBytes int64, maxBackups int) (*FileStore, error) {
+	if maxBytes < 0 || maxBackups < 0 {
+		return nil, errors.New("history file size and backups cannot be negative")
+	}
+	s := &FileStore{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) open() error {
+	f, err := os.OpenFile(s.path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("error opening history file: %w", err)
+	}
+	size, err := terminateLastLine(f)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("error opening history file: %w", err)
+	}
+	s.f, s.size = f, size
+	return nil
+}
+
+// terminateLastLine ends a line cut short by a crash, so that it does not
+// swallow the next result, and returns the size of the file.
+func terminateLastLine(f *os.File) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := fi.Size()
+	if size == 0 {
+		return 0, nil
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, size-1); err != nil {
+		return 0, err
+	}
+	if last[0] != '\n' {
+		if _, err := f.Write([]byte{'\n'}); err != nil {
+			return 0, err
+		}
+		size++
+	}
+	return size, nil
+}
+
+func (s *FileStore) backup(n int) string {
+	return fmt.Sprintf("%s.%d", s.path, n)
+}
+
+// rotate moves the current file to the first backup, shifting older
+// backups, and starts a new file.
+func (s *FileStore) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+	if s.maxBackups == 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return s.open()
+	}
+	if err := os.Remove(s.backup(s.maxBackups)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for n := s.maxBackups - 1; n >= 1; n-- {
+		if err := os.Rename(s.backup(n), s.backup(n+1)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if err := os.Rename(s.path, s.backup(1)); err != nil {
+		return err
+	}
+	return s.open()
+}
+
+// Append writes a result to the file, rotating it first if needed.
+func (s *FileStore) Append(r *Result) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errors.New("history file is closed")
+	}
+	if s.maxBytes > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxBytes {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("error rotating history file: %w", err)
+		}
+	}
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// Load reads the results of all files, oldest first. Lines that cannot be
+// decoded, like a line cut short by a crash, are skipped.
+func (s *FileStore) Load() ([]*Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var results []*Result
+	for n := s.maxBackups; n >= 0; n-- {
+		path := s.path
+		if n > 0 {
+			path = s.backup(n)
+		}
+		f, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading history file: %w", err)
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 64<<20)
+		for scanner.Scan() {
+			r := &Result{}
+			if err := json.Unmarshal(scanner.Bytes(), r); err != nil {
+				continue
+			}
+			results = append(results, r)
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading history file %s: %w", path, err)
+		}
+	}
+	return results, nil
+}
+
+// Close closes the file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	return err
+}
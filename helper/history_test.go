@@ -0,0 +1,212 @@
+// Copyright 2017 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryKeepsLatestResults(t *testing.T) {
+	history := &ResultHistory{MaxResults: 3}
+	for i := 0; i < 4; i++ {
+		history.Add("module", "target", fmt.Sprintf("result %d", i), true)
+	}
+
+	savedResults := history.List()
+	for i := 0; i < len(savedResults); i++ {
+		if savedResults[i].DebugOutput != fmt.Sprintf("result %d", i+1) {
+			t.Errorf("History contained the wrong result at index %d", i)
+		}
+	}
+}
+
+func FillHistoryWithMaxSuccesses(h *ResultHistory) {
+	for i := uint(0); i < h.MaxResults; i++ {
+		h.Add("module", "target", fmt.Sprintf("result %d", h.nextId), true)
+	}
+}
+
+func FillHistoryWithMaxPreservedFailures(h *ResultHistory) {
+	for i := uint(0); i < h.MaxResults; i++ {
+		h.Add("module", "target", fmt.Sprintf("result %d", h.nextId), false)
+	}
+}
+
+func TestHistoryPreservesExpiredFailedResults(t *testing.T) {
+	history := &ResultHistory{MaxResults: 3}
+
+	// Success are expired, no failures are expired
+	FillHistoryWithMaxSuccesses(history)
+	FillHistoryWithMaxPreservedFailures(history)
+	savedResults := history.List()
+	for i := uint(0); i < uint(len(savedResults)); i++ {
+		expectedDebugOutput := fmt.Sprintf("result %d", i+history.MaxResults)
+		if savedResults[i].DebugOutput != expectedDebugOutput {
+			t.Errorf("History contained the wrong result at index %d. Expected: %s, Actual: %s", i, expectedDebugOutput, savedResults[i].DebugOutput)
+		}
+	}
+
+	// Failures are expired, should all be preserved
+	FillHistoryWithMaxPreservedFailures(history)
+	savedResults = history.List()
+	for i := uint(0); i < uint(len(savedResults)); i++ {
+		expectedDebugOutput := fmt.Sprintf("result %d", i+history.MaxResults)
+		if savedResults[i].DebugOutput != expectedDebugOutput {
+			t.Errorf("History contained the wrong result at index %d. Expected: %s, Actual: %s", i, expectedDebugOutput, savedResults[i].DebugOutput)
+		}
+	}
+
+	// New expired failures are preserved, new success are not expired
+	FillHistoryWithMaxPreservedFailures(history)
+	FillHistoryWithMaxSuccesses(history)
+	savedResults = history.List()
+	for i := uint(0); i < uint(len(savedResults)); i++ {
+		expectedDebugOutput := fmt.Sprintf("result %d", i+history.MaxResults*3)
+		if savedResults[i].DebugOutput != expectedDebugOutput {
+			t.Errorf("History contained the wrong result at index %d. Expected: %s, Actual: %s", i, expectedDebugOutput, savedResults[i].DebugOutput)
+		}
+	}
+}
+
+func TestHistoryQuery(t *testing.T) {
+	history := &ResultHistory{MaxResults: 10}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		history.Record(&Result{
+			ModuleName: fmt.Sprintf("module%d", i%2),
+			Target:     "target",
+			Success:    i%3 != 0,
+			Timestamp:  start.Add(time.Duration(i) * time.Minute),
+		})
+	}
+
+	failed := false
+	for _, tc := range []struct {
+		query HistoryQuery
+		ids   []int64
+		total int
+	}{
+		{HistoryQuery{}, []int64{0, 1, 2, 3, 4, 5}, 6},
+		{HistoryQuery{ModuleName: "module1"}, []int64{1, 3, 5}, 3},
+		{HistoryQuery{Target: "other"}, nil, 0},
+		{HistoryQuery{Success: &failed}, []int64{0, 3}, 2},
+		{HistoryQuery{Since: start.Add(2 * time.Minute), Until: start.Add(4 * time.Minute)}, []int64{2, 3}, 2},
+		{HistoryQuery{Offset: 2, Limit: 3}, []int64{2, 3, 4}, 6},
+		{HistoryQuery{ModuleName: "module0", Offset: 5}, nil, 3},
+	} {
+		results, total := history.Query(tc.query)
+		if total != tc.total {
+			t.Errorf("Query %+v: expected %d matches, got %d", tc.query, tc.total, total)
+		}
+		var ids []int64
+		for _, r := range results {
+			ids = append(ids, r.Id)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tc.ids) {
+			t.Errorf("Query %+v: expected results %v, got %v", tc.query, tc.ids, ids)
+		}
+	}
+}
+
+func TestHistoryFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := OpenFileStore(path, 500, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history, err := NewResultHistory(3, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 8; i++ {
+		err := history.Record(&Result{
+			ModuleName:    "module",
+			Target:        "target",
+			DebugOutput:   fmt.Sprintf("result %d", i),
+			Success:       i != 1,
+			Timestamp:     time.Unix(int64(i), 0).UTC(),
+			Duration:      time.Second,
+			FailureReason: map[bool]string{true: "timeout"}[i == 1],
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(path + ".2"); err != nil {
+		t.Errorf("Expected the history file to be rotated: %s", err)
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Errorf("Expected at most 2 rotated history files")
+	}
+
+	// Simulate a crash in the middle of writing a result.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":8,"module":"mod`)
+	f.Close()
+	store.Close()
+
+	store, err = OpenFileStore(path, 500, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	restored, err := NewResultHistory(3, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(resultIDs(restored.List())) != fmt.Sprint(resultIDs(history.List())) {
+		t.Errorf("Expected results %v after restart, got %v", resultIDs(history.List()), resultIDs(restored.List()))
+	}
+	r := restored.Get(1)
+	if r == nil || r.Success || r.FailureReason != "timeout" || r.Duration != time.Second || !r.Timestamp.Equal(time.Unix(1, 0)) {
+		t.Errorf("Failed result was not restored: %+v", r)
+	}
+
+	// IDs continue after the restored results, and new results follow the
+	// line cut short by the crash.
+	restored.Add("module", "target", "after restart", true)
+	results, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := results[len(results)-1]; last.Id != 8 || last.DebugOutput != "after restart" {
+		t.Errorf("Unexpected result after restart: %+v", last)
+	}
+}
+
+func TestHistoryWithoutStore(t *testing.T) {
+	history, err := NewResultHistory(2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history.Add("module", "target", "in memory", true)
+	if results := history.List(); len(results) != 1 || results[0].DebugOutput != "in memory" {
+		t.Errorf("Unexpected results %+v", results)
+	}
+}
+
+func resultIDs(results []*Result) []int64 {
+	ids := make([]int64, 0, len(results))
+	for _, r := range results {
+		ids = append(ids, r.Id)
+	}
+	return ids
+}
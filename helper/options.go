@@ -0,0 +1,150 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promlog"
+)
+
+// Options configure the exporters created by blackbox.New and snmp.New.
+type Options struct {
+	// Registerer receives the metrics about the exporter itself.
+	Registerer prometheus.Registerer
+	// Namespace prefixes the names of those metrics.
+	Namespace string
+	Logger    log.Logger
+	// SNMP sets how the SNMP exporter scrapes targets. It is set by the
+	// options of the snmp package and rejected by the blackbox exporter.
+	SNMP *SNMPOptions
+}
+
+// SNMPOptions set how the SNMP exporter scrapes its targets.
+type SNMPOptions struct {
+	// SourceAddress to send SNMP from in the format 'address:port'. If the
+	// port is empty or '0', as in '127.0.0.1:' or '[::1]:0', a random
+	// source port is chosen.
+	SourceAddress string
+	// WrapCounters wraps 64-bit counters to avoid floating point rounding.
+	WrapCounters bool
+	// Concurrency is the number of modules scraped in parallel.
+	Concurrency int
+}
+
+// Option changes Options.
+type Option func(*Options)
+
+// WithRegisterer registers the metrics about the exporter with reg instead
+// of prometheus.DefaultRegisterer.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	return func(o *Options) {
+		o.Registerer = reg
+	}
+}
+
+// WithNamespace prefixes the names of the metrics about the exporter, so
+// that blackbox_module_unknown_total becomes <namespace>_blackbox_module_unknown_total.
+func WithNamespace(namespace string) Option {
+	return func(o *Options) {
+		o.Namespace = namespace
+	}
+}
+
+// WithLogger logs to logger. The log level passed to New is not applied to
+// it.
+func WithLogger(logger log.Logger) Option {
+	return func(o *Options) {
+		o.Logger = logger
+	}
+}
+
+// WithSlogLogger logs to a log/slog logger. The log level passed to New is
+// not applied to it.
+func WithSlogLogger(logger *slog.Logger) Option {
+	return WithLogger(NewSlogAdapter(logger))
+}
+
+// NewOptions applies opts to the defaults: the default registerer, no
+// namespace and a logfmt logger to stderr at logLevel.
+func NewOptions(logLevel string, opts ...Option) (*Options, error) {
+	o := &Options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Registerer == nil {
+		o.Registerer = prometheus.DefaultRegisterer
+	}
+	if o.Namespace != "" {
+		o.Registerer = prometheus.WrapRegistererWithPrefix(o.Namespace+"_", o.Registerer)
+	}
+	if o.Logger == nil {
+		v := &promlog.AllowedLevel{}
+		if err := v.Set(logLevel); err != nil {
+			return nil, fmt.Errorf("error setting log level: %w", err)
+		}
+		o.Logger = promlog.New(&promlog.Config{Level: v})
+	}
+	return o, nil
+}
+
+// Register registers c with reg. If an equal collector is already
+// registered, for example by another exporter sharing reg, that collector
+// is returned instead so that both exporters update the same metrics.
+func Register[T prometheus.Collector](reg prometheus.Registerer, c T) (T, error) {
+	err := reg.Register(c)
+	if err == nil {
+		return c, nil
+	}
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	return c, err
+}
+
+// ReloadMetrics report the outcome of configuration reloads.
+type ReloadMetrics struct {
+	Success   prometheus.Gauge
+	Timestamp prometheus.Gauge
+}
+
+// NewReloadMetrics registers the <namespace>_config_last_reload_* metrics
+// with reg. exporter names the exporter in their help.
+func NewReloadMetrics(reg prometheus.Registerer, namespace, exporter string) (*ReloadMetrics, error) {
+	success, err := Register(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_last_reload_successful",
+		Help:      exporter + " config loaded successfully.",
+	}))
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := Register(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "config_last_reload_success_timestamp_seconds",
+		Help:      "Timestamp of the last successful configuration reload.",
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return &ReloadMetrics{Success: success, Timestamp: timestamp}, nil
+}
+
+// Observe records the outcome of a reload. It does nothing on a nil
+// ReloadMetrics.
+func (m *ReloadMetrics) Observe(err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.Success.Set(0)
+		return
+	}
+	m.Success.Set(1)
+	m.Timestamp.SetToCurrentTime()
+}
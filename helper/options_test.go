@@ -0,0 +1,100 @@
+package helper
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewOptions(t *testing.T) {
+	if _, err := NewOptions("verbose"); err == nil {
+		t.Errorf("Expected an error for an invalid log level")
+	}
+	// An injected logger makes the log level irrelevant.
+	o, err := NewOptions("", WithLogger(log.NewNopLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.Registerer != prometheus.DefaultRegisterer {
+		t.Errorf("Expected the default registerer")
+	}
+
+	reg := prometheus.NewRegistry()
+	o, err = NewOptions("info", WithRegisterer(reg), WithNamespace("acme"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewReloadMetrics(o.Registerer, "snmp_exporter", "Snmp exporter"); err != nil {
+		t.Fatal(err)
+	}
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mf := range mfs {
+		if !strings.HasPrefix(mf.GetName(), "acme_snmp_exporter_") {
+			t.Errorf("Metric %s is not prefixed with the namespace", mf.GetName())
+		}
+	}
+}
+
+func TestRegisterShared(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	first, err := NewReloadMetrics(reg, "test", "Test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := NewReloadMetrics(reg, "test", "Test")
+	if err != nil {
+		t.Fatalf("Registering the metrics twice failed: %s", err)
+	}
+	second.Observe(nil)
+	if v := testutil.ToFloat64(first.Success); v != 1 {
+		t.Errorf("Expected instances to share metrics, got success %v", v)
+	}
+	second.Observe(errors.New("bad config"))
+	if v := testutil.ToFloat64(first.Success); v != 0 {
+		t.Errorf("Expected success 0 after a failed reload, got %v", v)
+	}
+
+	// Different collectors with the same name still conflict.
+	if _, err := Register(reg, prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "test_config_last_reload_successful",
+		Help: "Other help.",
+	})); err == nil {
+		t.Errorf("Expected an error registering a conflicting collector")
+	}
+
+	// Observing on nil metrics does nothing.
+	var m *ReloadMetrics
+	m.Observe(nil)
+}
+
+func TestSlogAdapter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogAdapter(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+
+	level.Debug(logger).Log("msg", "hidden")
+	level.Warn(log.With(logger, "module", "http_2xx")).Log("msg", "Probe failed", "target", "example.com")
+	logger.Log("msg", "no level", "odd")
+
+	got := buf.String()
+	if strings.Contains(got, "hidden") {
+		t.Errorf("Expected debug records to be filtered, got %q", got)
+	}
+	for _, want := range []string{
+		`level=WARN msg="Probe failed" module=http_2xx target=example.com`,
+		`level=INFO msg="no level" odd=(MISSING)`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in %q", want, got)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+)
+
+type slogAdapter struct {
+	logger *slog.Logger
+}
+
+// NewSlogAdapter returns a go-kit logger writing to a log/slog logger. The
+// go-kit level and "msg" keys become the level and message of the record,
+// the other key/value pairs its attributes.
+func NewSlogAdapter(logger *slog.Logger) log.Logger {
+	return slogAdapter{logger: logger}
+}
+
+func (a slogAdapter) Log(keyvals ...interface{}) error {
+	lvl := slog.LevelInfo
+	var msg string
+	attrs := make([]any, 0, len(keyvals))
+	for i := 0; i < len(keyvals); i += 2 {
+		var v interface{} = log.ErrMissingValue
+		if i+1 < len(keyvals) {
+			v = keyvals[i+1]
+		}
+		switch k := keyvals[i]; {
+		case k == level.Key():
+			lvl = slogLevel(v)
+		case k == "msg":
+			msg = fmt.Sprint(v)
+		default:
+			attrs = append(attrs, fmt.Sprint(k), v)
+		}
+	}
+	a.logger.Log(context.Background(), lvl, msg, attrs...)
+	return nil
+}
+
+func slogLevel(v interface{}) slog.Level {
+	switch v {
+	case level.DebugValue():
+		return slog.LevelDebug
+	case level.WarnValue():
+		return slog.LevelWarn
+	case level.ErrorValue():
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
@@ -199,16 +199,17 @@ type NodeConfig struct {
 	sizeCache     protoimpl.SizeCache
 	unknownFields protoimpl.UnknownFields
 
-	Version       int32  `protobuf:"varint,1,opt,name=Version,proto3" json:"version,omitempty"`             
-	Community     string `protobuf:"bytes,2,opt,name=Community,proto3" json:"community,omitempty"`          
-	SecurityLevel string `protobuf:"bytes,3,opt,name=SecurityLevel,proto3" json:"securityLevel,omitempty"`  
-	Username      string `protobuf:"bytes,4,opt,name=Username,proto3" json:"username,omitempty"`            
-	Password      string `protobuf:"bytes,5,opt,name=Password,proto3" json:"password,omitempty"`            
-	AuthProtocol  string `protobuf:"bytes,6,opt,name=AuthProtocol,proto3" json:"authProtocol,omitempty"`    
-	PrivProtocol  string `protobuf:"bytes,7,opt,name=PrivProtocol,proto3" json:"privProtocol,omitempty"`    
-	PrivPassword  string `protobuf:"bytes,8,opt,name=PrivPassword,proto3" json:"privPassword,omitempty"`    
-	ContextName   string `protobuf:"bytes,9,opt,name=ContextName,proto3" json:"contextName,omitempty"`      
-	Timeout       int32  `protobuf:"varint,10,opt,name=Timeout,proto3" json:"timeout,omitempty"`            
+	Version       int32  `protobuf:"varint,1,opt,name=Version,proto3" json:"version,omitempty"`              
+	Community     string `protobuf:"bytes,2,opt,name=Community,proto3" json:"community,omitempty"`           
+	SecurityLevel string `protobuf:"bytes,3,opt,name=SecurityLevel,proto3" json:"securityLevel,omitempty"`   
+	Username      string `protobuf:"bytes,4,opt,name=Username,proto3" json:"username,omitempty"`             
+	Password      string `protobuf:"bytes,5,opt,name=Password,proto3" json:"password,omitempty"`             
+	AuthProtocol  string `protobuf:"bytes,6,opt,name=AuthProtocol,proto3" json:"authProtocol,omitempty"`     
+	PrivProtocol  string `protobuf:"bytes,7,opt,name=PrivProtocol,proto3" json:"privProtocol,omitempty"`     
+	PrivPassword  string `protobuf:"bytes,8,opt,name=PrivPassword,proto3" json:"privPassword,omitempty"`     
+	ContextName   string `protobuf:"bytes,9,opt,name=ContextName,proto3" json:"contextName,omitempty"`       
+	Timeout       int32  `protobuf:"varint,10,opt,name=Timeout,proto3" json:"timeout,omitempty"`             
+	SourceAddress string `protobuf:"bytes,11,opt,name=SourceAddress,proto3" json:"sourceAddress,omitempty"`  
 }
 
 func (x *NodeConfig) Reset() {
@@ -313,6 +314,13 @@ func (x *NodeConfig) GetTimeout() int32 {
 	return 0
 }
 
+func (x *NodeConfig) GetSourceAddress() string {
+	if x != nil {
+		return x.SourceAddress
+	}
+	return ""
+}
+
 type Authorization struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -510,6 +518,322 @@ func (x *ICMPQOSConfig) GetTimeout() int32 {
 	return 0
 }
 
+type DNSConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ClientSubnet     string `protobuf:"bytes,1,opt,name=ClientSubnet,proto3" json:"clientSubnet,omitempty"`           
+	UDPSize          int32  `protobuf:"varint,2,opt,name=UDPSize,proto3" json:"udpSize,omitempty"`                    
+	Cookie           *bool  `protobuf:"varint,3,opt,name=Cookie,proto3,oneof" json:"cookie,omitempty"`                
+	NSID             *bool  `protobuf:"varint,4,opt,name=NSID,proto3,oneof" json:"nsid,omitempty"`                    
+	PaddingBlockSize int32  `protobuf:"varint,5,opt,name=PaddingBlockSize,proto3" json:"paddingBlockSize,omitempty"`  
+	QueryName        string `protobuf:"bytes,6,opt,name=QueryName,proto3" json:"queryName,omitempty"`                 
+	QueryType        string `protobuf:"bytes,7,opt,name=QueryType,proto3" json:"queryType,omitempty"`                 
+}
+
+func (x *DNSConfig) Reset() {
+	*x = DNSConfig{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_messages_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *DNSConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DNSConfig) ProtoMessage() {}
+
+func (x *DNSConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_messages_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DNSConfig.ProtoReflect.Descriptor instead.
+func (*DNSConfig) Descriptor() ([]byte, []int) {
+	return file_messages_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DNSConfig) GetClientSubnet() string {
+	if x != nil {
+		return x.ClientSubnet
+	}
+	return ""
+}
+
+func (x *DNSConfig) GetUDPSize() int32 {
+	if x != nil {
+		return x.UDPSize
+	}
+	return 0
+}
+
+func (x *DNSConfig) GetCookie() bool {
+	if x != nil && x.Cookie != nil {
+		return *x.Cookie
+	}
+	return false
+}
+
+func (x *DNSConfig) GetNSID() bool {
+	if x != nil && x.NSID != nil {
+		return *x.NSID
+	}
+	return false
+}
+
+func (x *DNSConfig) GetPaddingBlockSize() int32 {
+	if x != nil {
+		return x.PaddingBlockSize
+	}
+	return 0
+}
+
+func (x *DNSConfig) GetQueryName() string {
+	if x != nil {
+		return x.QueryName
+	}
+	return ""
+}
+
+func (x *DNSConfig) GetQueryType() string {
+	if x != nil {
+		return x.QueryType
+	}
+	return ""
+}
+
+type TCPQueryResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Expect   string `protobuf:"bytes,1,opt,name=Expect,proto3" json:"expect,omitempty"`       
+	Send     string `protobuf:"bytes,2,opt,name=Send,proto3" json:"send,omitempty"`           
+	StartTLS bool   `protobuf:"varint,3,opt,name=StartTLS,proto3" json:"startTls,omitempty"`  
+}
+
+func (x *TCPQueryResponse) Reset() {
+	*x = TCPQueryResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_messages_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TCPQueryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TCPQueryResponse) ProtoMessage() {}
+
+func (x *TCPQueryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_messages_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TCPQueryResponse.ProtoReflect.Descriptor instead.
+func (*TCPQueryResponse) Descriptor() ([]byte, []int) {
+	return file_messages_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *TCPQueryResponse) GetExpect() string {
+	if x != nil {
+		return x.Expect
+	}
+	return ""
+}
+
+func (x *TCPQueryResponse) GetSend() string {
+	if x != nil {
+		return x.Send
+	}
+	return ""
+}
+
+func (x *TCPQueryResponse) GetStartTLS() bool {
+	if x != nil {
+		return x.StartTLS
+	}
+	return false
+}
+
+type TCPConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	IPProtocol      string              `protobuf:"bytes,1,opt,name=IPProtocol,proto3" json:"ipProtocol,omitempty"`            
+	SourceIPAddress string              `protobuf:"bytes,2,opt,name=SourceIPAddress,proto3" json:"sourceIpAddress,omitempty"`  
+	TLS             *bool               `protobuf:"varint,3,opt,name=TLS,proto3,oneof" json:"tls,omitempty"`                   
+	QueryResponse   []*TCPQueryResponse `protobuf:"bytes,4,rep,name=QueryResponse,proto3" json:"queryResponse,omitempty"`      
+}
+
+func (x *TCPConfig) Reset() {
+	*x = TCPConfig{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_messages_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TCPConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TCPConfig) ProtoMessage() {}
+
+func (x *TCPConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_messages_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TCPConfig.ProtoReflect.Descriptor instead.
+func (*TCPConfig) Descriptor() ([]byte, []int) {
+	return file_messages_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TCPConfig) GetIPProtocol() string {
+	if x != nil {
+		return x.IPProtocol
+	}
+	return ""
+}
+
+func (x *TCPConfig) GetSourceIPAddress() string {
+	if x != nil {
+		return x.SourceIPAddress
+	}
+	return ""
+}
+
+func (x *TCPConfig) GetTLS() bool {
+	if x != nil && x.TLS != nil {
+		return *x.TLS
+	}
+	return false
+}
+
+func (x *TCPConfig) GetQueryResponse() []*TCPQueryResponse {
+	if x != nil {
+		return x.QueryResponse
+	}
+	return nil
+}
+
+type GRPCConfig struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Service   string            `protobuf:"bytes,1,opt,name=Service,proto3" json:"service,omitempty"`                                                                                            
+	Method    string            `protobuf:"bytes,2,opt,name=Method,proto3" json:"method,omitempty"`                                                                                              
+	Request   string            `protobuf:"bytes,3,opt,name=Request,proto3" json:"request,omitempty"`                                                                                            
+	Metadata  map[string]string `protobuf:"bytes,4,rep,name=Metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`  
+	Authority string            `protobuf:"bytes,5,opt,name=Authority,proto3" json:"authority,omitempty"`                                                                                        
+	TLS       *bool             `protobuf:"varint,6,opt,name=TLS,proto3,oneof" json:"tls,omitempty"`                                                                                             
+}
+
+func (x *GRPCConfig) Reset() {
+	*x = GRPCConfig{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_messages_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GRPCConfig) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GRPCConfig) ProtoMessage() {}
+
+func (x *GRPCConfig) ProtoReflect() protoreflect.Message {
+	mi := &file_messages_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GRPCConfig.ProtoReflect.Descriptor instead.
+func (*GRPCConfig) Descriptor() ([]byte, []int) {
+	return file_messages_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *GRPCConfig) GetService() string {
+	if x != nil {
+		return x.Service
+	}
+	return ""
+}
+
+func (x *GRPCConfig) GetMethod() string {
+	if x != nil {
+		return x.Method
+	}
+	return ""
+}
+
+func (x *GRPCConfig) GetRequest() string {
+	if x != nil {
+		return x.Request
+	}
+	return ""
+}
+
+func (x *GRPCConfig) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *GRPCConfig) GetAuthority() string {
+	if x != nil {
+		return x.Authority
+	}
+	return ""
+}
+
+func (x *GRPCConfig) GetTLS() bool {
+	if x != nil && x.TLS != nil {
+		return *x.TLS
+	}
+	return false
+}
+
 type WorkerProbe struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -525,10 +849,12 @@ type WorkerProbe struct {
 	 
 	//
 	// Types that are assignable to ProbeConfig:
-	//
 	//	*WorkerProbe_Node
 	//	*WorkerProbe_Website
 	//	*WorkerProbe_ICMPQOS
+	//	*WorkerProbe_DNS
+	//	*WorkerProbe_TCP
+	//	*WorkerProbe_GRPC
 	ProbeConfig isWorkerProbe_ProbeConfig `protobuf_oneof:"ProbeConfig" json:"probe_config,omitempty"`
 	LastUpdated int64                     `protobuf:"varint,11,opt,name=LastUpdated,proto3" json:"lastUpdated,omitempty"`  
 }
@@ -536,7 +862,7 @@ type WorkerProbe struct {
 func (x *WorkerProbe) Reset() {
 	*x = WorkerProbe{}
 	if protoimpl.UnsafeEnabled {
-		mi := &file_messages_proto_msgTypes[7]
+		mi := &file_messages_proto_msgTypes[11]
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		ms.StoreMessageInfo(mi)
 	}
@@ -549,7 +875,7 @@ func (x *WorkerProbe) String() string {
 func (*WorkerProbe) ProtoMessage() {}
 
 func (x *WorkerProbe) ProtoReflect() protoreflect.Message {
-	mi := &file_messages_proto_msgTypes[7]
+	mi := &file_messages_proto_msgTypes[11]
 	if protoimpl.UnsafeEnabled && x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -562,7 +888,7 @@ func (x *WorkerProbe) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use WorkerProbe.ProtoReflect.Descriptor instead.
 func (*WorkerProbe) Descriptor() ([]byte, []int) {
-	return file_messages_proto_rawDescGZIP(), []int{7}
+	return file_messages_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *WorkerProbe) GetProbeId() string {
@@ -642,6 +968,27 @@ func (x *WorkerProbe) GetICMPQOS() *ICMPQOSConfig {
 	return nil
 }
 
+func (x *WorkerProbe) GetDNS() *DNSConfig {
+	if x, ok := x.GetProbeConfig().(*WorkerProbe_DNS); ok {
+		return x.DNS
+	}
+	return nil
+}
+
+func (x *WorkerProbe) GetTCP() *TCPConfig {
+	if x, ok := x.GetProbeConfig().(*WorkerProbe_TCP); ok {
+		return x.TCP
+	}
+	return nil
+}
+
+func (x *WorkerProbe) GetGRPC() *GRPCConfig {
+	if x, ok := x.GetProbeConfig().(*WorkerProbe_GRPC); ok {
+		return x.GRPC
+	}
+	return nil
+}
+
 func (x *WorkerProbe) GetLastUpdated() int64 {
 	if x != nil {
 		return x.LastUpdated
@@ -665,12 +1012,30 @@ type WorkerProbe_ICMPQOS struct {
 	ICMPQOS *ICMPQOSConfig `protobuf:"bytes,10,opt,name=ICMPQOS,proto3,oneof" json:"icmpQos,omitempty"`  
 }
 
+type WorkerProbe_DNS struct {
+	DNS *DNSConfig `protobuf:"bytes,12,opt,name=DNS,proto3,oneof" json:"dns,omitempty"`  
+}
+
+type WorkerProbe_TCP struct {
+	TCP *TCPConfig `protobuf:"bytes,13,opt,name=TCP,proto3,oneof" json:"tcp,omitempty"`  
+}
+
+type WorkerProbe_GRPC struct {
+	GRPC *GRPCConfig `protobuf:"bytes,14,opt,name=GRPC,proto3,oneof" json:"grpc,omitempty"`  
+}
+
 func (*WorkerProbe_Node) isWorkerProbe_ProbeConfig() {}
 
 func (*WorkerProbe_Website) isWorkerProbe_ProbeConfig() {}
 
 func (*WorkerProbe_ICMPQOS) isWorkerProbe_ProbeConfig() {}
 
+func (*WorkerProbe_DNS) isWorkerProbe_ProbeConfig() {}
+
+func (*WorkerProbe_TCP) isWorkerProbe_ProbeConfig() {}
+
+func (*WorkerProbe_GRPC) isWorkerProbe_ProbeConfig() {}
+
 var File_messages_proto protoreflect.FileDescriptor
 
 var file_messages_proto_rawDesc = []byte{
@@ -695,7 +1060,7 @@ var file_messages_proto_rawDesc = []byte{
 	0x6e, 0x66, 0x69, 0x67, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79,
 	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76,
 	0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75,
-	0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xca, 0x02, 0x0a, 0x0a, 0x4e, 0x6f, 0x64, 0x65, 0x43, 0x6f,
+	0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xf0, 0x02, 0x0a, 0x0a, 0x4e, 0x6f, 0x64, 0x65, 0x43, 0x6f,
 	0x6e, 0x66, 0x69, 0x67, 0x12, 0x18, 0x0a, 0x07, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18,
 	0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1c,
 	0x0a, 0x09, 0x43, 0x6f, 0x6d, 0x6d, 0x75, 0x6e, 0x69, 0x74, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28,
@@ -716,67 +1081,127 @@ var file_messages_proto_rawDesc = []byte{
 	0x74, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x43, 0x6f, 0x6e,
 	0x74, 0x65, 0x78, 0x74, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x54, 0x69, 0x6d, 0x65,
 	0x6f, 0x75, 0x74, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x54, 0x69, 0x6d, 0x65, 0x6f,
-	0x75, 0x74, 0x22, 0x47, 0x0a, 0x0d, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x61, 0x74,
-	0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x55, 0x73, 0x65, 0x72, 0x6e, 0x61, 0x6d, 0x65, 0x12,
-	0x1a, 0x0a, 0x08, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28,
-	0x09, 0x52, 0x08, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x22, 0xfa, 0x01, 0x0a, 0x0d,
-	0x57, 0x65, 0x62, 0x73, 0x69, 0x74, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x16, 0x0a,
-	0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x4d,
-	0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x3f, 0x0a, 0x0d, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69,
-	0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x69,
-	0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x2e, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72,
-	0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69,
-	0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x40, 0x0a, 0x07, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72,
-	0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x26, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66,
-	0x61, 0x63, 0x65, 0x73, 0x2e, 0x57, 0x65, 0x62, 0x73, 0x69, 0x74, 0x65, 0x43, 0x6f, 0x6e, 0x66,
-	0x69, 0x67, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52,
-	0x07, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x42, 0x6f, 0x64, 0x79,
-	0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x42, 0x6f, 0x64, 0x79, 0x1a, 0x3a, 0x0a, 0x0c,
-	0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
-	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
-	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
-	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x7b, 0x0a, 0x0d, 0x49, 0x43, 0x4d, 0x50,
-	0x51, 0x4f, 0x53, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x1e, 0x0a, 0x0a, 0x50, 0x61, 0x63,
-	0x6b, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0a, 0x50,
-	0x61, 0x63, 0x6b, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x49, 0x6e, 0x74,
-	0x65, 0x72, 0x76, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x49, 0x6e, 0x74,
-	0x65, 0x72, 0x76, 0x61, 0x6c, 0x12, 0x14, 0x0a, 0x05, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03,
-	0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x18, 0x0a, 0x07, 0x54,
-	0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07, 0x54, 0x69,
-	0x6d, 0x65, 0x6f, 0x75, 0x74, 0x22, 0xba, 0x03, 0x0a, 0x0b, 0x57, 0x6f, 0x72, 0x6b, 0x65, 0x72,
-	0x50, 0x72, 0x6f, 0x62, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x50, 0x72, 0x6f, 0x62, 0x65, 0x49, 0x64,
-	0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x50, 0x72, 0x6f, 0x62, 0x65, 0x49, 0x64, 0x12,
-	0x1e, 0x0a, 0x0a, 0x43, 0x6f, 0x73, 0x74, 0x75, 0x6d, 0x65, 0x72, 0x49, 0x64, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x0a, 0x43, 0x6f, 0x73, 0x74, 0x75, 0x6d, 0x65, 0x72, 0x49, 0x64, 0x12,
-	0x32, 0x0a, 0x14, 0x55, 0x73, 0x65, 0x72, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x49, 0x6e, 0x76, 0x65,
-	0x6e, 0x74, 0x6f, 0x72, 0x79, 0x49, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52, 0x14, 0x55,
-	0x73, 0x65, 0x72, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x49, 0x6e, 0x76, 0x65, 0x6e, 0x74, 0x6f, 0x72,
-	0x79, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x4e, 0x6f, 0x64, 0x65, 0x49, 0x64, 0x18, 0x04, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x06, 0x4e, 0x6f, 0x64, 0x65, 0x49, 0x64, 0x12, 0x0e, 0x0a, 0x02, 0x49,
-	0x70, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x49, 0x70, 0x12, 0x1a, 0x0a, 0x08, 0x49,
-	0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x08, 0x49,
-	0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x12, 0x2c, 0x0a, 0x07, 0x4d, 0x6f, 0x64, 0x75, 0x6c,
-	0x65, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72,
-	0x66, 0x61, 0x63, 0x65, 0x73, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x52, 0x07, 0x4d, 0x6f,
-	0x64, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x2c, 0x0a, 0x04, 0x4e, 0x6f, 0x64, 0x65, 0x18, 0x08, 0x20,
-	0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73,
-	0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x48, 0x00, 0x52, 0x04, 0x4e,
-	0x6f, 0x64, 0x65, 0x12, 0x35, 0x0a, 0x07, 0x57, 0x65, 0x62, 0x73, 0x69, 0x74, 0x65, 0x18, 0x09,
-	0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65,
-	0x73, 0x2e, 0x57, 0x65, 0x62, 0x73, 0x69, 0x74, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x48,
-	0x00, 0x52, 0x07, 0x57, 0x65, 0x62, 0x73, 0x69, 0x74, 0x65, 0x12, 0x35, 0x0a, 0x07, 0x49, 0x43,
-	0x4d, 0x50, 0x51, 0x4f, 0x53, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x69, 0x6e,
-	0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x2e, 0x49, 0x43, 0x4d, 0x50, 0x51, 0x4f, 0x53,
-	0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x48, 0x00, 0x52, 0x07, 0x49, 0x43, 0x4d, 0x50, 0x51, 0x4f,
-	0x53, 0x12, 0x20, 0x0a, 0x0b, 0x4c, 0x61, 0x73, 0x74, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64,
-	0x18, 0x0b, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x4c, 0x61, 0x73, 0x74, 0x55, 0x70, 0x64, 0x61,
-	0x74, 0x65, 0x64, 0x42, 0x0d, 0x0a, 0x0b, 0x50, 0x72, 0x6f, 0x62, 0x65, 0x43, 0x6f, 0x6e, 0x66,
-	0x69, 0x67, 0x42, 0x55, 0x5a, 0x53, 0x67, 0x69, 0x74, 0x6c, 0x61, 0x62, 0x2e, 0x70, 0x6c, 0x61,
-	0x79, 0x63, 0x6f, 0x75, 0x72, 0x74, 0x2e, 0x69, 0x64, 0x2f, 0x74, 0x65, 0x6c, 0x6b, 0x6f, 0x6d,
-	0x2d, 0x64, 0x69, 0x67, 0x69, 0x74, 0x61, 0x6c, 0x2f, 0x64, 0x70, 0x65, 0x2f, 0x73, 0x74, 0x64,
-	0x2f, 0x69, 0x6d, 0x70, 0x6c, 0x2f, 0x6e, 0x65, 0x74, 0x6d, 0x6f, 0x6e, 0x6b, 0x2f, 0x50, 0x72,
-	0x6f, 0x74, 0x6f, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x3b, 0x69,
+	0x75, 0x74, 0x12, 0x24, 0x0a, 0x0d, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x41, 0x64, 0x64, 0x72,
+	0x65, 0x73, 0x73, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x53, 0x6f, 0x75, 0x72, 0x63,
+	0x65, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x22, 0x47, 0x0a, 0x0d, 0x41, 0x75, 0x74, 0x68,
+	0x6f, 0x72, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1a, 0x0a, 0x08, 0x55, 0x73, 0x65,
+	0x72, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x55, 0x73, 0x65,
+	0x72, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72,
+	0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x50, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72,
+	0x64, 0x22, 0xfa, 0x01, 0x0a, 0x0d, 0x57, 0x65, 0x62, 0x73, 0x69, 0x74, 0x65, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x12, 0x16, 0x0a, 0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x3f, 0x0a, 0x0d, 0x41,
+	0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x19, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x2e,
+	0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0d, 0x41,
+	0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x7a, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x40, 0x0a, 0x07,
+	0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x26, 0x2e,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x2e, 0x57, 0x65, 0x62, 0x73, 0x69,
+	0x74, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x07, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x12, 0x12,
+	0x0a, 0x04, 0x42, 0x6f, 0x64, 0x79, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x42, 0x6f,
+	0x64, 0x79, 0x1a, 0x3a, 0x0a, 0x0c, 0x48, 0x65, 0x61, 0x64, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74,
+	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0x7b,
+	0x0a, 0x0d, 0x49, 0x43, 0x4d, 0x50, 0x51, 0x4f, 0x53, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12,
+	0x1e, 0x0a, 0x0a, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x0a, 0x50, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x53, 0x69, 0x7a, 0x65, 0x12,
+	0x1a, 0x0a, 0x08, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x08, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x12, 0x14, 0x0a, 0x05, 0x43,
+	0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x43, 0x6f, 0x75, 0x6e,
+	0x74, 0x12, 0x18, 0x0a, 0x07, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x07, 0x54, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x22, 0xfb, 0x01, 0x0a, 0x09,
+	0x44, 0x4e, 0x53, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12, 0x22, 0x0a, 0x0c, 0x43, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x53, 0x75, 0x62, 0x6e, 0x65, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0c, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x53, 0x75, 0x62, 0x6e, 0x65, 0x74, 0x12, 0x18, 0x0a,
+	0x07, 0x55, 0x44, 0x50, 0x53, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x07,
+	0x55, 0x44, 0x50, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x1b, 0x0a, 0x06, 0x43, 0x6f, 0x6f, 0x6b, 0x69,
+	0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x06, 0x43, 0x6f, 0x6f, 0x6b, 0x69,
+	0x65, 0x88, 0x01, 0x01, 0x12, 0x17, 0x0a, 0x04, 0x4e, 0x53, 0x49, 0x44, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x08, 0x48, 0x01, 0x52, 0x04, 0x4e, 0x53, 0x49, 0x44, 0x88, 0x01, 0x01, 0x12, 0x2a, 0x0a,
+	0x10, 0x50, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67, 0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x53, 0x69, 0x7a,
+	0x65, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x10, 0x50, 0x61, 0x64, 0x64, 0x69, 0x6e, 0x67,
+	0x42, 0x6c, 0x6f, 0x63, 0x6b, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x51, 0x75, 0x65,
+	0x72, 0x79, 0x4e, 0x61, 0x6d, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x51, 0x75,
+	0x65, 0x72, 0x79, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x1c, 0x0a, 0x09, 0x51, 0x75, 0x65, 0x72, 0x79,
+	0x54, 0x79, 0x70, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x51, 0x75, 0x65, 0x72,
+	0x79, 0x54, 0x79, 0x70, 0x65, 0x42, 0x09, 0x0a, 0x07, 0x5f, 0x43, 0x6f, 0x6f, 0x6b, 0x69, 0x65,
+	0x42, 0x07, 0x0a, 0x05, 0x5f, 0x4e, 0x53, 0x49, 0x44, 0x22, 0x5a, 0x0a, 0x10, 0x54, 0x43, 0x50,
+	0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x16, 0x0a,
+	0x06, 0x45, 0x78, 0x70, 0x65, 0x63, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x45,
+	0x78, 0x70, 0x65, 0x63, 0x74, 0x12, 0x12, 0x0a, 0x04, 0x53, 0x65, 0x6e, 0x64, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x04, 0x53, 0x65, 0x6e, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x53, 0x74, 0x61,
+	0x72, 0x74, 0x54, 0x4c, 0x53, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x08, 0x53, 0x74, 0x61,
+	0x72, 0x74, 0x54, 0x4c, 0x53, 0x22, 0xb8, 0x01, 0x0a, 0x09, 0x54, 0x43, 0x50, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x67, 0x12, 0x1e, 0x0a, 0x0a, 0x49, 0x50, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f,
+	0x6c, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x49, 0x50, 0x50, 0x72, 0x6f, 0x74, 0x6f,
+	0x63, 0x6f, 0x6c, 0x12, 0x28, 0x0a, 0x0f, 0x53, 0x6f, 0x75, 0x72, 0x63, 0x65, 0x49, 0x50, 0x41,
+	0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x53, 0x6f,
+	0x75, 0x72, 0x63, 0x65, 0x49, 0x50, 0x41, 0x64, 0x64, 0x72, 0x65, 0x73, 0x73, 0x12, 0x15, 0x0a,
+	0x03, 0x54, 0x4c, 0x53, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x03, 0x54, 0x4c,
+	0x53, 0x88, 0x01, 0x01, 0x12, 0x42, 0x0a, 0x0d, 0x51, 0x75, 0x65, 0x72, 0x79, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x2e, 0x54, 0x43, 0x50, 0x51, 0x75, 0x65, 0x72,
+	0x79, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x0d, 0x51, 0x75, 0x65, 0x72, 0x79,
+	0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x54, 0x4c, 0x53,
+	0x22, 0x94, 0x02, 0x0a, 0x0a, 0x47, 0x52, 0x50, 0x43, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x12,
+	0x18, 0x0a, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x07, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x4d, 0x65, 0x74,
+	0x68, 0x6f, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x4d, 0x65, 0x74, 0x68, 0x6f,
+	0x64, 0x12, 0x18, 0x0a, 0x07, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x40, 0x0a, 0x08, 0x4d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x04, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x24, 0x2e,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x2e, 0x47, 0x52, 0x50, 0x43, 0x43,
+	0x6f, 0x6e, 0x66, 0x69, 0x67, 0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x52, 0x08, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x1c, 0x0a,
+	0x09, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x09, 0x41, 0x75, 0x74, 0x68, 0x6f, 0x72, 0x69, 0x74, 0x79, 0x12, 0x15, 0x0a, 0x03, 0x54,
+	0x4c, 0x53, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x48, 0x00, 0x52, 0x03, 0x54, 0x4c, 0x53, 0x88,
+	0x01, 0x01, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x45, 0x6e,
+	0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42,
+	0x06, 0x0a, 0x04, 0x5f, 0x54, 0x4c, 0x53, 0x22, 0xbe, 0x04, 0x0a, 0x0b, 0x57, 0x6f, 0x72, 0x6b,
+	0x65, 0x72, 0x50, 0x72, 0x6f, 0x62, 0x65, 0x12, 0x18, 0x0a, 0x07, 0x50, 0x72, 0x6f, 0x62, 0x65,
+	0x49, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x50, 0x72, 0x6f, 0x62, 0x65, 0x49,
+	0x64, 0x12, 0x1e, 0x0a, 0x0a, 0x43, 0x6f, 0x73, 0x74, 0x75, 0x6d, 0x65, 0x72, 0x49, 0x64, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x43, 0x6f, 0x73, 0x74, 0x75, 0x6d, 0x65, 0x72, 0x49,
+	0x64, 0x12, 0x32, 0x0a, 0x14, 0x55, 0x73, 0x65, 0x72, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x49, 0x6e,
+	0x76, 0x65, 0x6e, 0x74, 0x6f, 0x72, 0x79, 0x49, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x14, 0x55, 0x73, 0x65, 0x72, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x49, 0x6e, 0x76, 0x65, 0x6e, 0x74,
+	0x6f, 0x72, 0x79, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x4e, 0x6f, 0x64, 0x65, 0x49, 0x64, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x4e, 0x6f, 0x64, 0x65, 0x49, 0x64, 0x12, 0x0e, 0x0a,
+	0x02, 0x49, 0x70, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x49, 0x70, 0x12, 0x1a, 0x0a,
+	0x08, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52,
+	0x08, 0x49, 0x6e, 0x74, 0x65, 0x72, 0x76, 0x61, 0x6c, 0x12, 0x2c, 0x0a, 0x07, 0x4d, 0x6f, 0x64,
+	0x75, 0x6c, 0x65, 0x73, 0x18, 0x07, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x12, 0x2e, 0x69, 0x6e, 0x74,
+	0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x2e, 0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x52, 0x07,
+	0x4d, 0x6f, 0x64, 0x75, 0x6c, 0x65, 0x73, 0x12, 0x2c, 0x0a, 0x04, 0x4e, 0x6f, 0x64, 0x65, 0x18,
+	0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63,
+	0x65, 0x73, 0x2e, 0x4e, 0x6f, 0x64, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x48, 0x00, 0x52,
+	0x04, 0x4e, 0x6f, 0x64, 0x65, 0x12, 0x35, 0x0a, 0x07, 0x57, 0x65, 0x62, 0x73, 0x69, 0x74, 0x65,
+	0x18, 0x09, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61,
+	0x63, 0x65, 0x73, 0x2e, 0x57, 0x65, 0x62, 0x73, 0x69, 0x74, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69,
+	0x67, 0x48, 0x00, 0x52, 0x07, 0x57, 0x65, 0x62, 0x73, 0x69, 0x74, 0x65, 0x12, 0x35, 0x0a, 0x07,
+	0x49, 0x43, 0x4d, 0x50, 0x51, 0x4f, 0x53, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e,
+	0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x2e, 0x49, 0x43, 0x4d, 0x50, 0x51,
+	0x4f, 0x53, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x48, 0x00, 0x52, 0x07, 0x49, 0x43, 0x4d, 0x50,
+	0x51, 0x4f, 0x53, 0x12, 0x29, 0x0a, 0x03, 0x44, 0x4e, 0x53, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x15, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x2e, 0x44, 0x4e,
+	0x53, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x48, 0x00, 0x52, 0x03, 0x44, 0x4e, 0x53, 0x12, 0x29,
+	0x0a, 0x03, 0x54, 0x43, 0x50, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x69, 0x6e,
+	0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x2e, 0x54, 0x43, 0x50, 0x43, 0x6f, 0x6e, 0x66,
+	0x69, 0x67, 0x48, 0x00, 0x52, 0x03, 0x54, 0x43, 0x50, 0x12, 0x2c, 0x0a, 0x04, 0x47, 0x52, 0x50,
+	0x43, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x66,
+	0x61, 0x63, 0x65, 0x73, 0x2e, 0x47, 0x52, 0x50, 0x43, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x48,
+	0x00, 0x52, 0x04, 0x47, 0x52, 0x50, 0x43, 0x12, 0x20, 0x0a, 0x0b, 0x4c, 0x61, 0x73, 0x74, 0x55,
+	0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x03, 0x52, 0x0b, 0x4c, 0x61,
+	0x73, 0x74, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x64, 0x42, 0x0d, 0x0a, 0x0b, 0x50, 0x72, 0x6f,
+	0x62, 0x65, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x67, 0x42, 0x3f, 0x5a, 0x3d, 0x67, 0x69, 0x74, 0x68,
+	0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x61, 0x62, 0x69, 0x61, 0x6c, 0x65, 0x6d, 0x75, 0x65,
+	0x6c, 0x2f, 0x70, 0x72, 0x6f, 0x6d, 0x65, 0x74, 0x68, 0x65, 0x75, 0x73, 0x2d, 0x65, 0x78, 0x70,
+	0x6f, 0x72, 0x74, 0x65, 0x72, 0x2f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x73, 0x3b, 0x69,
 	0x6e, 0x74, 0x65, 0x72, 0x66, 0x61, 0x63, 0x65, 0x73, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
 	0x33,
 }
@@ -793,32 +1218,42 @@ func file_messages_proto_rawDescGZIP() []byte {
 	return file_messages_proto_rawDescData
 }
 
-var file_messages_proto_msgTypes = make([]protoimpl.MessageInfo, 10)
+var file_messages_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_messages_proto_goTypes = []interface{}{
-	(*PublicMsg)(nil),      // 0: interfaces.PublicMsg
-	(*CollectDataMsg)(nil), // 1: interfaces.CollectDataMsg
-	(*Module)(nil),         // 2: interfaces.Module
-	(*NodeConfig)(nil),     // 3: interfaces.NodeConfig
-	(*Authorization)(nil),  // 4: interfaces.Authorization
-	(*WebsiteConfig)(nil),  // 5: interfaces.WebsiteConfig
-	(*ICMPQOSConfig)(nil),  // 6: interfaces.ICMPQOSConfig
-	(*WorkerProbe)(nil),    // 7: interfaces.WorkerProbe
-	nil,                    // 8: interfaces.Module.ConfigEntry
-	nil,                    // 9: interfaces.WebsiteConfig.HeadersEntry
+	(*PublicMsg)(nil),        // 0: interfaces.PublicMsg
+	(*CollectDataMsg)(nil),   // 1: interfaces.CollectDataMsg
+	(*Module)(nil),           // 2: interfaces.Module
+	(*NodeConfig)(nil),       // 3: interfaces.NodeConfig
+	(*Authorization)(nil),    // 4: interfaces.Authorization
+	(*WebsiteConfig)(nil),    // 5: interfaces.WebsiteConfig
+	(*ICMPQOSConfig)(nil),    // 6: interfaces.ICMPQOSConfig
+	(*DNSConfig)(nil),        // 7: interfaces.DNSConfig
+	(*TCPQueryResponse)(nil), // 8: interfaces.TCPQueryResponse
+	(*TCPConfig)(nil),        // 9: interfaces.TCPConfig
+	(*GRPCConfig)(nil),       // 10: interfaces.GRPCConfig
+	(*WorkerProbe)(nil),      // 11: interfaces.WorkerProbe
+	nil,                      // 12: interfaces.Module.ConfigEntry
+	nil,                      // 13: interfaces.WebsiteConfig.HeadersEntry
+	nil,                      // 14: interfaces.GRPCConfig.MetadataEntry
 }
 var file_messages_proto_depIdxs = []int32{
-	8, // 0: interfaces.Module.Config:type_name -> interfaces.Module.ConfigEntry
-	4, // 1: interfaces.WebsiteConfig.Authorization:type_name -> interfaces.Authorization
-	9, // 2: interfaces.WebsiteConfig.Headers:type_name -> interfaces.WebsiteConfig.HeadersEntry
-	2, // 3: interfaces.WorkerProbe.Modules:type_name -> interfaces.Module
-	3, // 4: interfaces.WorkerProbe.Node:type_name -> interfaces.NodeConfig
-	5, // 5: interfaces.WorkerProbe.Website:type_name -> interfaces.WebsiteConfig
-	6, // 6: interfaces.WorkerProbe.ICMPQOS:type_name -> interfaces.ICMPQOSConfig
-	7, // [7:7] is the sub-list for method output_type
-	7, // [7:7] is the sub-list for method input_type
-	7, // [7:7] is the sub-list for extension type_name
-	7, // [7:7] is the sub-list for extension extendee
-	0, // [0:7] is the sub-list for field type_name
+	12, // 0: interfaces.Module.Config:type_name -> interfaces.Module.ConfigEntry
+	4,  // 1: interfaces.WebsiteConfig.Authorization:type_name -> interfaces.Authorization
+	13, // 2: interfaces.WebsiteConfig.Headers:type_name -> interfaces.WebsiteConfig.HeadersEntry
+	8,  // 3: interfaces.TCPConfig.QueryResponse:type_name -> interfaces.TCPQueryResponse
+	14, // 4: interfaces.GRPCConfig.Metadata:type_name -> interfaces.GRPCConfig.MetadataEntry
+	2,  // 5: interfaces.WorkerProbe.Modules:type_name -> interfaces.Module
+	3,  // 6: interfaces.WorkerProbe.Node:type_name -> interfaces.NodeConfig
+	5,  // 7: interfaces.WorkerProbe.Website:type_name -> interfaces.WebsiteConfig
+	6,  // 8: interfaces.WorkerProbe.ICMPQOS:type_name -> interfaces.ICMPQOSConfig
+	7,  // 9: interfaces.WorkerProbe.DNS:type_name -> interfaces.DNSConfig
+	9,  // 10: interfaces.WorkerProbe.TCP:type_name -> interfaces.TCPConfig
+	10, // 11: interfaces.WorkerProbe.GRPC:type_name -> interfaces.GRPCConfig
+	12, // [12:12] is the sub-list for method output_type
+	12, // [12:12] is the sub-list for method input_type
+	12, // [12:12] is the sub-list for extension type_name
+	12, // [12:12] is the sub-list for extension extendee
+	0,  // [0:12] is the sub-list for field type_name
 }
 
 func init() { file_messages_proto_init() }
@@ -912,6 +1347,54 @@ func file_messages_proto_init() {
 			}
 		}
 		file_messages_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*DNSConfig); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_messages_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TCPQueryResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_messages_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TCPConfig); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_messages_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GRPCConfig); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_messages_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
 			switch v := v.(*WorkerProbe); i {
 			case 0:
 				return &v.state
@@ -924,10 +1407,16 @@ func file_messages_proto_init() {
 			}
 		}
 	}
-	file_messages_proto_msgTypes[7].OneofWrappers = []interface{}{
+	file_messages_proto_msgTypes[7].OneofWrappers = []interface{}{}
+	file_messages_proto_msgTypes[9].OneofWrappers = []interface{}{}
+	file_messages_proto_msgTypes[10].OneofWrappers = []interface{}{}
+	file_messages_proto_msgTypes[11].OneofWrappers = []interface{}{
 		(*WorkerProbe_Node)(nil),
 		(*WorkerProbe_Website)(nil),
 		(*WorkerProbe_ICMPQOS)(nil),
+		(*WorkerProbe_DNS)(nil),
+		(*WorkerProbe_TCP)(nil),
+		(*WorkerProbe_GRPC)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -935,7 +1424,7 @@ func file_messages_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_messages_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   10,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
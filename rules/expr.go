@@ -0,0 +1,152 @@
+package rules
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	exprRE = regexp.MustCompile(`^\s*([a-zA-Z_:][a-zA-Z0-9_:]*)\s*(\{.*\})?\s*(>=|<=|==|!=|>|<)\s*(\S+?)(?:\s+for\s+(\d+)\s+runs?)?\s*$`)
+	// matcherRE matches one label matcher at the start of a selector.
+	matcherRE = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*(=|!=)\s*("(?:[^"\\]|\\.)*")\s*(,|$)`)
+)
+
+type labelMatcher struct {
+	name     string
+	value    string
+	negative bool
+}
+
+// Expr is a threshold over a metric of a probe result, like
+// probe_qos_packet_loss_gauge{total="loss_percentage"} > 5 for 3 runs. It
+// holds if any series of the metric matching the labels crosses the
+// threshold, for at least the given number of consecutive runs.
+type Expr struct {
+	metric    string
+	matchers  []labelMatcher
+	op        string
+	threshold float64
+	// Runs is the number of consecutive runs the threshold has to be
+	// crossed, at least 1.
+	Runs int
+
+	text string
+}
+
+// ParseExpr parses a threshold expression.
+func ParseExpr(s string) (*Expr, error) {
+	m := exprRE.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("expression %q is not valid, expected metric{label=\"value\"} > threshold [for N runs]", s)
+	}
+	e := &Expr{metric: m[1], op: m[3], Runs: 1, text: strings.TrimSpace(s)}
+
+	if m[2] != "" {
+		selector := strings.TrimSpace(m[2][1 : len(m[2])-1])
+		for selector != "" {
+			lm := matcherRE.FindStringSubmatch(selector)
+			if lm == nil {
+				return nil, fmt.Errorf("label matchers %q of expression %q are not valid", m[2], s)
+			}
+			value, err := strconv.Unquote(lm[3])
+			if err != nil {
+				return nil, fmt.Errorf("label value %s of expression %q is not valid: %w", lm[3], s, err)
+			}
+			e.matchers = append(e.matchers, labelMatcher{name: lm[1], value: value, negative: lm[2] == "!="})
+			selector = selector[len(lm[0]):]
+		}
+	}
+
+	threshold, err := strconv.ParseFloat(m[4], 64)
+	if err != nil {
+		return nil, fmt.Errorf("threshold %q of expression %q is not a number", m[4], s)
+	}
+	e.threshold = threshold
+
+	if m[5] != "" {
+		runs, err := strconv.Atoi(m[5])
+		if err != nil || runs < 1 {
+			return nil, fmt.Errorf("runs of expression %q must be at least 1", s)
+		}
+		e.Runs = runs
+	}
+	return e, nil
+}
+
+// String returns the expression as it was parsed.
+func (e *Expr) String() string {
+	return e.text
+}
+
+func (e *Expr) compare(v float64) bool {
+	switch e.op {
+	case ">":
+		return v > e.threshold
+	case ">=":
+		return v >= e.threshold
+	case "<":
+		return v < e.threshold
+	case "<=":
+		return v <= e.threshold
+	case "==":
+		return v == e.threshold
+	default:
+		return v != e.threshold
+	}
+}
+
+func (e *Expr) matchesLabels(m *dto.Metric) bool {
+	for _, lm := range e.matchers {
+		value := ""
+		for _, lp := range m.GetLabel() {
+			if lp.GetName() == lm.name {
+				value = lp.GetValue()
+				break
+			}
+		}
+		if (value == lm.value) == lm.negative {
+			return false
+		}
+	}
+	return true
+}
+
+// Eval reports whether a run crosses the threshold, with the value of the
+// first series crossing it, or else of the first matching series. The
+// value is NaN if no series matches.
+func (e *Expr) Eval(families map[string]*dto.MetricFamily) (bool, float64) {
+	value := math.NaN()
+	mf, ok := families[e.metric]
+	if !ok {
+		return false, value
+	}
+	found := false
+	for _, m := range mf.GetMetric() {
+		if !e.matchesLabels(m) {
+			continue
+		}
+		var v float64
+		switch {
+		case m.Gauge != nil:
+			v = m.GetGauge().GetValue()
+		case m.Counter != nil:
+			v = m.GetCounter().GetValue()
+		case m.Untyped != nil:
+			v = m.GetUntyped().GetValue()
+		default:
+			continue
+		}
+		if e.compare(v) {
+			return true, v
+		}
+		if !found {
+			value, found = v, true
+		}
+	}
+	return false, value
+}
@@ -0,0 +1,301 @@
+// Package rules evaluates threshold rules over probe results and tracks
+// the resulting state of every target.
+package rules
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/abialemuel/prometheus-exporter/helper"
+)
+
+// Level is the state of a rule for a target.
+type Level int
+
+const (
+	OK Level = iota
+	Warn
+	Crit
+)
+
+func (l Level) String() string {
+	switch l {
+	case OK:
+		return "OK"
+	case Warn:
+		return "WARN"
+	case Crit:
+		return "CRIT"
+	default:
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// FlapDetection suppresses events of targets changing state too often.
+type FlapDetection struct {
+	// Window is the number of recent runs considered, zero disables flap
+	// detection.
+	Window int `yaml:"window,omitempty"`
+	// Changes is the number of state changes within the window that mark
+	// a target as flapping. It stops flapping once the changes fall below
+	// half of it.
+	Changes int `yaml:"changes,omitempty"`
+}
+
+// Rule derives the level of a target from the metrics of its probe
+// results. A target is CRIT while Critical holds, else WARN while Warning
+// holds, else OK; either expression may be empty.
+type Rule struct {
+	Name     string `yaml:"name"`
+	Warning  string `yaml:"warning,omitempty"`
+	Critical string `yaml:"critical,omitempty"`
+	// RecoverAfter is the number of consecutive runs at a lower level
+	// before the level drops, so that values hovering around a threshold
+	// do not toggle the state. It defaults to 1.
+	RecoverAfter int           `yaml:"recover_after,omitempty"`
+	Flapping     FlapDetection `yaml:"flapping,omitempty"`
+}
+
+// Event reports a state change of a rule for a target. Events are also
+// sent when a target starts or stops flapping; state changes while it is
+// flapping are not reported.
+type Event struct {
+	Rule     string    `json:"rule"`
+	Target   string    `json:"target"`
+	From     Level     `json:"from"`
+	To       Level     `json:"to"`
+	Value    float64   `json:"value"`
+	Flapping bool      `json:"flapping"`
+	Time     time.Time `json:"time"`
+}
+
+type rule struct {
+	Rule
+	warning, critical *Expr
+}
+
+type stateKey struct {
+	rule, target string
+}
+
+type state struct {
+	level              Level
+	warnRuns, critRuns int
+	// lowerRuns counts the consecutive runs below the current level.
+	lowerRuns int
+	// changes records whether the level changed in the recent runs.
+	changes  []bool
+	flapping bool
+}
+
+// Engine evaluates rules over probe results. It is safe for concurrent
+// use.
+type Engine struct {
+	rules   []*rule
+	handler func(Event)
+
+	mu     sync.Mutex
+	states map[stateKey]*state
+}
+
+// NewEngine returns an engine evaluating rules and passing state changes
+// to handler. The handler is called synchronously, outside of the locks of
+// the engine.
+func NewEngine(rules []Rule, handler func(Event)) (*Engine, error) {
+	if handler == nil {
+		return nil, errors.New("event handler must be set")
+	}
+	e := &Engine{handler: handler, states: map[stateKey]*state{}}
+	names := map[string]bool{}
+	for _, r := range rules {
+		if r.Name == "" {
+			return nil, errors.New("rule name must be set")
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("rule %q is defined twice", r.Name)
+		}
+		names[r.Name] = true
+		if r.Warning == "" && r.Critical == "" {
+			return nil, fmt.Errorf("rule %q needs a warning or critical expression", r.Name)
+		}
+		if r.RecoverAfter == 0 {
+			r.RecoverAfter = 1
+		}
+		if r.RecoverAfter < 0 || r.Flapping.Window < 0 || r.Flapping.Changes < 0 {
+			return nil, fmt.Errorf("rule %q has negative run counts", r.Name)
+		}
+		if r.Flapping.Window > 0 && r.Flapping.Changes < 2 {
+			return nil, fmt.Errorf("rule %q needs at least 2 changes to detect flapping", r.Name)
+		}
+		cr := &rule{Rule: r}
+		var err error
+		if r.Warning != "" {
+			if cr.warning, err = ParseExpr(r.Warning); err != nil {
+				return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+			}
+		}
+		if r.Critical != "" {
+			if cr.critical, err = ParseExpr(r.Critical); err != nil {
+				return nil, fmt.Errorf("rule %q: %w", r.Name, err)
+			}
+		}
+		e.rules = append(e.rules, cr)
+	}
+	return e, nil
+}
+
+// ChannelHandler returns a handler sending events to ch. It blocks while
+// ch is full.
+func ChannelHandler(ch chan<- Event) func(Event) {
+	return func(ev Event) {
+		ch <- ev
+	}
+}
+
+// Evaluate runs all rules over a probe result of a target at a time.
+func (e *Engine) Evaluate(target string, result helper.ProbeResult, at time.Time) error {
+	text, err := result.Text()
+	if err != nil {
+		return err
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(bytes.NewReader(text))
+	if err != nil {
+		return fmt.Errorf("error parsing probe result: %w", err)
+	}
+	e.EvaluateFamilies(target, families, at)
+	return nil
+}
+
+// EvaluateFamilies runs all rules over metric families of a target at a
+// time.
+func (e *Engine) EvaluateFamilies(target string, families map[string]*dto.MetricFamily, at time.Time) {
+	var events []Event
+	e.mu.Lock()
+	for _, r := range e.rules {
+		key := stateKey{rule: r.Name, target: target}
+		s, ok := e.states[key]
+		if !ok {
+			s = &state{}
+			e.states[key] = s
+		}
+		if ev, ok := s.update(r, families); ok {
+			ev.Rule, ev.Target, ev.Time = r.Name, target, at
+			events = append(events, ev)
+		}
+	}
+	e.mu.Unlock()
+
+	for _, ev := range events {
+		e.handler(ev)
+	}
+}
+
+// State returns the level of a rule for a target and whether it is
+// flapping.
+func (e *Engine) State(rule, target string) (Level, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, ok := e.states[stateKey{rule: rule, target: target}]
+	if !ok {
+		return OK, false
+	}
+	return s.level, s.flapping
+}
+
+// Forget drops the states of a target.
+func (e *Engine) Forget(target string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for key := range e.states {
+		if key.target == target {
+			delete(e.states, key)
+		}
+	}
+}
+
+// update evaluates a rule for a run and returns the event to emit, if
+// any.
+func (s *state) update(r *rule, families map[string]*dto.MetricFamily) (Event, bool) {
+	var value float64
+	candidate := OK
+	if r.warning != nil {
+		var crossed bool
+		crossed, value = r.warning.Eval(families)
+		s.warnRuns = countRun(s.warnRuns, crossed)
+		if crossed && s.warnRuns >= r.warning.Runs {
+			candidate = Warn
+		}
+	}
+	if r.critical != nil {
+		crossed, v := r.critical.Eval(families)
+		s.critRuns = countRun(s.critRuns, crossed)
+		if crossed && s.critRuns >= r.critical.Runs {
+			candidate = Crit
+		}
+		if crossed || r.warning == nil {
+			value = v
+		}
+	}
+
+	from := s.level
+	switch {
+	case candidate > s.level:
+		s.level, s.lowerRuns = candidate, 0
+	case candidate < s.level:
+		s.lowerRuns++
+		if s.lowerRuns >= r.RecoverAfter {
+			s.level, s.lowerRuns = candidate, 0
+		}
+	default:
+		s.lowerRuns = 0
+	}
+	changed := s.level != from
+
+	wasFlapping := s.flapping
+	if r.Flapping.Window > 0 {
+		s.changes = append(s.changes, changed)
+		if len(s.changes) > r.Flapping.Window {
+			s.changes = s.changes[1:]
+		}
+		n := 0
+		for _, c := range s.changes {
+			if c {
+				n++
+			}
+		}
+		if n >= r.Flapping.Changes {
+			s.flapping = true
+		} else if n < r.Flapping.Changes/2 {
+			s.flapping = false
+		}
+	}
+
+	ev := Event{From: from, To: s.level, Value: value, Flapping: s.flapping}
+	switch {
+	case s.flapping != wasFlapping:
+		return ev, true
+	case s.flapping:
+		return ev, false
+	default:
+		return ev, changed
+	}
+}
+
+func countRun(runs int, crossed bool) int {
+	if crossed {
+		return runs + 1
+	}
+	return 0
+}
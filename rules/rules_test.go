@@ -0,0 +1,156 @@
+package rules_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/abialemuel/prometheus-exporter/helper"
+	"github.com/abialemuel/prometheus-exporter/rules"
+)
+
+// lossResult returns a probe result with the given packet loss and an
+// unrelated series of the same metric.
+func lossResult(loss float64) helper.ProbeResult {
+	series := func(total string, v float64) *dto.Metric {
+		return &dto.Metric{
+			Label: []*dto.LabelPair{{Name: proto.String("total"), Value: proto.String(total)}},
+			Gauge: &dto.Gauge{Value: proto.Float64(v)},
+		}
+	}
+	return helper.NewProbeResult(true, []*dto.MetricFamily{{
+		Name:   proto.String("probe_qos_packet_loss_gauge"),
+		Help:   proto.String("Packet loss"),
+		Type:   dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{series("packets_sent", 100), series("loss_percentage", loss)},
+	}})
+}
+
+func TestParseExpr(t *testing.T) {
+	for _, s := range []string{
+		`probe_qos_packet_loss_gauge{total="loss_percentage"} > 5 for 3 runs`,
+		`probe_duration_seconds >= 1.5`,
+		`probe_success{} == 0 for 1 run`,
+		`probe_http_status_code{phase!="x", code="a\"b"} != 200`,
+	} {
+		e, err := rules.ParseExpr(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, s, e.String())
+		}
+	}
+	for _, s := range []string{
+		`probe_success`,
+		`probe_success > five`,
+		`probe_success{code=200} > 1`,
+		`probe_success > 1 for 0 runs`,
+	} {
+		_, err := rules.ParseExpr(s)
+		assert.Error(t, err, s)
+	}
+
+	e, err := rules.ParseExpr(`probe_qos_packet_loss_gauge{total="loss_percentage"} > 5 for 3 runs`)
+	require.NoError(t, err)
+	assert.Equal(t, 3, e.Runs)
+}
+
+func TestEngineStates(t *testing.T) {
+	events := make(chan rules.Event, 10)
+	engine, err := rules.NewEngine([]rules.Rule{{
+		Name:         "packet_loss",
+		Warning:      `probe_qos_packet_loss_gauge{total="loss_percentage"} > 5 for 2 runs`,
+		Critical:     `probe_qos_packet_loss_gauge{total="loss_percentage"} > 20`,
+		RecoverAfter: 2,
+	}}, rules.ChannelHandler(events))
+	require.NoError(t, err)
+
+	t0 := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i, tc := range []struct {
+		loss  float64
+		level rules.Level
+		event bool
+	}{
+		{loss: 0, level: rules.OK},
+		{loss: 10, level: rules.OK},
+		{loss: 10, level: rules.Warn, event: true},
+		{loss: 30, level: rules.Crit, event: true},
+		{loss: 10, level: rules.Crit}, // Hysteresis holds the level for a run.
+		{loss: 10, level: rules.Warn, event: true},
+		{loss: 0, level: rules.Warn},
+		{loss: 0, level: rules.OK, event: true},
+	} {
+		require.NoError(t, engine.Evaluate("target", lossResult(tc.loss), t0.Add(time.Duration(i)*time.Minute)))
+		level, flapping := engine.State("packet_loss", "target")
+		assert.Equal(t, tc.level, level, "run %d", i)
+		assert.False(t, flapping)
+		if tc.event {
+			select {
+			case ev := <-events:
+				assert.Equal(t, tc.level, ev.To, "run %d", i)
+				assert.Equal(t, "packet_loss", ev.Rule)
+				assert.Equal(t, "target", ev.Target)
+				assert.Equal(t, tc.loss, ev.Value)
+				assert.Equal(t, t0.Add(time.Duration(i)*time.Minute), ev.Time)
+			default:
+				t.Errorf("Expected an event in run %d", i)
+			}
+		}
+		assert.Empty(t, events, "run %d", i)
+	}
+
+	// Unknown metrics leave the target OK.
+	engine.EvaluateFamilies("other", nil, t0)
+	level, _ := engine.State("packet_loss", "other")
+	assert.Equal(t, rules.OK, level)
+	assert.Empty(t, events)
+}
+
+func TestEngineFlapping(t *testing.T) {
+	var events []rules.Event
+	engine, err := rules.NewEngine([]rules.Rule{{
+		Name:     "down",
+		Critical: `probe_qos_packet_loss_gauge{total="loss_percentage"} >= 100`,
+		Flapping: rules.FlapDetection{Window: 6, Changes: 4},
+	}}, func(ev rules.Event) { events = append(events, ev) })
+	require.NoError(t, err)
+
+	for _, loss := range []float64{100, 0, 100, 0, 100, 0, 0, 0, 0, 0, 0} {
+		engine.Evaluate("target", lossResult(loss), time.Now())
+	}
+	var flapping []bool
+	for _, ev := range events {
+		flapping = append(flapping, ev.Flapping)
+	}
+	// Three changes are reported, the fourth starts flapping and hides the
+	// fifth, quiet runs end flapping.
+	assert.Equal(t, []bool{false, false, false, true, false}, flapping)
+	assert.Equal(t, rules.OK, events[len(events)-1].To)
+}
+
+func TestNewEngineValidation(t *testing.T) {
+	handler := func(rules.Event) {}
+	for _, r := range [][]rules.Rule{
+		{{Name: "empty"}},
+		{{Warning: "probe_success < 1"}},
+		{{Name: "bad", Critical: "probe_success <"}},
+		{{Name: "twice", Critical: "probe_success < 1"}, {Name: "twice", Critical: "probe_success < 1"}},
+		{{Name: "flap", Critical: "probe_success < 1", Flapping: rules.FlapDetection{Window: 5, Changes: 1}}},
+	} {
+		_, err := rules.NewEngine(r, handler)
+		assert.Error(t, err)
+	}
+	_, err := rules.NewEngine(nil, nil)
+	assert.Error(t, err)
+}
+
+func TestExprEvalMissingSeries(t *testing.T) {
+	e, err := rules.ParseExpr(`probe_qos_packet_loss_gauge{total="jitter"} > 1`)
+	require.NoError(t, err)
+	crossed, value := e.Eval(nil)
+	assert.False(t, crossed)
+	assert.True(t, math.IsNaN(value))
+}
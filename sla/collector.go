@@ -0,0 +1,60 @@
+package sla
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	windowLabels = []string{"probe_id", "module", "window"}
+
+	availabilityDesc = prometheus.NewDesc("sla_availability_ratio",
+		"Share of the observed time in the window with successful probes.", windowLabels, nil)
+	downtimeDesc = prometheus.NewDesc("sla_downtime_seconds",
+		"Time in the window with failed probes.", windowLabels, nil)
+	incidentsDesc = prometheus.NewDesc("sla_incidents",
+		"Number of incidents in the window.", windowLabels, nil)
+	mttrDesc = prometheus.NewDesc("sla_mttr_seconds",
+		"Mean time to recovery of the incidents in the window.", windowLabels, nil)
+	mtbfDesc = prometheus.NewDesc("sla_mtbf_seconds",
+		"Mean time between the incidents in the window.", windowLabels, nil)
+	errorBudgetDesc = prometheus.NewDesc("sla_error_budget_remaining_ratio",
+		"Share of the error budget of the window that is left.", windowLabels, nil)
+	burnRateDesc = prometheus.NewDesc("sla_error_budget_burn_rate",
+		"Rate at which the error budget of the window is used.", windowLabels, nil)
+	upDesc = prometheus.NewDesc("sla_up",
+		"Whether the latest probe succeeded.", []string{"probe_id", "module"}, nil)
+	objectiveDesc = prometheus.NewDesc("sla_objective_ratio",
+		"Availability objective the error budgets are based on.", nil, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (t *Tracker) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{availabilityDesc, downtimeDesc, incidentsDesc, mttrDesc, mtbfDesc, errorBudgetDesc, burnRateDesc, upDesc, objectiveDesc} {
+		ch <- d
+	}
+}
+
+// Collect implements prometheus.Collector, reporting at the current time.
+func (t *Tracker) Collect(ch chan<- prometheus.Metric) {
+	r := t.Report(time.Now())
+	ch <- prometheus.MustNewConstMetric(objectiveDesc, prometheus.GaugeValue, r.Objective)
+	for _, s := range r.Services {
+		up := 0.0
+		if s.Up {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(upDesc, prometheus.GaugeValue, up, s.ProbeID, s.Module)
+		for _, w := range s.Windows {
+			labels := []string{s.ProbeID, s.Module, w.Window}
+			ch <- prometheus.MustNewConstMetric(availabilityDesc, prometheus.GaugeValue, w.Availability, labels...)
+			ch <- prometheus.MustNewConstMetric(downtimeDesc, prometheus.GaugeValue, w.Downtime, labels...)
+			ch <- prometheus.MustNewConstMetric(incidentsDesc, prometheus.GaugeValue, float64(w.Incidents), labels...)
+			ch <- prometheus.MustNewConstMetric(mttrDesc, prometheus.GaugeValue, w.MTTR, labels...)
+			ch <- prometheus.MustNewConstMetric(mtbfDesc, prometheus.GaugeValue, w.MTBF, labels...)
+			ch <- prometheus.MustNewConstMetric(errorBudgetDesc, prometheus.GaugeValue, w.ErrorBudgetRemaining, labels...)
+			ch <- prometheus.MustNewConstMetric(burnRateDesc, prometheus.GaugeValue, w.BurnRate, labels...)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Package sla aggregates probe results into availability, incidents and
+// error budgets over rolling windows.
+package sla
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/abialemuel/prometheus-exporter/helper"
+)
+
+// Window is a rolling window ending at the time of a report.
+type Window struct {
+	Name   string
+	Length time.Duration
+}
+
+// DefaultWindows are the windows of reports when none are configured.
+var DefaultWindows = []Window{
+	{Name: "1h", Length: time.Hour},
+	{Name: "24h", Length: 24 * time.Hour},
+	{Name: "30d", Length: 30 * 24 * time.Hour},
+}
+
+// Key identifies the results of a module probing a service.
+type Key struct {
+	ProbeID string `json:"probeId"`
+	Module  string `json:"module"`
+}
+
+// period is a time span in which all results had the same outcome.
+type period struct {
+	start time.Time
+	up    bool
+}
+
+// series holds the periods of a key, oldest first. The outcome of a result
+// holds until the next result.
+type series struct {
+	periods []period
+	last    time.Time
+}
+
+// Tracker ingests probe results and aggregates them per key. It is safe for
+// concurrent use.
+type Tracker struct {
+	objective float64
+	windows   []Window
+	retention time.Duration
+
+	mu     sync.Mutex
+	series map[Key]*series
+}
+
+// NewTracker returns a tracker for an availability objective between 0 and
+// 1, such as 0.999, reporting on the given windows or DefaultWindows.
+func NewTracker(objective float64, windows ...Window) (*Tracker, error) {
+	if objective <= 0 || objective >= 1 {
+		return nil, fmt.Errorf("objective %v must be between 0 and 1", objective)
+	}
+	if len(windows) == 0 {
+		windows = DefaultWindows
+	}
+	t := &Tracker{objective: objective, windows: windows, series: map[Key]*series{}}
+	for _, w := range windows {
+		if w.Name == "" || w.Length <= 0 {
+			return nil, errors.New("windows need a name and a positive length")
+		}
+		if w.Length > t.retention {
+			t.retention = w.Length
+		}
+	}
+	return t, nil
+}
+
+// Observe records the outcome of a probe at a time. Results older than the
+// latest result of the key are ignored.
+func (t *Tracker) Observe(probeID, module string, at time.Time, success bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := Key{ProbeID: probeID, Module: module}
+	s, ok := t.series[key]
+	if !ok {
+		s = &series{}
+		t.series[key] = s
+	}
+	if at.Before(s.last) {
+		return
+	}
+	s.last = at
+	if n := len(s.periods); n == 0 || s.periods[n-1].up != success {
+		s.periods = append(s.periods, period{start: at, up: success})
+	}
+
+	// Drop periods that ended before the longest window, keeping the one
+	// the window starts in.
+	cutoff := at.Add(-t.retention)
+	i := 0
+	for i+1 < len(s.periods) && !s.periods[i+1].start.After(cutoff) {
+		i++
+	}
+	s.periods = s.periods[i:]
+}
+
+// ObserveResult records a probe result.
+func (t *Tracker) ObserveResult(probeID, module string, at time.Time, result helper.ProbeResult) {
+	t.Observe(probeID, module, at, result.Success())
+}
+
+// Forget drops the results of a key, for example of a deleted probe.
+func (t *Tracker) Forget(probeID, module string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.series, Key{ProbeID: probeID, Module: module})
+}
+
+// Incident is a span of failed results. End is nil while the incident is
+// ongoing.
+type Incident struct {
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+	Duration float64    `json:"durationSeconds"`
+}
+
+// WindowReport aggregates the results of a key in a window. Durations are
+// in seconds and only cover the part of the window with results.
+type WindowReport struct {
+	Window       string  `json:"window"`
+	Observed     float64 `json:"observedSeconds"`
+	Availability float64 `json:"availability"`
+	Uptime       float64 `json:"uptimeSeconds"`
+	Downtime     float64 `json:"downtimeSeconds"`
+	Incidents    int     `json:"incidents"`
+	// MTTR is the mean downtime and MTBF the mean uptime per incident,
+	// both are zero without incidents.
+	MTTR float64 `json:"mttrSeconds"`
+	MTBF float64 `json:"mtbfSeconds"`
+	// ErrorBudgetRemaining is the share of the allowed downtime not used
+	// yet, it turns negative once the objective is missed. BurnRate is the
+	// rate at which the error budget is used, 1 uses it up exactly by the
+	// end of the window.
+	ErrorBudgetRemaining float64 `json:"errorBudgetRemaining"`
+	BurnRate             float64 `json:"burnRate"`
+}
+
+// ServiceReport aggregates the results of a key.
+type ServiceReport struct {
+	Key
+	Up        bool           `json:"up"`
+	Windows   []WindowReport `json:"windows"`
+	Incidents []Incident     `json:"incidents"`
+}
+
+// Report aggregates the results of all keys.
+type Report struct {
+	Time      time.Time       `json:"time"`
+	Objective float64         `json:"objective"`
+	Services  []ServiceReport `json:"services"`
+}
+
+// Report aggregates the results of all keys at a time, ordered by key.
+func (t *Tracker) Report(at time.Time) Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := Report{Time: at, Objective: t.objective, Services: make([]ServiceReport, 0, len(t.series))}
+	for key, s := range t.series {
+		r.Services = append(r.Services, t.serviceReport(key, s, at))
+	}
+	sort.Slice(r.Services, func(i, j int) bool {
+		a, b := r.Services[i].Key, r.Services[j].Key
+		if a.ProbeID != b.ProbeID {
+			return a.ProbeID < b.ProbeID
+		}
+		return a.Module < b.Module
+	})
+	return r
+}
+
+// ReportJSON returns the report at a time as JSON.
+func (t *Tracker) ReportJSON(at time.Time) ([]byte, error) {
+	return json.Marshal(t.Report(at))
+}
+
+func (t *Tracker) serviceReport(key Key, s *series, at time.Time) ServiceReport {
+	r := ServiceReport{Key: key, Incidents: []Incident{}}
+	if len(s.periods) == 0 {
+		return r
+	}
+	r.Up = s.periods[len(s.periods)-1].up
+
+	for _, w := range t.windows {
+		r.Windows = append(r.Windows, t.windowReport(w, s, at))
+	}
+	from := at.Add(-t.retention)
+	for i, p := range s.periods {
+		if p.up {
+			continue
+		}
+		incident := Incident{Start: p.start}
+		end := at
+		if i+1 < len(s.periods) {
+			end = s.periods[i+1].start
+			incident.End = &end
+		}
+		if !end.After(from) {
+			continue
+		}
+		incident.Duration = end.Sub(p.start).Seconds()
+		r.Incidents = append(r.Incidents, incident)
+	}
+	return r
+}
+
+func (t *Tracker) windowReport(w Window, s *series, at time.Time) WindowReport {
+	r := WindowReport{Window: w.Name}
+	from := at.Add(-w.Length)
+	for i, p := range s.periods {
+		start, end := p.start, at
+		if i+1 < len(s.periods) {
+			end = s.periods[i+1].start
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if !end.After(start) {
+			continue
+		}
+		d := end.Sub(start).Seconds()
+		if p.up {
+			r.Uptime += d
+		} else {
+			r.Downtime += d
+			r.Incidents++
+		}
+	}
+	r.Observed = r.Uptime + r.Downtime
+	if r.Observed == 0 {
+		// Nothing to judge yet, the budget is untouched.
+		r.Availability = 1
+		r.ErrorBudgetRemaining = 1
+		return r
+	}
+	r.Availability = r.Uptime / r.Observed
+	if r.Incidents > 0 {
+		r.MTTR = r.Downtime / float64(r.Incidents)
+		r.MTBF = r.Uptime / float64(r.Incidents)
+	}
+	r.BurnRate = (1 - r.Availability) / (1 - t.objective)
+	r.ErrorBudgetRemaining = 1 - r.BurnRate
+	return r
+}
@@ -0,0 +1,97 @@
+package sla_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/abialemuel/prometheus-exporter/helper"
+	"github.com/abialemuel/prometheus-exporter/sla"
+)
+
+func TestTrackerReport(t *testing.T) {
+	tracker, err := sla.NewTracker(0.99)
+	require.NoError(t, err)
+
+	t0 := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, o := range []struct {
+		minutes int
+		success bool
+	}{
+		{0, true}, {10, false}, {15, false}, {20, true}, {50, false},
+	} {
+		tracker.ObserveResult("probe-1", "http_2xx", t0.Add(time.Duration(o.minutes)*time.Minute), helper.NewProbeResult(o.success, nil))
+	}
+	// Results arriving late are ignored.
+	tracker.Observe("probe-1", "http_2xx", t0.Add(5*time.Minute), false)
+
+	report := tracker.Report(t0.Add(time.Hour))
+	require.Len(t, report.Services, 1)
+	s := report.Services[0]
+	assert.Equal(t, sla.Key{ProbeID: "probe-1", Module: "http_2xx"}, s.Key)
+	assert.False(t, s.Up)
+
+	require.Len(t, s.Windows, 3)
+	w := s.Windows[0]
+	assert.Equal(t, "1h", w.Window)
+	assert.Equal(t, 3600.0, w.Observed)
+	assert.InDelta(t, 2.0/3, w.Availability, 1e-9)
+	assert.Equal(t, 1200.0, w.Downtime)
+	assert.Equal(t, 2, w.Incidents)
+	assert.Equal(t, 600.0, w.MTTR)
+	assert.Equal(t, 1200.0, w.MTBF)
+	assert.InDelta(t, 100.0/3, w.BurnRate, 1e-9)
+	assert.InDelta(t, 1-100.0/3, w.ErrorBudgetRemaining, 1e-9)
+	// Longer windows only cover the observed time.
+	assert.Equal(t, w.Availability, s.Windows[2].Availability)
+
+	require.Len(t, s.Incidents, 2)
+	assert.Equal(t, t0.Add(10*time.Minute), s.Incidents[0].Start)
+	require.NotNil(t, s.Incidents[0].End)
+	assert.Equal(t, t0.Add(20*time.Minute), *s.Incidents[0].End)
+	assert.Nil(t, s.Incidents[1].End, "the last incident is ongoing")
+
+	// An hour later the first incident left the 1h window.
+	tracker.Observe("probe-1", "http_2xx", t0.Add(70*time.Minute), true)
+	w = tracker.Report(t0.Add(2 * time.Hour)).Services[0].Windows[0]
+	assert.Equal(t, 1, w.Incidents)
+	assert.Equal(t, 600.0, w.Downtime)
+
+	b, err := tracker.ReportJSON(t0.Add(time.Hour))
+	require.NoError(t, err)
+	var decoded sla.Report
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, 0.99, decoded.Objective)
+	assert.Equal(t, "probe-1", decoded.Services[0].ProbeID)
+}
+
+func TestTrackerRetention(t *testing.T) {
+	tracker, err := sla.NewTracker(0.999, sla.Window{Name: "1h", Length: time.Hour})
+	require.NoError(t, err)
+
+	t0 := time.Now().Add(-3 * time.Hour)
+	tracker.Observe("probe-1", "icmp", t0, false)
+	tracker.Observe("probe-1", "icmp", t0.Add(time.Hour), true)
+	tracker.Observe("probe-1", "icmp", time.Now(), true)
+
+	s := tracker.Report(time.Now()).Services[0]
+	assert.Empty(t, s.Incidents, "incidents older than the longest window are dropped")
+	assert.Equal(t, 1.0, s.Windows[0].Availability)
+	assert.Equal(t, 1.0, s.Windows[0].ErrorBudgetRemaining)
+
+	assert.Equal(t, 1, testutil.CollectAndCount(tracker, "sla_availability_ratio"))
+	assert.Equal(t, 1, testutil.CollectAndCount(tracker, "sla_up"))
+	tracker.Forget("probe-1", "icmp")
+	assert.Equal(t, 0, testutil.CollectAndCount(tracker, "sla_up"))
+}
+
+func TestNewTrackerValidation(t *testing.T) {
+	_, err := sla.NewTracker(1)
+	assert.Error(t, err)
+	_, err = sla.NewTracker(0.9, sla.Window{Name: "bad"})
+	assert.Error(t, err)
+}
@@ -24,12 +24,12 @@ import (
 	"sync"
 	"time"
 
-	"github.com/alecthomas/kingpin/v2"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/gosnmp/gosnmp"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/abialemuel/prometheus-exporter/helper"
 	"github.com/abialemuel/prometheus-exporter/snmp/config"
 	"github.com/abialemuel/prometheus-exporter/snmp/scraper"
 )
@@ -37,10 +37,17 @@ import (
 var (
 	// 64-bit float mantissa: https://en.wikipedia.org/wiki/Double-precision_floating-point_format
 	float64Mantissa uint64 = 9007199254740992
-	wrapCounters           = kingpin.Flag("snmp.wrap-large-counters", "Wrap 64-bit counters to avoid floating point rounding.").Default("true").Bool()
-	srcAddress             = kingpin.Flag("snmp.source-address", "Source address to send snmp from in the format 'address:port' to use when connecting targets. If the port parameter is empty or '0', as in '127.0.0.1:' or '[::1]:0', a source port number is automatically (random) chosen.").Default("").String()
+
+	// DefaultOptions wrap large counters and scrape one module at a time.
+	DefaultOptions = Options{
+		WrapCounters: true,
+		Concurrency:  1,
+	}
 )
 
+// Options set how a Collector scrapes its target.
+type Options = helper.SNMPOptions
+
 // Types preceded by an enum with their actual type.
 var combinedTypeMapping = map[string]map[int]string{
 	"InetAddress": {
@@ -116,16 +123,19 @@ func ScrapeTarget(snmp scraper.SNMPScraper, target string, auth *config.Auth, mo
 	if maxOids == 0 || version == 1 {
 		maxOids = 1
 	}
+	level.Debug(logger).Log("msg", "Requesting OIDs", "get", len(getOids), "walk", len(newWalk))
 	for len(getOids) > 0 {
 		oids := len(getOids)
 		if oids > maxOids {
 			oids = maxOids
 		}
 
+		getStart := time.Now()
 		packet, err := snmp.Get(getOids[:oids])
 		if err != nil {
 			return results, err
 		}
+		level.Debug(logger).Log("msg", "Get completed", "oids", oids, "returned", len(packet.Variables), "duration_seconds", time.Since(getStart).Seconds())
 		// SNMPv1 will return packet error for unsupported OIDs.
 		if packet.Error == gosnmp.NoSuchName && version == 1 {
 			level.Debug(logger).Log("msg", "OID not supported by target", "oids", getOids[0])
@@ -148,12 +158,15 @@ func ScrapeTarget(snmp scraper.SNMPScraper, target string, auth *config.Auth, mo
 	}
 
 	for _, subtree := range newWalk {
+		walkStart := time.Now()
 		pdus, err := snmp.WalkAll(subtree)
 		if err != nil {
 			return results, err
 		}
+		level.Debug(logger).Log("msg", "Walk completed", "oid", subtree, "returned", len(pdus), "duration_seconds", time.Since(walkStart).Seconds())
 		results.pdus = append(results.pdus, pdus...)
 	}
+	level.Debug(logger).Log("msg", "Scrape returned PDUs", "pdus", len(results.pdus))
 	return results, nil
 }
 
@@ -291,18 +304,18 @@ func NewNamedModule(name string, module *config.Module) *NamedModule {
 }
 
 type Collector struct {
-	ctx         context.Context
-	target      string
-	auth        *config.Auth
-	authName    string
-	modules     []*NamedModule
-	logger      log.Logger
-	metrics     Metrics
-	concurrency int
+	ctx      context.Context
+	target   string
+	auth     *config.Auth
+	authName string
+	modules  []*NamedModule
+	logger   log.Logger
+	metrics  Metrics
+	opts     Options
 }
 
-func New(ctx context.Context, target, authName string, auth *config.Auth, modules []*NamedModule, logger log.Logger, metrics Metrics, conc int) *Collector {
-	return &Collector{ctx: ctx, target: target, authName: authName, auth: auth, modules: modules, logger: logger, metrics: metrics, concurrency: conc}
+func New(ctx context.Context, target, authName string, auth *config.Auth, modules []*NamedModule, logger log.Logger, metrics Metrics, opts Options) *Collector {
+	return &Collector{ctx: ctx, target: target, authName: authName, auth: auth, modules: modules, logger: logger, metrics: metrics, opts: opts}
 }
 
 // Describe implements Prometheus.Collector.
@@ -390,7 +403,7 @@ func (c Collector) collect(ch chan<- prometheus.Metric, logger log.Logger, clien
 			}
 			if head.metric != nil {
 				// Found a match.
-				samples := pduToSamples(oidList[i+1:], &pdu, head.metric, oidToPdu, logger, c.metrics)
+				samples := pduToSamples(oidList[i+1:], &pdu, head.metric, oidToPdu, logger, c.metrics, c.opts.WrapCounters)
 				for _, sample := range samples {
 					ch <- sample
 				}
@@ -407,7 +420,7 @@ func (c Collector) collect(ch chan<- prometheus.Metric, logger log.Logger, clien
 // Collect implements Prometheus.Collector.
 func (c Collector) Collect(ch chan<- prometheus.Metric) {
 	wg := sync.WaitGroup{}
-	workerCount := c.concurrency
+	workerCount := c.opts.Concurrency
 	if workerCount < 1 {
 		workerCount = 1
 	}
@@ -419,7 +432,7 @@ func (c Collector) Collect(ch chan<- prometheus.Metric) {
 		go func(i int) {
 			defer wg.Done()
 			logger := log.With(c.logger, "worker", i)
-			client, err := scraper.NewGoSNMP(logger, c.target, *srcAddress)
+			client, err := scraper.NewGoSNMP(logger, c.target, c.opts.SourceAddress)
 			if err != nil {
 				level.Info(logger).Log("msg", err)
 				cancel()
@@ -467,10 +480,10 @@ func (c Collector) Collect(ch chan<- prometheus.Metric) {
 	wg.Wait()
 }
 
-func getPduValue(pdu *gosnmp.SnmpPDU) float64 {
+func getPduValue(pdu *gosnmp.SnmpPDU, wrapCounters bool) float64 {
 	switch pdu.Type {
 	case gosnmp.Counter64:
-		if *wrapCounters {
+		if wrapCounters {
 			// Wrap by 2^53.
 			return float64(gosnmp.ToBigInt(pdu.Value).Uint64() % float64Mantissa)
 		}
@@ -531,12 +544,12 @@ func parseDateAndTime(pdu *gosnmp.SnmpPDU) (float64, error) {
 	return float64(t.Unix()), nil
 }
 
-func pduToSamples(indexOids []int, pdu *gosnmp.SnmpPDU, metric *config.Metric, oidToPdu map[string]gosnmp.SnmpPDU, logger log.Logger, metrics Metrics) []prometheus.Metric {
+func pduToSamples(indexOids []int, pdu *gosnmp.SnmpPDU, metric *config.Metric, oidToPdu map[string]gosnmp.SnmpPDU, logger log.Logger, metrics Metrics, wrapCounters bool) []prometheus.Metric {
 	var err error
 	// The part of the OID that is the indexes.
 	labels := indexesToLabels(indexOids, metric, oidToPdu, metrics)
 
-	value := getPduValue(pdu)
+	value := getPduValue(pdu, wrapCounters)
 
 	labelnames := make([]string, 0, len(labels)+1)
 	labelvalues := make([]string, 0, len(labels)+1)
@@ -576,7 +589,7 @@ func pduToSamples(indexOids []int, pdu *gosnmp.SnmpPDU, metric *config.Metric, o
 			// Lookup associated sub type in previous object.
 			prevOid := fmt.Sprintf("%s.%s", getPrevOid(metric.Oid), listToOid(indexOids))
 			if prevPdu, ok := oidToPdu[prevOid]; ok {
-				val := int(getPduValue(&prevPdu))
+				val := int(getPduValue(&prevPdu, false))
 				if t, ok := typeMapping[val]; ok {
 					metricType = t
 				} else {
@@ -923,7 +936,7 @@ func indexesToLabels(indexOids []int, metric *config.Metric, oidToPdu map[string
 					prevOid = fmt.Sprintf("%s.%s", prevOid, listToOid(labelOids[label]))
 				}
 				if prevPdu, ok := oidToPdu[prevOid]; ok {
-					val := int(getPduValue(&prevPdu))
+					val := int(getPduValue(&prevPdu, false))
 					if ty, ok := typeMapping[val]; ok {
 						t = ty
 					}
@@ -20,7 +20,6 @@ import (
 	"strings"
 	"testing"
 
-	kingpin "github.com/alecthomas/kingpin/v2"
 	"github.com/go-kit/log"
 	"github.com/gosnmp/gosnmp"
 	io_prometheus_client "github.com/prometheus/client_model/go"
@@ -571,7 +570,7 @@ func TestPduToSample(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		metrics := pduToSamples(c.indexOids, c.pdu, c.metric, c.oidToPdu, log.NewNopLogger(), Metrics{})
+		metrics := pduToSamples(c.indexOids, c.pdu, c.metric, c.oidToPdu, log.NewNopLogger(), Metrics{}, true)
 		metric := &io_prometheus_client.Metric{}
 		expected := map[string]struct{}{}
 		for _, e := range c.expectedMetrics {
@@ -608,37 +607,27 @@ func TestGetPduValue(t *testing.T) {
 		Value: uint64(1 << 63),
 		Type:  gosnmp.Counter64,
 	}
-	value := getPduValue(pdu)
+	value := getPduValue(pdu, false)
 	if value <= 0 {
 		t.Fatalf("Got negative value for PDU value type Counter64: %v", value)
 	}
 }
 
 func TestGetPduLargeValue(t *testing.T) {
-	_, err := kingpin.CommandLine.Parse([]string{})
-	if err != nil {
-		t.Fatal(err)
-	}
-
 	pdu := &gosnmp.SnmpPDU{
 		Value: uint64(19007199254740992),
 		Type:  gosnmp.Counter64,
 	}
-	value := getPduValue(pdu)
+	value := getPduValue(pdu, DefaultOptions.WrapCounters)
 	if value != 992800745259008.0 {
 		t.Fatalf("Got incorrect counter wrapping for Counter64: %v", value)
 	}
 
-	_, err = kingpin.CommandLine.Parse([]string{"--no-snmp.wrap-large-counters"})
-	if err != nil {
-		t.Fatal(err)
-	}
-
 	pdu = &gosnmp.SnmpPDU{
 		Value: uint64(19007199254740992),
 		Type:  gosnmp.Counter64,
 	}
-	value = getPduValue(pdu)
+	value = getPduValue(pdu, false)
 	if value != 19007199254740990.0 {
 		t.Fatalf("Got incorrect rounded float for Counter64: %v", value)
 	}
@@ -21,32 +21,14 @@ import (
 	"sync"
 	"time"
 
+	"github.com/abialemuel/prometheus-exporter/helper"
 	"github.com/gosnmp/gosnmp"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 	"gopkg.in/yaml.v2"
 )
 
-const (
-	namespace = "snmp"
-)
-
 var (
-	Concurrency    = 1
 	defaultRetries = 0
 
-	configReloadSuccess = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: "snmp_exporter",
-		Name:      "config_last_reload_successful",
-		Help:      "Snmp exporter config loaded successfully.",
-	})
-
-	configReloadSeconds = promauto.NewGauge(prometheus.GaugeOpts{
-		Namespace: "snmp_exporter",
-		Name:      "config_last_reload_success_timestamp_seconds",
-		Help:      "Timestamp of the last successful configuration reload.",
-	})
-
 	DefaultAuth = Auth{
 		Community:     "public",
 		SecurityLevel: "noAuthNoPriv",
@@ -67,20 +49,13 @@ var (
 	DefaultRegexpExtract = RegexpExtract{
 		Value: "$1",
 	}
-
-	SnmpCollectionDuration = promauto.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Namespace: namespace,
-			Name:      "collection_duration_seconds",
-			Help:      "Duration of collections by the SNMP exporter",
-		},
-		[]string{"module"},
-	)
 )
 
 type SafeConfig struct {
 	sync.RWMutex
 	C *Config
+	// Metrics, if set, report the outcome of reloads.
+	Metrics *helper.ReloadMetrics
 }
 
 // Config for the snmp_exporter.
@@ -109,12 +84,7 @@ type Module struct {
 
 func (sc *SafeConfig) ReloadConfig(configFile string, expandEnvVars bool) (err error) {
 	defer func() {
-		if err != nil {
-			configReloadSuccess.Set(0)
-		} else {
-			configReloadSuccess.Set(1)
-			configReloadSeconds.SetToCurrentTime()
-		}
+		sc.Metrics.Observe(err)
 	}()
 	conf, err := LoadFile(configFile, expandEnvVars)
 	if err != nil {
@@ -127,11 +97,6 @@ func (sc *SafeConfig) ReloadConfig(configFile string, expandEnvVars bool) (err e
 
 	sc.Lock()
 	sc.C = conf
-	// Initialize metrics.
-
-	for module := range sc.C.Modules {
-		SnmpCollectionDuration.WithLabelValues(module)
-	}
 	sc.Unlock()
 
 	return nil
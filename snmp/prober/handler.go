@@ -1,8 +1,11 @@
 package prober
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/abialemuel/prometheus-exporter/helper"
@@ -11,53 +14,77 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"gopkg.in/yaml.v2"
 )
 
 const (
 	namespace = "snmp"
 )
 
-var (
-	// Metrics about the SNMP exporter itself.
-	snmpRequestErrors = createCounter("request_errors_total", "Errors in SNMP requests.")
-	exporterMetrics   = createExporterMetrics()
-)
-
-func createCounter(name string, help string) prometheus.Counter {
-	return promauto.NewCounter(prometheus.CounterOpts{
-		Namespace: namespace,
-		Name:      name,
-		Help:      help,
-	})
+// Metrics are the metrics about the SNMP exporter itself.
+type Metrics struct {
+	RequestErrors prometheus.Counter
+	collector.Metrics
 }
 
-func createExporterMetrics() collector.Metrics {
-	buckets := prometheus.ExponentialBuckets(0.0001, 2, 15)
-	return collector.Metrics{
-		SNMPCollectionDuration: config.SnmpCollectionDuration,
-		SNMPUnexpectedPduType:  createCounter("unexpected_pdu_type_total", "Unexpected Go types in a PDU."),
-		SNMPDuration: promauto.NewHistogram(prometheus.HistogramOpts{
-			Namespace: namespace,
-			Name:      "packet_duration_seconds",
-			Help:      "A histogram of latencies for SNMP packets.",
-			Buckets:   buckets,
-		}),
-		SNMPPackets: createCounter("packets_total", "Number of SNMP packet sent, including retries."),
-		SNMPRetries: createCounter("packet_retries_total", "Number of SNMP packet retries."),
-		SNMPInflight: promauto.NewGauge(prometheus.GaugeOpts{
+// NewMetrics creates the metrics about the exporter and registers them with
+// reg. Metrics already registered with reg, for example by another
+// exporter, are shared.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	var (
+		m   = &Metrics{}
+		err error
+	)
+	register := func(c prometheus.Collector) prometheus.Collector {
+		if err != nil {
+			return c
+		}
+		c, err = helper.Register(reg, c)
+		return c
+	}
+	counter := func(name string, help string) prometheus.Counter {
+		return register(prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: namespace,
-			Name:      "request_in_flight",
-			Help:      "Current number of SNMP scrapes being requested.",
-		}),
+			Name:      name,
+			Help:      help,
+		})).(prometheus.Counter)
 	}
+
+	m.RequestErrors = counter("request_errors_total", "Errors in SNMP requests.")
+	m.SNMPCollectionDuration = register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "collection_duration_seconds",
+		Help:      "Duration of collections by the SNMP exporter",
+	}, []string{"module"})).(*prometheus.HistogramVec)
+	m.SNMPUnexpectedPduType = counter("unexpected_pdu_type_total", "Unexpected Go types in a PDU.")
+	m.SNMPDuration = register(prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "packet_duration_seconds",
+		Help:      "A histogram of latencies for SNMP packets.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 15),
+	})).(prometheus.Histogram)
+	m.SNMPPackets = counter("packets_total", "Number of SNMP packet sent, including retries.")
+	m.SNMPRetries = counter("packet_retries_total", "Number of SNMP packet retries.")
+	m.SNMPInflight = register(prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "request_in_flight",
+		Help:      "Current number of SNMP scrapes being requested.",
+	})).(prometheus.Gauge)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-// Call is a function that calls the prober
-func Call(target string, moduleNames []string, c *config.SafeConfig, auth config.Auth, logger log.Logger, timeoutOffset float64) (helper.ProbeResult, error) {
+// Call is a function that calls the prober. The scrape is recorded in rh
+// with its logs, metrics and module configuration, and counted in metrics.
+// opts set how the target is scraped.
+func Call(target string, moduleNames []string, c *config.SafeConfig, auth config.Auth, opts collector.Options, logger log.Logger, metrics *Metrics, rh *helper.ResultHistory, timeoutOffset float64) (helper.ProbeResult, error) {
 	if target == "" {
 		level.Debug(logger).Log("msg", "parameter must be specified once", "target", target)
-		snmpRequestErrors.Inc()
+		metrics.RequestErrors.Inc()
 		return nil, fmt.Errorf("unknown target %q", target)
 	}
 
@@ -71,35 +98,62 @@ func Call(target string, moduleNames []string, c *config.SafeConfig, auth config
 
 	c.RLock()
 	var nmodules []*collector.NamedModule
+	modules := make(map[string]*config.Module, len(moduleNames))
 	for _, m := range moduleNames {
 		module, moduleOk := c.C.Modules[m]
 		if !moduleOk {
 			c.RUnlock()
 			level.Debug(logger).Log("msg", "Unknown module", "module", m)
-			snmpRequestErrors.Inc()
+			metrics.RequestErrors.Inc()
 			return nil, fmt.Errorf("unknown module %q", m)
 		}
 		getTimeout(module, timeoutOffset) // Convert timeoutOffset to time.Duration
 		nmodules = append(nmodules, collector.NewNamedModule(m, module))
+		modules[m] = module
 	}
 
 	// auth, authOk := c.C.Auths[*authName]
 	// if !authOk {
 	// 	c.RUnlock()
 	// 	level.Debug(logger).Log("msg", "Unknown auth", "auth", *authName)
-	// 	snmpRequestErrors.Inc()
+	// 	metrics.RequestErrors.Inc()
 	// 	return nil, fmt.Errorf("unknown auth %q", *authName)
 	// }
 	c.RUnlock()
 
-	logger = log.With(logger, "auth", c.C.Auths, "target", target)
+	moduleName := strings.Join(moduleNames, ",")
+	sl := newScrapeLogger(logger, moduleName, target)
+	logger = log.With(sl, "auth", c.C.Auths, "target", target)
+	level.Info(sl).Log("msg", "Beginning scrape", "timeout_seconds", timeoutOffset)
+	start := time.Now()
 	registry := prometheus.NewRegistry()
 	authName := fmt.Sprintf("version: %d, securityLevel: %s", auth.Version, auth.SecurityLevel)
-	col := collector.New(ctx, target, authName, &auth, nmodules, logger, exporterMetrics, config.Concurrency)
+	col := collector.New(ctx, target, authName, &auth, nmodules, logger, metrics.Metrics, opts)
 	registry.MustRegister(col)
 
 	// Gather metrics
 	metricFamilies, err := registry.Gather()
+	duration := time.Since(start)
+	result := &helper.Result{
+		ModuleName: moduleName,
+		Target:     target,
+		Success:    err == nil,
+		Timestamp:  start,
+		Duration:   duration,
+	}
+	if err != nil {
+		result.FailureReason = "scrape_error"
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.FailureReason = "timeout"
+		}
+		level.Error(sl).Log("msg", "Scrape failed", "duration_seconds", duration.Seconds(), "err", err)
+	} else {
+		level.Info(sl).Log("msg", "Scrape succeeded", "duration_seconds", duration.Seconds())
+	}
+	result.DebugOutput = DebugOutput(modules, &sl.buffer, metricFamilies)
+	if err := rh.Record(result); err != nil {
+		level.Warn(sl.next).Log("msg", "Error storing scrape result", "err", err)
+	}
 	if err != nil {
 		// handle error
 		return nil, fmt.Errorf("failed to gather metrics: %s", err)
@@ -110,6 +164,45 @@ func Call(target string, moduleNames []string, c *config.SafeConfig, auth config
 	return helper.NewProbeResult(success, metricFamilies), nil
 }
 
+// scrapeLogger keeps the logs of a scrape for its debug output. The
+// workers of a scrape log concurrently.
+type scrapeLogger struct {
+	next         log.Logger
+	buffer       bytes.Buffer
+	bufferLogger log.Logger
+}
+
+func newScrapeLogger(logger log.Logger, module string, target string) *scrapeLogger {
+	sl := &scrapeLogger{next: logger}
+	bl := log.NewLogfmtLogger(log.NewSyncWriter(&sl.buffer))
+	sl.bufferLogger = log.With(bl, "ts", log.DefaultTimestampUTC, "module", module, "target", target)
+	return sl
+}
+
+func (sl *scrapeLogger) Log(keyvals ...interface{}) error {
+	sl.bufferLogger.Log(keyvals...)
+	return sl.next.Log(keyvals...)
+}
+
+// DebugOutput returns plaintext debug output for a scrape.
+func DebugOutput(modules map[string]*config.Module, logBuffer *bytes.Buffer, metricFamilies []*dto.MetricFamily) string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "Logs for the scrape:\n")
+	logBuffer.WriteTo(buf)
+	fmt.Fprintf(buf, "\n\n\nMetrics that would have been returned:\n")
+	for _, mf := range metricFamilies {
+		expfmt.MetricFamilyToText(buf, mf)
+	}
+	fmt.Fprintf(buf, "\n\n\nModule configuration:\n")
+	c, err := yaml.Marshal(modules)
+	if err != nil {
+		fmt.Fprintf(buf, "Error marshalling config: %s\n", err)
+	}
+	buf.Write(c)
+
+	return buf.String()
+}
+
 func getTimeout(module *config.Module, timeout float64) (err error) {
 	if timeout <= 0 {
 		module.WalkParams.Timeout = config.DefaultWalkParams.Timeout
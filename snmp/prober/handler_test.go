@@ -0,0 +1,63 @@
+// Copyright 2024 The Prometheus Authors
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prober
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abialemuel/prometheus-exporter/helper"
+	"github.com/abialemuel/prometheus-exporter/snmp/collector"
+	"github.com/abialemuel/prometheus-exporter/snmp/config"
+)
+
+func TestCallRecordsHistory(t *testing.T) {
+	// Nothing answers on a port that was just released.
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := conn.LocalAddr().String()
+	conn.Close()
+
+	module := config.DefaultModule
+	module.Walk = []string{"1.3.6.1.2.1.1"}
+	sc := &config.SafeConfig{C: &config.Config{Modules: map[string]*config.Module{"system": &module}}}
+	rh := &helper.ResultHistory{MaxResults: 1}
+	metrics, err := NewMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Call(target, []string{"system"}, sc, config.DefaultAuth, collector.DefaultOptions, log.NewNopLogger(), metrics, rh, 1); err == nil {
+		t.Fatalf("Expected scrape of a closed port to fail")
+	}
+
+	results := rh.List()
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result in the history, got %d", len(results))
+	}
+	r := results[0]
+	if r.Success || r.ModuleName != "system" || r.Target != target || r.FailureReason == "" || r.Duration <= 0 {
+		t.Errorf("Unexpected result: %+v", r)
+	}
+	for _, want := range []string{"Requesting OIDs", "Scrape failed", "1.3.6.1.2.1.1"} {
+		if !strings.Contains(r.DebugOutput, want) {
+			t.Errorf("Expected %q in the debug output, got %s", want, r.DebugOutput)
+		}
+	}
+}
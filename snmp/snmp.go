@@ -5,20 +5,27 @@ import (
 
 	"github.com/abialemuel/prometheus-exporter/helper"
 	proto "github.com/abialemuel/prometheus-exporter/messages"
+	"github.com/abialemuel/prometheus-exporter/snmp/collector"
 	"github.com/abialemuel/prometheus-exporter/snmp/config"
 	"github.com/abialemuel/prometheus-exporter/snmp/prober"
 	"github.com/go-kit/log"
-	"github.com/prometheus/common/promlog"
 )
 
 type snmp struct {
 	timeoutOffset float64
+	rh            *helper.ResultHistory
 	sc            *config.SafeConfig
 	logger        log.Logger
+	metrics       *prober.Metrics
+	opts          collector.Options
 }
 
 type Snmp interface {
+	// Call scrapes target. Of the options set by New, only the source
+	// address can be changed for a call, by nodeConfig.SourceAddress.
 	Call(target string, moduleName []string, nodeConfig *proto.NodeConfig) (helper.ProbeResult, error)
+	// History returns the results of recent scrapes.
+	History() *helper.ResultHistory
 }
 
 var (
@@ -26,21 +33,82 @@ var (
 	expandEnvVars = false
 )
 
-func New(historyLimit uint, timeoutOffset float64, logLevel string) (Snmp, error) {
-	v := &promlog.AllowedLevel{}
-	if err := v.Set(logLevel); err != nil {
-		return nil, fmt.Errorf("error setting log level: %w", err)
+// snmpOptions returns the SNMP options of o, starting from the defaults.
+func snmpOptions(o *helper.Options) *helper.SNMPOptions {
+	if o.SNMP == nil {
+		opts := collector.DefaultOptions
+		o.SNMP = &opts
 	}
-	logger := promlog.New(&promlog.Config{Level: v})
-	sc := &config.SafeConfig{C: &config.Config{}}
+	return o.SNMP
+}
+
+// WithSourceAddress sends SNMP from addr, in the format 'address:port',
+// unless the node config of a call sets its own source address. If the port
+// is empty or '0', a random source port is chosen.
+func WithSourceAddress(addr string) helper.Option {
+	return func(o *helper.Options) {
+		snmpOptions(o).SourceAddress = addr
+	}
+}
+
+// WithWrapCounters sets whether 64-bit counters are wrapped to avoid
+// floating point rounding. They are by default.
+func WithWrapCounters(wrap bool) helper.Option {
+	return func(o *helper.Options) {
+		snmpOptions(o).WrapCounters = wrap
+	}
+}
+
+// WithConcurrency scrapes up to n modules of a call in parallel, one by
+// default.
+func WithConcurrency(n int) helper.Option {
+	return func(o *helper.Options) {
+		snmpOptions(o).Concurrency = n
+	}
+}
+
+// New loads snmp.yml and returns an exporter keeping the last historyLimit
+// scrape results. Its own metrics are registered with
+// prometheus.DefaultRegisterer and it logs to stderr at logLevel, unless
+// opts say otherwise.
+func New(historyLimit uint, timeoutOffset float64, logLevel string, opts ...helper.Option) (Snmp, error) {
+	return NewWithHistory(&helper.ResultHistory{MaxResults: historyLimit}, timeoutOffset, logLevel, opts...)
+}
+
+// NewWithHistory is like New, recording scrape results in the given
+// history, for example one persisted in a helper.FileStore.
+func NewWithHistory(rh *helper.ResultHistory, timeoutOffset float64, logLevel string, opts ...helper.Option) (Snmp, error) {
+	o, err := helper.NewOptions(logLevel, opts...)
+	if err != nil {
+		return nil, err
+	}
+	collectorOpts := *snmpOptions(o)
+	if collectorOpts.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1, got %d", collectorOpts.Concurrency)
+	}
+	metrics, err := prober.NewMetrics(o.Registerer)
+	if err != nil {
+		return nil, fmt.Errorf("error registering metrics: %w", err)
+	}
+	reloadMetrics, err := helper.NewReloadMetrics(o.Registerer, "snmp_exporter", "Snmp exporter")
+	if err != nil {
+		return nil, fmt.Errorf("error registering metrics: %w", err)
+	}
+	sc := &config.SafeConfig{C: &config.Config{}, Metrics: reloadMetrics}
 	if err := sc.ReloadConfig(path, expandEnvVars); err != nil {
 		return nil, fmt.Errorf("error loading config: %w", err)
 	}
+	for module := range sc.C.Modules {
+		metrics.SNMPCollectionDuration.WithLabelValues(module)
+	}
 
 	return &snmp{
 		timeoutOffset: timeoutOffset,
+		rh:            rh,
 		sc:            sc,
-		logger:        logger,
+		logger:        o.Logger,
+		metrics:       metrics,
+		opts:          collectorOpts,
 	}, nil
 }
 
@@ -63,5 +131,16 @@ func (c *snmp) Call(target string, moduleName []string, nodeConfig *proto.NodeCo
 		c.timeoutOffset = float64(nodeConfig.Timeout)
 	}
 
-	return prober.Call(target, moduleName, c.sc, snmpAuth, c.logger, c.timeoutOffset)
+	// source address of this node, if set
+	opts := c.opts
+	if nodeConfig.SourceAddress != "" {
+		opts.SourceAddress = nodeConfig.SourceAddress
+	}
+
+	return prober.Call(target, moduleName, c.sc, snmpAuth, opts, c.logger, c.metrics, c.rh, c.timeoutOffset)
+}
+
+// History returns the results of recent scrapes.
+func (c *snmp) History() *helper.ResultHistory {
+	return c.rh
 }
@@ -0,0 +1,45 @@
+package snmp
+
+import (
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abialemuel/prometheus-exporter/helper"
+	"github.com/abialemuel/prometheus-exporter/snmp/collector"
+)
+
+func TestNewOptions(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	first, err := New(1, 1, "", helper.WithRegisterer(reg), helper.WithLogger(log.NewNopLogger()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := first.(*snmp).opts; got != collector.DefaultOptions {
+		t.Errorf("Expected default collector options, got %+v", got)
+	}
+
+	// A second exporter on the same registerer shares its metrics.
+	second, err := New(1, 1, "",
+		helper.WithRegisterer(reg),
+		helper.WithLogger(log.NewNopLogger()),
+		WithSourceAddress("127.0.0.1:0"),
+		WithWrapCounters(false),
+		WithConcurrency(4),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := collector.Options{SourceAddress: "127.0.0.1:0", WrapCounters: false, Concurrency: 4}
+	if got := second.(*snmp).opts; got != want {
+		t.Errorf("Expected collector options %+v, got %+v", want, got)
+	}
+	if first.(*snmp).metrics.SNMPPackets != second.(*snmp).metrics.SNMPPackets {
+		t.Errorf("Expected exporters on the same registerer to share metrics")
+	}
+
+	if _, err := New(1, 1, "info", helper.WithRegisterer(prometheus.NewRegistry()), WithConcurrency(0)); err == nil {
+		t.Errorf("Expected an error for a concurrency of 0")
+	}
+}
@@ -0,0 +1,76 @@
+// Package tracing sets up OpenTelemetry tracer providers for the spans of
+// probes. Probes are traced through the global tracer provider, set it
+// with otel.SetTracerProvider.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+)
+
+// DefaultServiceName is the service name of spans if none is configured.
+const DefaultServiceName = "prometheus-exporter"
+
+// Config configures the spans and their OTLP export.
+type Config struct {
+	// Endpoint is the host and port of an OTLP/HTTP receiver, such as
+	// localhost:4318.
+	Endpoint string
+	// Insecure sends spans over plain HTTP.
+	Insecure bool
+	Headers  map[string]string
+	// ServiceName defaults to DefaultServiceName.
+	ServiceName string
+	// SampleRatio is the share of probes to trace, from 0 to 1. Zero traces
+	// all probes.
+	SampleRatio float64
+}
+
+// NewOTLPProvider returns a tracer provider exporting spans in batches to
+// an OTLP/HTTP receiver. Shut it down to flush the remaining spans.
+func NewOTLPProvider(ctx context.Context, cfg Config) (*sdktrace.TracerProvider, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("OTLP endpoint must be set")
+	}
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(cfg.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating OTLP exporter: %w", err)
+	}
+	return NewProvider(sdktrace.NewBatchSpanProcessor(exporter), cfg)
+}
+
+// NewProvider returns a tracer provider passing spans to a span processor,
+// such as a tracetest.SpanRecorder in tests. Only the service name and the
+// sample ratio of the configuration are used.
+func NewProvider(processor sdktrace.SpanProcessor, cfg Config) (*sdktrace.TracerProvider, error) {
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("sample ratio %v must be between 0 and 1", cfg.SampleRatio)
+	}
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	res := resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+		sdktrace.WithResource(res),
+	), nil
+}
@@ -0,0 +1,58 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.25.0"
+
+	"github.com/abialemuel/prometheus-exporter/tracing"
+)
+
+func TestNewOTLPProvider(t *testing.T) {
+	var requests atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" && r.Header.Get("X-Tenant") == "team-a" {
+			requests.Add(1)
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	defer receiver.Close()
+
+	ctx := context.Background()
+	tp, err := tracing.NewOTLPProvider(ctx, tracing.Config{
+		Endpoint: strings.TrimPrefix(receiver.URL, "http://"),
+		Insecure: true,
+		Headers:  map[string]string{"X-Tenant": "team-a"},
+	})
+	require.NoError(t, err)
+	_, span := tp.Tracer("test").Start(ctx, "probe")
+	span.End()
+	require.NoError(t, tp.Shutdown(ctx))
+	assert.Equal(t, int32(1), requests.Load())
+
+	_, err = tracing.NewOTLPProvider(ctx, tracing.Config{})
+	assert.Error(t, err)
+}
+
+func TestNewProvider(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp, err := tracing.NewProvider(recorder, tracing.Config{ServiceName: "probes"})
+	require.NoError(t, err)
+	_, span := tp.Tracer("test").Start(context.Background(), "probe")
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Contains(t, spans[0].Resource().Attributes(), semconv.ServiceName("probes"))
+
+	_, err = tracing.NewProvider(recorder, tracing.Config{SampleRatio: 2})
+	assert.Error(t, err)
+}